@@ -1,6 +1,11 @@
 package restic
 
-import "io"
+import (
+	"io"
+	"os"
+	"sort"
+	"time"
+)
 
 // Backend is used to store and access data.
 type Backend interface {
@@ -35,6 +40,145 @@ type Backend interface {
 	List(t FileType, done <-chan struct{}) <-chan string
 }
 
+// AccessEvent records an operation on a file in a backend.
+type AccessEvent struct {
+	Time     time.Time     `json:"time"`
+	Type     FileType      `json:"type"`
+	Name     string        `json:"name"`
+	Op       string        `json:"op"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+
+	// Error is the error returned by the operation, empty on success.
+	Error string `json:"error,omitempty"`
+}
+
+// MirrorDiff lists the differences between two backends which should hold
+// the same files.
+type MirrorDiff struct {
+	MissingOnPrimary   []Handle
+	MissingOnSecondary []Handle
+
+	// SizeMismatch holds the files which exist in both backends with
+	// different sizes, ContentMismatch those with the same size but
+	// different content (only checked on request).
+	SizeMismatch    []Handle
+	ContentMismatch []Handle
+}
+
+// Empty returns true if no differences were found.
+func (d MirrorDiff) Empty() bool {
+	return len(d.MissingOnPrimary) == 0 && len(d.MissingOnSecondary) == 0 &&
+		len(d.SizeMismatch) == 0 && len(d.ContentMismatch) == 0
+}
+
+// TypeStats summarizes the sizes of the files of one type.
+type TypeStats struct {
+	Count     int
+	TotalSize int64
+
+	// Smallest and Largest are the sizes of the smallest and largest file,
+	// both are zero if there are no files.
+	Smallest, Largest int64
+
+	// Histogram counts the files by the number of bits of their size:
+	// Histogram[0] holds the empty files, Histogram[i] the files with a size
+	// of at least 2^(i-1) and less than 2^i bytes.
+	Histogram []int
+}
+
+// Add records a file with the given size.
+func (s *TypeStats) Add(size int64) {
+	if s.Count == 0 || size < s.Smallest {
+		s.Smallest = size
+	}
+	if size > s.Largest {
+		s.Largest = size
+	}
+	s.Count++
+	s.TotalSize += size
+
+	bits := 0
+	for n := size; n > 0; n >>= 1 {
+		bits++
+	}
+	for len(s.Histogram) <= bits {
+		s.Histogram = append(s.Histogram, 0)
+	}
+	s.Histogram[bits]++
+}
+
+// LayoutManifest describes the directory structure of a repository without
+// the files, so that it can be recreated elsewhere.
+type LayoutManifest struct {
+	// Dirs holds the directories sorted by path, starting with the
+	// repository directory itself as ".".
+	Dirs []LayoutDir
+
+	// ShardDepth is the number of levels of subdirectories below the data
+	// directory: one for the default shards, two if sub-shards exist.
+	ShardDepth int
+
+	// SnapshotBuckets is true if new snapshots are stored in date buckets.
+	SnapshotBuckets bool
+}
+
+// LayoutDir is a directory in a LayoutManifest.
+type LayoutDir struct {
+	// Path is relative to the repository directory, with forward slashes.
+	Path string
+	Mode os.FileMode
+}
+
+// Listing is an immutable list of the files in a backend at a point in time.
+// It is safe for concurrent use.
+type Listing struct {
+	names map[FileType][]string
+}
+
+// NewListing returns a Listing holding names, which are sorted in place and
+// must not be modified afterwards.
+func NewListing(names map[FileType][]string) Listing {
+	for _, n := range names {
+		sort.Strings(n)
+	}
+	return Listing{names: names}
+}
+
+// Names returns the sorted names of all files of type t.
+func (l Listing) Names(t FileType) []string {
+	names := make([]string, len(l.names[t]))
+	copy(names, l.names[t])
+	return names
+}
+
+// Has returns true if the file at h was part of the listing.
+func (l Listing) Has(h Handle) bool {
+	names := l.names[h.Type]
+	i := sort.SearchStrings(names, h.Name)
+	return i < len(names) && names[i] == h.Name
+}
+
 // FileInfo is returned by Stat() and contains information about a file in the
 // backend.
-type FileInfo struct{ Size int64 }
+type FileInfo struct {
+	Size int64
+
+	// ModTime is the modification time of the file, it is zero if the
+	// backend does not report it.
+	ModTime time.Time
+}
+
+// RepoInfo describes the state of a repository location as found by probing
+// it without opening the repository.
+type RepoInfo struct {
+	// HasConfig is true if the config file exists, Config holds its raw
+	// (encrypted) content.
+	HasConfig     bool
+	Config        []byte
+	ConfigSize    int64
+	ConfigModTime time.Time
+
+	// MissingDirs lists the expected subdirectories which do not exist.
+	MissingDirs []string
+}
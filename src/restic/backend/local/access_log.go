@@ -0,0 +1,196 @@
+package local
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"restic"
+	"sync"
+	"time"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// accessLogBuffer is the number of events which may be pending before new
+// events are dropped.
+const accessLogBuffer = 1024
+
+// accessLog writes the events recorded by logAccess in the background. The
+// writer is started on first use.
+type accessLog struct {
+	m       sync.Mutex
+	ch      chan restic.AccessEvent
+	done    chan struct{}
+	dropped int
+}
+
+func (b *Local) accessLogFile() string {
+	return filepath.Join(b.Path, localPaths.Log, "access.log")
+}
+
+// logAccess records the operation op on h which started at start and
+// transferred n bytes. It never blocks, the event is dropped if the writer
+// does not keep up.
+func (b *Local) logAccess(op string, h restic.Handle, start time.Time, n int64, err error) {
+	ev := restic.AccessEvent{
+		Time:     start,
+		Type:     h.Type,
+		Name:     h.Name,
+		Op:       op,
+		Bytes:    n,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	l := &b.accessLog
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.ch == nil {
+		l.ch = make(chan restic.AccessEvent, accessLogBuffer)
+		l.done = make(chan struct{})
+		go b.writeAccessLog(l.ch, l.done)
+	}
+
+	select {
+	case l.ch <- ev:
+	default:
+		l.dropped++
+	}
+}
+
+// writeAccessLog appends the events received from ch to the log file until
+// ch is closed.
+func (b *Local) writeAccessLog(ch <-chan restic.AccessEvent, done chan<- struct{}) {
+	defer close(done)
+
+	fn := b.accessLogFile()
+	var f File
+	err := b.FS.MkdirAll(filepath.Dir(fn), backend.Modes.Dir)
+	if err == nil {
+		f, err = b.FS.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, backend.Modes.File)
+	}
+	if err != nil {
+		debug.Log("unable to open access log: %v", err)
+		for range ch {
+		}
+		return
+	}
+
+	wr := bufio.NewWriter(f)
+	enc := json.NewEncoder(wr)
+	for ev := range ch {
+		if err := enc.Encode(ev); err != nil {
+			debug.Log("unable to write access log: %v", err)
+		}
+
+		// write the events when no more are pending
+		if len(ch) == 0 {
+			if err := wr.Flush(); err != nil {
+				debug.Log("unable to write access log: %v", err)
+			}
+		}
+	}
+
+	if err := wr.Flush(); err != nil {
+		debug.Log("unable to write access log: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		debug.Log("unable to close access log: %v", err)
+	}
+}
+
+// flushAccessLog waits until all pending events have been written. The
+// writer is started again by the next event.
+func (b *Local) flushAccessLog() {
+	l := &b.accessLog
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.ch == nil {
+		return
+	}
+
+	close(l.ch)
+	<-l.done
+	l.ch, l.done = nil, nil
+
+	if l.dropped > 0 {
+		debug.Log("%d access log events were dropped", l.dropped)
+		l.dropped = 0
+	}
+}
+
+// ReadAccessLog returns the events recorded in the access log since the
+// given time, in the order in which they were written. Pending events are
+// written first.
+func (b *Local) ReadAccessLog(since time.Time) ([]restic.AccessEvent, error) {
+	b.flushAccessLog()
+
+	f, err := b.FS.Open(b.accessLogFile())
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Open")
+	}
+	defer f.Close()
+
+	var events []restic.AccessEvent
+	dec := json.NewDecoder(f)
+	for {
+		var ev restic.AccessEvent
+		err := dec.Decode(&ev)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events, errors.Wrap(err, "Decode")
+		}
+
+		if !ev.Time.Before(since) {
+			events = append(events, ev)
+		}
+	}
+
+	return events, nil
+}
+
+// countingReader counts the bytes read from rd.
+type countingReader struct {
+	rd io.Reader
+	n  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.rd.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// accessLogReader records the Load it was returned by when it is closed.
+type accessLogReader struct {
+	io.ReadCloser
+	be    *Local
+	h     restic.Handle
+	start time.Time
+	n     int64
+}
+
+func (r *accessLogReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *accessLogReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.be.logAccess("Load", r.h, r.start, r.n, err)
+	return err
+}
@@ -0,0 +1,83 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"restic"
+	"testing"
+	"time"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestAccessLog(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	be.AccessLog = true
+
+	start := time.Now()
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	_, err := be.Stat(h)
+	OK(t, err)
+
+	rd, err := be.Load(h, 100, 10)
+	OK(t, err)
+	_, err = ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+
+	missing := restic.Handle{Type: restic.LockFile, Name: "missing"}
+	_, err = be.Load(missing, 0, 0)
+	Assert(t, err != nil, "Load of a missing file succeeded")
+
+	OK(t, be.Remove(h))
+
+	events, err := be.ReadAccessLog(start)
+	OK(t, err)
+
+	type op struct {
+		Op     string
+		Name   string
+		Bytes  int64
+		Failed bool
+	}
+	var ops []op
+	for _, ev := range events {
+		Assert(t, !ev.Time.Before(start), "event %v before start", ev)
+		ops = append(ops, op{ev.Op, ev.Name, ev.Bytes, ev.Error != ""})
+	}
+
+	Equals(t, []op{
+		{"Save", h.Name, 1000, false},
+		{"Stat", h.Name, 0, false},
+		{"Load", h.Name, 100, false},
+		{"Load", "missing", 0, true},
+		{"Remove", h.Name, 0, false},
+	}, ops)
+
+	// the log is not part of any listing
+	for _, tpe := range []restic.FileType{restic.DataFile, restic.LockFile, restic.IndexFile} {
+		for name := range be.List(tpe, nil) {
+			t.Errorf("unexpected file %v of type %v listed", name, tpe)
+		}
+	}
+
+	// the log is appended to and can be queried by time
+	OK(t, be.Close())
+	later := time.Now()
+	_, err = be.Test(h)
+	OK(t, err)
+
+	events, err = be.ReadAccessLog(later)
+	OK(t, err)
+	Equals(t, 1, len(events))
+	Equals(t, "Test", events[0].Op)
+
+	events, err = be.ReadAccessLog(start)
+	OK(t, err)
+	Equals(t, 6, len(events))
+}
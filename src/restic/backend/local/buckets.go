@@ -0,0 +1,151 @@
+package local
+
+import (
+	"io"
+	"path/filepath"
+	"restic"
+	"strconv"
+	"sync"
+	"time"
+
+	"restic/backend"
+	"restic/debug"
+)
+
+// snapshotBucket returns the directory below Paths.Snapshots for a snapshot
+// created at t.
+func snapshotBucket(t time.Time) string {
+	return filepath.Join(t.Format("2006"), t.Format("01"))
+}
+
+// isNumber returns true if s consists of n decimal digits.
+func isNumber(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
+// hasSnapshotBuckets returns true if the snapshot directory of the repository
+// at base contains date bucket directories.
+func hasSnapshotBuckets(fsys FS, base string) bool {
+	fileInfos, err := readdir(fsys, filepath.Join(base, backend.Paths.Snapshots))
+	if err != nil {
+		return false
+	}
+
+	for _, fi := range fileInfos {
+		if fi.IsDir() && isNumber(fi.Name(), 4) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bucketed returns true if snapshot files may be stored in date buckets.
+func (b *Local) bucketed() bool {
+	return b.SnapshotBuckets || b.hasBuckets
+}
+
+// bucketCache holds the date bucket directories of the repository, so that
+// looking up a snapshot does not read the snapshot directory each time. The
+// directories are read on first use, buckets created by another process
+// afterwards are only found after opening the repository again.
+type bucketCache struct {
+	m     sync.Mutex
+	valid bool
+	dirs  []string
+}
+
+// add records the new bucket dir if the buckets have been read already.
+func (c *bucketCache) add(dir string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if !c.valid {
+		return
+	}
+	for _, d := range c.dirs {
+		if d == dir {
+			return
+		}
+	}
+	c.dirs = append(c.dirs, dir)
+}
+
+// reset forgets the buckets, they are read again on next use.
+func (c *bucketCache) reset() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.valid = false
+	c.dirs = nil
+}
+
+// snapshotBucketDirs returns all existing date bucket directories.
+func (b *Local) snapshotBucketDirs() []string {
+	b.buckets.m.Lock()
+	defer b.buckets.m.Unlock()
+	if !b.buckets.valid {
+		b.buckets.dirs = b.readBucketDirs()
+		b.buckets.valid = true
+	}
+	return append([]string(nil), b.buckets.dirs...)
+}
+
+// readBucketDirs reads the date bucket directories from the snapshot
+// directory.
+func (b *Local) readBucketDirs() (dirs []string) {
+	base := filepath.Join(b.Path, backend.Paths.Snapshots)
+	years, err := readdir(b.FS, base)
+	if err != nil {
+		debug.Log("unable to read snapshot dir: %v", err)
+		return nil
+	}
+
+	for _, year := range years {
+		if !year.IsDir() || !isNumber(year.Name(), 4) {
+			continue
+		}
+
+		months, err := readdir(b.FS, filepath.Join(base, year.Name()))
+		if err != nil {
+			debug.Log("unable to read bucket %v: %v", year.Name(), err)
+			continue
+		}
+
+		for _, month := range months {
+			if month.IsDir() && isNumber(month.Name(), 2) {
+				dirs = append(dirs, filepath.Join(base, year.Name(), month.Name()))
+			}
+		}
+	}
+
+	return dirs
+}
+
+// listSnapshots returns the names of all snapshot files in the flat
+// directory and in all date buckets.
+func (b *Local) listSnapshots(fsys FS, dir string) ([]string, error) {
+	names, err := listDir(fsys, dir)
+	if err != nil || !b.bucketed() {
+		return names, err
+	}
+
+	for _, bucket := range b.snapshotBucketDirs() {
+		files, err := listDir(fsys, bucket)
+		if err != nil {
+			continue
+		}
+		names = append(names, files...)
+	}
+
+	return names, nil
+}
+
+// SaveAt stores data in the backend at the handle like Save. If
+// SnapshotBuckets is set, snapshot files are stored in the date bucket for t.
+func (b *Local) SaveAt(h restic.Handle, rd io.Reader, t time.Time) error {
+	debug.Log("SaveAt %v, %v", h, t)
+	return b.save(h, rd, saveOptions{date: t})
+}
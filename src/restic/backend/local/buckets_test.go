@@ -0,0 +1,101 @@
+package local_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"restic/backend"
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func listNames(be restic.Backend, tpe restic.FileType) []string {
+	var names []string
+	for name := range be.List(tpe, nil) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSnapshotBuckets(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	// a snapshot in the flat layout
+	OK(t, be.Save(restic.Handle{Type: restic.SnapshotFile, Name: "flat"}, strings.NewReader("flat")))
+
+	be.SnapshotBuckets = true
+	dates := map[string]time.Time{
+		"snap1": time.Date(2016, 12, 31, 23, 0, 0, 0, time.Local),
+		"snap2": time.Date(2017, 1, 1, 1, 0, 0, 0, time.Local),
+		"snap3": time.Date(2017, 1, 15, 1, 0, 0, 0, time.Local),
+	}
+
+	for name, date := range dates {
+		OK(t, be.SaveAt(restic.Handle{Type: restic.SnapshotFile, Name: name}, strings.NewReader(name), date))
+	}
+
+	dir := filepath.Join(be.Location(), "snapshots")
+	for _, fn := range []string{"2016/12/snap1", "2017/01/snap2", "2017/01/snap3"} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(fn)))
+		OK(t, err)
+	}
+
+	// a snapshot with the same name cannot be saved again
+	h := restic.Handle{Type: restic.SnapshotFile, Name: "snap1"}
+	Assert(t, be.Save(h, strings.NewReader("snap1")) != nil, "existing snapshot was overwritten")
+
+	// both layouts are detected by Open
+	be2, err := local.Open(local.Config{Path: be.Location()})
+	OK(t, err)
+
+	Equals(t, []string{"flat", "snap1", "snap2", "snap3"}, listNames(be2, restic.SnapshotFile))
+
+	for _, name := range []string{"flat", "snap1", "snap2", "snap3"} {
+		buf, err := backend.LoadAll(be2, restic.Handle{Type: restic.SnapshotFile, Name: name})
+		OK(t, err)
+		Equals(t, name, string(buf))
+	}
+
+	OK(t, be2.Remove(h))
+	Equals(t, []string{"flat", "snap2", "snap3"}, listNames(be2, restic.SnapshotFile))
+}
+
+func TestSnapshotBucketsListing(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	OK(t, be.Save(restic.Handle{Type: restic.SnapshotFile, Name: "flat"}, strings.NewReader("flat")))
+	digest, err := be.StateDigest(nil)
+	OK(t, err)
+
+	be.SnapshotBuckets = true
+	date := time.Date(2017, 1, 1, 1, 0, 0, 0, time.Local)
+	OK(t, be.SaveAt(restic.Handle{Type: restic.SnapshotFile, Name: "bucketed"}, strings.NewReader("bucketed"), date))
+
+	digest2, err := be.StateDigest(nil)
+	OK(t, err)
+	Assert(t, digest != digest2, "snapshot in bucket does not change the digest")
+
+	var buf bytes.Buffer
+	OK(t, be.ExportListing(&buf, nil))
+	var entries []local.ListingEntry
+	OK(t, json.Unmarshal(buf.Bytes(), &entries))
+
+	var names []string
+	for _, e := range entries {
+		if e.Type == restic.SnapshotFile {
+			names = append(names, e.Name)
+		}
+	}
+	sort.Strings(names)
+	Equals(t, []string{"bucketed", "flat"}, names)
+}
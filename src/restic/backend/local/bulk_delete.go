@@ -0,0 +1,60 @@
+package local
+
+import (
+	"os"
+	"restic"
+	"time"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// BulkDelete removes the files received from handles until the channel is
+// closed, at most rate files per second (zero means no limit). Files which
+// cannot be removed are returned in failed, the others are still processed.
+// Files which do not exist are counted as deleted, so an interrupted run can
+// be resumed with the same handles.
+//
+// When done is closed, BulkDelete returns an error. Each handle received
+// from the channel so far has then either been deleted or is part of failed,
+// no further handles are read.
+func (b *Local) BulkDelete(handles <-chan restic.Handle, rate int, done <-chan struct{}) (deleted int, failed []restic.Handle, err error) {
+	debug.Log("BulkDelete, rate %v", rate)
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	canceled := errors.New("BulkDelete canceled")
+	for first := true; ; first = false {
+		if tick != nil && !first {
+			select {
+			case <-tick:
+			case <-done:
+				return deleted, failed, canceled
+			}
+		}
+
+		var h restic.Handle
+		var ok bool
+		select {
+		case h, ok = <-handles:
+		case <-done:
+			return deleted, failed, canceled
+		}
+
+		if !ok {
+			return deleted, failed, nil
+		}
+
+		if err := b.Remove(h); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			debug.Log("unable to remove %v: %v", h, err)
+			failed = append(failed, h)
+			continue
+		}
+		deleted++
+	}
+}
@@ -0,0 +1,103 @@
+package local
+
+import (
+	"os"
+	"restic"
+	"syscall"
+	"testing"
+	"time"
+
+	. "restic/test"
+)
+
+func sendHandles(handles []restic.Handle) <-chan restic.Handle {
+	ch := make(chan restic.Handle, len(handles))
+	for _, h := range handles {
+		ch <- h
+	}
+	close(ch)
+	return ch
+}
+
+func TestBulkDelete(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var handles []restic.Handle
+	for i := 0; i < 5; i++ {
+		h, _ := saveData(t, be, i, 100)
+		handles = append(handles, h)
+	}
+
+	// missing files are counted as deleted
+	OK(t, be.Remove(handles[0]))
+
+	be.FS = &fakeFS{FS: defaultFS, fail: func(op, name string) error {
+		if op == "Remove" && name == filename(be.Path, handles[2].Type, handles[2].Name) {
+			return syscall.EIO
+		}
+		return nil
+	}}
+
+	deleted, failed, err := be.BulkDelete(sendHandles(handles), 0, nil)
+	OK(t, err)
+	Equals(t, 4, deleted)
+	Equals(t, []restic.Handle{handles[2]}, failed)
+
+	be.FS = defaultFS
+	for i, h := range handles {
+		ok, err := be.Test(h)
+		OK(t, err)
+		Equals(t, i == 2, ok)
+	}
+}
+
+func TestBulkDeleteRate(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var handles []restic.Handle
+	for i := 0; i < 6; i++ {
+		h, _ := saveData(t, be, i, 100)
+		handles = append(handles, h)
+	}
+
+	start := time.Now()
+	deleted, failed, err := be.BulkDelete(sendHandles(handles), 50, nil)
+	OK(t, err)
+	Equals(t, 6, deleted)
+	Equals(t, 0, len(failed))
+
+	// five intervals of 20ms between the six deletions
+	d := time.Since(start)
+	Assert(t, d >= 90*time.Millisecond, "six deletions at 50/s took only %v", d)
+}
+
+func TestBulkDeleteCancel(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var handles []restic.Handle
+	for i := 0; i < 5; i++ {
+		h, _ := saveData(t, be, i, 100)
+		handles = append(handles, h)
+	}
+
+	ch := make(chan restic.Handle)
+	done := make(chan struct{})
+	go func() {
+		ch <- handles[0]
+		ch <- handles[1]
+		close(done)
+	}()
+
+	deleted, failed, err := be.BulkDelete(ch, 0, done)
+	Assert(t, err != nil, "BulkDelete was not canceled")
+	Equals(t, 2, deleted)
+	Equals(t, 0, len(failed))
+
+	for i, h := range handles {
+		_, err := os.Stat(filename(be.Path, h.Type, h.Name))
+		Equals(t, i < 2, os.IsNotExist(err))
+	}
+}
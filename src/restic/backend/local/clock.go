@@ -0,0 +1,61 @@
+package local
+
+import (
+	"path/filepath"
+	"time"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrClockSkew is returned by CheckClock if the modification times set by
+// the file system differ from the local clock by more than MaxClockSkew.
+var ErrClockSkew = errors.New("clock skew between storage and local time")
+
+// MaxClockSkew is the skew tolerated by CheckClock. It is large enough for
+// file systems which store modification times with a resolution of two
+// seconds.
+const MaxClockSkew = 30 * time.Second
+
+// CheckClock estimates the difference between the clock which sets the
+// modification times of new files (e.g. that of an NFS server) and the local
+// clock, by creating a scratch file below Paths.Temp. A positive skew means
+// that the modification times are ahead of the local time. Features which
+// compare modification times to the local time, like RetentionByType, are
+// unreliable if the skew is large. If it is larger than MaxClockSkew, the
+// skew is returned together with ErrClockSkew.
+func (b *Local) CheckClock() (skew time.Duration, err error) {
+	before := time.Now()
+	f, err := b.FS.TempFile(filepath.Join(b.Path, backend.Paths.Temp), "clock-")
+	if err != nil {
+		return 0, errors.Wrap(err, "TempFile")
+	}
+	fn := f.Name()
+	defer b.FS.Remove(fn)
+
+	_, err = f.Write([]byte("clock"))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "Write")
+	}
+	after := time.Now()
+
+	fi, err := b.FS.Stat(fn)
+	if err != nil {
+		return 0, errors.Wrap(err, "Stat")
+	}
+
+	// the file was written at some point between before and after
+	local := before.Add(after.Sub(before) / 2)
+	skew = fi.ModTime().Sub(local)
+	debug.Log("clock skew of %v is %v", b.Path, skew)
+
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return skew, errors.Wrapf(ErrClockSkew, "modification times differ by %v", skew)
+	}
+
+	return skew, nil
+}
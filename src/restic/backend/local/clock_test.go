@@ -0,0 +1,56 @@
+package local
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// skewFS reports modification times which are off by skew.
+type skewFS struct {
+	FS
+	skew time.Duration
+}
+
+type skewFileInfo struct {
+	os.FileInfo
+	skew time.Duration
+}
+
+func (fi skewFileInfo) ModTime() time.Time {
+	return fi.FileInfo.ModTime().Add(fi.skew)
+}
+
+func (f skewFS) Stat(name string) (os.FileInfo, error) {
+	fi, err := f.FS.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return skewFileInfo{FileInfo: fi, skew: f.skew}, nil
+}
+
+func TestCheckClock(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	skew, err := be.CheckClock()
+	OK(t, err)
+	Assert(t, skew < 5*time.Second && skew > -5*time.Second, "unexpected skew %v", skew)
+
+	fsys := be.FS
+	for _, want := range []time.Duration{-time.Hour, 2 * time.Minute} {
+		be.FS = skewFS{FS: fsys, skew: want}
+		skew, err = be.CheckClock()
+		Assert(t, errors.Cause(err) == ErrClockSkew, "expected ErrClockSkew, got %v", err)
+		Assert(t, skew > want-5*time.Second && skew < want+5*time.Second,
+			"skew %v is too far from %v", skew, want)
+	}
+
+	// small differences are tolerated
+	be.FS = skewFS{FS: fsys, skew: 2 * time.Second}
+	_, err = be.CheckClock()
+	OK(t, err)
+}
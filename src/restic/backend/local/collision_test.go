@@ -0,0 +1,48 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestNameCollidesWithDirectory(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	for _, h := range []restic.Handle{
+		{Type: restic.DataFile, Name: restic.Hash(data).String()},
+		{Type: restic.LockFile, Name: "lock"},
+	} {
+		fn := be.filename(h.Type, h.Name)
+		OK(t, os.MkdirAll(fn, 0700))
+
+		for _, save := range []func(restic.Handle, *bytes.Reader) error{
+			func(h restic.Handle, rd *bytes.Reader) error { return be.Save(h, rd) },
+			func(h restic.Handle, rd *bytes.Reader) error { return be.Replace(h, rd) },
+		} {
+			err := save(h, bytes.NewReader(data))
+			Assert(t, errors.Cause(err) == ErrNameCollidesWithDirectory,
+				"%v: expected ErrNameCollidesWithDirectory, got %v", h, err)
+			Assert(t, err != nil && bytes.Contains([]byte(err.Error()), []byte(fn)),
+				"path missing in error %v", err)
+		}
+
+		// the directory is left alone and no tempfiles remain
+		fi, err := os.Stat(fn)
+		OK(t, err)
+		Assert(t, fi.IsDir(), "directory was replaced")
+
+		entries, err := ioutil.ReadDir(filepath.Join(be.Path, backend.Paths.Temp))
+		OK(t, err)
+		Equals(t, 0, len(entries))
+	}
+}
@@ -0,0 +1,177 @@
+package local
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"restic"
+	"sort"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// mirrorTypes are the file types compared by CompareMirror. Locks are only
+// held temporarily, so they are not expected to be mirrored.
+var mirrorTypes = []restic.FileType{
+	restic.DataFile,
+	restic.IndexFile,
+	restic.KeyFile,
+	restic.SnapshotFile,
+}
+
+// CompareMirror lists the files of both backends and reports the files which
+// are missing on one of them and those whose sizes differ. The config file
+// is compared as well, lock files are ignored. The handles in each list are
+// sorted by type and name. If done is closed, an error is returned.
+func CompareMirror(primary, secondary restic.Backend, done <-chan struct{}) (restic.MirrorDiff, error) {
+	return compareMirror(primary, secondary, false, done)
+}
+
+// CompareMirrorContent works like CompareMirror, and additionally reads the
+// files with the same size from both backends and reports those whose
+// content differs.
+func CompareMirrorContent(primary, secondary restic.Backend, done <-chan struct{}) (restic.MirrorDiff, error) {
+	return compareMirror(primary, secondary, true, done)
+}
+
+func compareMirror(primary, secondary restic.Backend, deep bool, done <-chan struct{}) (diff restic.MirrorDiff, err error) {
+	debug.Log("CompareMirror %v and %v, deep %v", primary.Location(), secondary.Location(), deep)
+
+	canceled := func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var both []restic.Handle
+	for _, t := range mirrorTypes {
+		p := listSet(primary, t, done)
+		s := listSet(secondary, t, done)
+		if canceled() {
+			return diff, errors.New("CompareMirror canceled")
+		}
+
+		for _, name := range sortedKeys(p) {
+			h := restic.Handle{Type: t, Name: name}
+			if _, ok := s[name]; ok {
+				both = append(both, h)
+			} else {
+				diff.MissingOnSecondary = append(diff.MissingOnSecondary, h)
+			}
+		}
+
+		for _, name := range sortedKeys(s) {
+			if _, ok := p[name]; !ok {
+				diff.MissingOnPrimary = append(diff.MissingOnPrimary, restic.Handle{Type: t, Name: name})
+			}
+		}
+	}
+
+	cfg := restic.Handle{Type: restic.ConfigFile}
+	inPrimary, err := primary.Test(cfg)
+	if err != nil {
+		return diff, err
+	}
+	inSecondary, err := secondary.Test(cfg)
+	if err != nil {
+		return diff, err
+	}
+
+	switch {
+	case inPrimary && inSecondary:
+		both = append([]restic.Handle{cfg}, both...)
+	case inPrimary:
+		diff.MissingOnSecondary = append([]restic.Handle{cfg}, diff.MissingOnSecondary...)
+	case inSecondary:
+		diff.MissingOnPrimary = append([]restic.Handle{cfg}, diff.MissingOnPrimary...)
+	}
+
+	for _, h := range both {
+		if canceled() {
+			return diff, errors.New("CompareMirror canceled")
+		}
+
+		pfi, err := primary.Stat(h)
+		if err != nil {
+			return diff, err
+		}
+		sfi, err := secondary.Stat(h)
+		if err != nil {
+			return diff, err
+		}
+
+		if pfi.Size != sfi.Size {
+			diff.SizeMismatch = append(diff.SizeMismatch, h)
+			continue
+		}
+
+		if !deep {
+			continue
+		}
+
+		same, err := sameContent(primary, secondary, h)
+		if err != nil {
+			return diff, err
+		}
+		if !same {
+			diff.ContentMismatch = append(diff.ContentMismatch, h)
+		}
+	}
+
+	return diff, nil
+}
+
+// listSet returns the names of all files of type t in be.
+func listSet(be restic.Backend, t restic.FileType, done <-chan struct{}) map[string]struct{} {
+	names := make(map[string]struct{})
+	for name := range be.List(t, done) {
+		names[name] = struct{}{}
+	}
+	return names
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sameContent returns true if the file at h has the same content in both
+// backends.
+func sameContent(primary, secondary restic.Backend, h restic.Handle) (bool, error) {
+	p, err := contentHash(primary, h)
+	if err != nil {
+		return false, err
+	}
+	s, err := contentHash(secondary, h)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(p, s), nil
+}
+
+// contentHash returns the SHA-256 hash of the file at h in be.
+func contentHash(be restic.Backend, h restic.Handle) ([]byte, error) {
+	rd, err := be.Load(h, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, rd)
+	if e := rd.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Load")
+	}
+
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,65 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestCompareMirror(t *testing.T) {
+	primary, cleanup := local.TestBackend(t)
+	defer cleanup()
+	secondary, cleanup2 := local.TestBackend(t)
+	defer cleanup2()
+
+	save := func(be *local.Local, h restic.Handle, data []byte) restic.Handle {
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		return h
+	}
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	save(primary, h, data)
+	save(secondary, h, data)
+
+	cfg := restic.Handle{Type: restic.ConfigFile}
+	save(primary, cfg, []byte("config"))
+	save(secondary, cfg, []byte("config"))
+
+	diff, err := local.CompareMirrorContent(primary, secondary, nil)
+	OK(t, err)
+	Assert(t, diff.Empty(), "unexpected differences %+v", diff)
+
+	onlyPrimary := save(primary, restic.Handle{Type: restic.IndexFile, Name: "only-primary"}, []byte("index"))
+	onlySecondary := save(secondary, restic.Handle{Type: restic.SnapshotFile, Name: "only-secondary"}, []byte("snapshot"))
+	size := restic.Handle{Type: restic.KeyFile, Name: "size"}
+	save(primary, size, []byte("key"))
+	save(secondary, size, []byte("longer key"))
+	content := restic.Handle{Type: restic.KeyFile, Name: "content"}
+	save(primary, content, []byte("aaaa"))
+	save(secondary, content, []byte("bbbb"))
+
+	// lock files are not compared
+	save(primary, restic.Handle{Type: restic.LockFile, Name: "lock"}, []byte("lock"))
+
+	diff, err = local.CompareMirror(primary, secondary, nil)
+	OK(t, err)
+	Equals(t, restic.MirrorDiff{
+		MissingOnPrimary:   []restic.Handle{onlySecondary},
+		MissingOnSecondary: []restic.Handle{onlyPrimary},
+		SizeMismatch:       []restic.Handle{size},
+	}, diff)
+
+	diff, err = local.CompareMirrorContent(primary, secondary, nil)
+	OK(t, err)
+	Equals(t, []restic.Handle{content}, diff.ContentMismatch)
+	Equals(t, []restic.Handle{size}, diff.SizeMismatch)
+
+	done := make(chan struct{})
+	close(done)
+	_, err = local.CompareMirror(primary, secondary, done)
+	Assert(t, err != nil, "canceled comparison did not return an error")
+}
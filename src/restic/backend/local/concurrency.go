@@ -0,0 +1,149 @@
+package local
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+const (
+	// DefaultConcurrency is returned by SuggestConcurrency if the storage
+	// could not be measured.
+	DefaultConcurrency = 2
+
+	// maxConcurrency is the highest concurrency tried.
+	maxConcurrency = 32
+
+	// concurrencyProbeFiles is the number of files read by the probe,
+	// concurrencyProbeSize their size.
+	concurrencyProbeFiles = 32
+	concurrencyProbeSize  = 16 * 1024
+
+	// concurrencyProbeLoads is the number of loads per concurrency level.
+	concurrencyProbeLoads = 256
+
+	// concurrencyProbeTimeout bounds the total time of the probe.
+	concurrencyProbeTimeout = 2 * time.Second
+
+	// concurrencyGain is the minimal relative improvement of the
+	// throughput for a higher concurrency to be worthwhile.
+	concurrencyGain = 1.1
+)
+
+// SuggestConcurrency measures how many files can be read in parallel from
+// the storage of the repository with increasing throughput. It writes a few
+// small probe files below Paths.Temp, loads them with doubling concurrency
+// until the throughput stops improving and returns the last concurrency
+// which improved it. The probe files are removed afterwards. The probe takes
+// at most a few seconds. If it fails, DefaultConcurrency is returned together
+// with the error.
+func (b *Local) SuggestConcurrency() (int, error) {
+	debug.Log("SuggestConcurrency %v", b.Path)
+
+	files, err := b.writeProbeFiles()
+	defer func() {
+		for _, fn := range files {
+			b.FS.Remove(fn)
+		}
+	}()
+	if err != nil {
+		return DefaultConcurrency, err
+	}
+
+	deadline := time.Now().Add(concurrencyProbeTimeout)
+	best, bestRate := 0, 0.0
+	for n := 1; n <= maxConcurrency && time.Now().Before(deadline); n *= 2 {
+		rate, err := b.probeLoads(files, n)
+		if err != nil {
+			return DefaultConcurrency, err
+		}
+		debug.Log("concurrency %d: %.0f loads per second", n, rate)
+
+		if best > 0 && rate < bestRate*concurrencyGain {
+			break
+		}
+		best, bestRate = n, rate
+	}
+
+	if best == 0 {
+		return DefaultConcurrency, nil
+	}
+	return best, nil
+}
+
+// writeProbeFiles creates the files read by probeLoads. The names of the
+// files created so far are returned even if an error occurs.
+func (b *Local) writeProbeFiles() (files []string, err error) {
+	tempdir := filepath.Join(b.Path, backend.Paths.Temp)
+	buf := make([]byte, concurrencyProbeSize)
+	for i := 0; i < concurrencyProbeFiles; i++ {
+		f, err := b.FS.TempFile(tempdir, "probe-")
+		if err != nil {
+			return files, errors.Wrap(err, "TempFile")
+		}
+		files = append(files, f.Name())
+
+		_, err = f.Write(buf)
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		if err != nil {
+			return files, errors.Wrap(err, "Write")
+		}
+	}
+
+	return files, nil
+}
+
+// probeLoads reads the files with n workers concurrencyProbeLoads times in
+// total and returns the number of loads per second.
+func (b *Local) probeLoads(files []string, n int) (float64, error) {
+	work := make(chan string)
+	errs := make(chan error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fn := range work {
+				f, err := b.FS.Open(fn)
+				if err == nil {
+					_, err = io.Copy(ioutil.Discard, f)
+					if e := f.Close(); err == nil {
+						err = e
+					}
+				}
+
+				if err != nil {
+					errs <- err
+					for range work {
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	for i := 0; i < concurrencyProbeLoads; i++ {
+		work <- files[i%len(files)]
+	}
+	close(work)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	select {
+	case err := <-errs:
+		return 0, errors.Wrap(err, "Load")
+	default:
+	}
+
+	return float64(concurrencyProbeLoads) / elapsed.Seconds(), nil
+}
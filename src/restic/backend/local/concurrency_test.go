@@ -0,0 +1,39 @@
+package local
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"restic/backend"
+	. "restic/test"
+)
+
+func TestSuggestConcurrency(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	n, err := be.SuggestConcurrency()
+	OK(t, err)
+	Assert(t, n >= 1 && n <= maxConcurrency, "implausible concurrency %d", n)
+
+	entries, err := ioutil.ReadDir(filepath.Join(be.Path, backend.Paths.Temp))
+	OK(t, err)
+	Equals(t, 0, len(entries))
+}
+
+func TestSuggestConcurrencyError(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	fsys := be.FS
+	be.FS = &fakeFS{FS: fsys, fail: failOp("Open", syscall.EIO)}
+	n, err := be.SuggestConcurrency()
+	Assert(t, err != nil, "expected error not returned")
+	Equals(t, DefaultConcurrency, n)
+
+	entries, err := ioutil.ReadDir(filepath.Join(be.Path, backend.Paths.Temp))
+	OK(t, err)
+	Equals(t, 0, len(entries))
+}
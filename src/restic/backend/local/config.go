@@ -1,7 +1,11 @@
 package local
 
 import (
+	"crypto"
+	"io"
+	"restic"
 	"strings"
+	"time"
 
 	"restic/errors"
 )
@@ -9,8 +13,255 @@ import (
 // Config holds all information needed to open a local repository.
 type Config struct {
 	Path string
+
+	// RetentionByType holds the minimum age for files of a type. Remove
+	// refuses to delete files which were modified more recently.
+	RetentionByType map[restic.FileType]time.Duration
+
+	// ChecksumCache enables caching the results of Verify, so that files
+	// which have not been modified since are not hashed again.
+	ChecksumCache bool
+
+	// RejectEmpty makes Save return ErrEmptyBlob instead of storing a file
+	// without content. All files restic writes to a repository are
+	// encrypted and therefore never empty, so an empty file is an error
+	// in the caller.
+	RejectEmpty bool
+
+	// OpTimeout is the maximum duration of a single file system operation,
+	// including fdatasync, fadvise and fallocate, after which ErrTimeout is
+	// returned. The blocked syscall is not interrupted, it may continue in
+	// the background and leak a file descriptor. Zero disables the timeout.
+	OpTimeout time.Duration
+
+	// MaxShardEntries limits the number of entries in a data subdirectory.
+	// When a subdirectory holds this many entries, Save stores new data
+	// files in a sub-shard, e.g. data/ab/c/abc..., instead of resharding
+	// the repository. The entry counts are cached after the first Save to
+	// each subdirectory. Load, Stat, Test and Remove then also look in the
+	// sub-shard, which costs another Stat for files which do not exist.
+	// It must remain set as long as sub-shards exist. Zero disables the
+	// limit.
+	MaxShardEntries int
+
+	// MigrationMode makes Load, Stat and Test also look for data files
+	// directly in the data directory, so that readers can continue while
+	// the files are moved into their subdirectories. It should only be
+	// enabled during such a migration.
+	MigrationMode bool
+
+	// SnapshotBuckets stores new snapshot files in subdirectories by date
+	// (snapshots/YYYY/MM/), which keeps the number of files per directory
+	// small. Snapshots in both layouts are always found.
+	SnapshotBuckets bool
+
+	// VerifyHash makes Save check that the data written for
+	// content-addressed files matches the name, otherwise ErrHashMismatch
+	// is returned and nothing is stored.
+	VerifyHash bool
+
+	// CRC32C makes Save store the CRC32C checksum of each file in a sidecar
+	// file below checksums/, which Scrub uses to detect corruption
+	// much faster than by computing the SHA-256 hash. CRC32C is not a
+	// cryptographic hash, it only catches accidental damage like bit rot.
+	CRC32C bool
+
+	// Hash selects the hash function whose hex-encoded digests are the names
+	// of content-addressed files, it is used by Verify and when checking
+	// data on Save. The repository sets it according to the format version
+	// of its config. The hash function must be available (linked into the
+	// binary). Zero selects SHA-256.
+	Hash crypto.Hash
+
+	// Pool makes Save record content-addressed files in a staging pool
+	// below pool/ by hard-linking them. When a file with the same name
+	// is saved again later, e.g. after it was removed by prune, it is
+	// linked from the pool instead of storing the new copy, after the data
+	// has passed the same checks as for other files. PrunePool removes the
+	// pool entries which are not used anymore.
+	Pool bool
+
+	// Footer makes Save append a footer of FooterSize bytes to each file,
+	// which records the file type and the length of the content. This
+	// allows recovery tools to identify stray files. Load and Stat strip
+	// the footer, files saved without one can still be read. It changes
+	// the bytes stored on disk, so it is disabled by default.
+	Footer bool
+
+	// ReadOnly declares that the repository is intentionally only read,
+	// e.g. from read-only media. Open then does not check whether files
+	// can be created.
+	ReadOnly bool
+
+	// MaxOpenFiles limits the number of files the backend keeps open at
+	// the same time, further opens block until a file is closed, or fail
+	// with ErrTooManyOpenFiles after a minute. The limit is also derived
+	// from RLIMIT_NOFILE minus OpenFilesReserve, the smaller one is used.
+	// Limits below two are raised to two, since some operations keep two
+	// files open. Zero means that only the derived limit applies.
+	MaxOpenFiles int
+
+	// OpenFilesReserve is the number of file descriptors left for the
+	// rest of the process when deriving the limit from RLIMIT_NOFILE.
+	// Zero selects a default of 64.
+	OpenFilesReserve int
+
+	// ParanoidVerify makes Save reopen each tempfile after it has been
+	// synced and closed, and compare the data read back (all of it for
+	// small files, the beginning otherwise) with the data written before
+	// the file is moved into place. This detects file systems which
+	// acknowledge writes that are lost, at the cost of additional reads.
+	ParanoidVerify bool
+
+	// NoReadOnly skips making new files read-only, which saves a Stat and
+	// a Chmod for each file saved and a Chmod for each file removed. Files
+	// are still never modified by the backend, but they are not protected
+	// against accidental changes by other programs.
+	NoReadOnly bool
+
+	// Sparse makes Save skip runs of zeroes instead of writing them, so
+	// that the file system stores them as holes. This saves space for data
+	// like disk images. It is only supported on Unix, files are written
+	// densely on other platforms.
+	Sparse bool
+
+	// DataSync makes Save flush tempfiles with fdatasync instead of fsync
+	// on Linux, which skips flushing metadata like the modification time.
+	// This is sufficient because the rename commits the metadata that
+	// matters. On other platforms, the file is synced as usual.
+	DataSync bool
+
+	// OpenFunc is called for each file the backend opens or creates,
+	// instead of opening it with FS, e.g. to wrap the files for tracking
+	// leaked file descriptors. The other file system operations are not
+	// affected. Nil opens the files with FS.
+	OpenFunc OpenFunc
+
+	// Naming translates the names of files to the names on disk, it allows
+	// accessing repositories written by other tools. Nil selects
+	// IdentityNaming.
+	Naming Naming
+
+	// ConfirmRemove makes Remove check that the file is gone afterwards and
+	// wait until it is. Some network file systems (e.g. NFS) still report
+	// a removed file for a short time, so that saving it again fails
+	// because it already exists.
+	ConfirmRemove bool
+
+	// ListDirDelay is the time List waits between reading two data
+	// subdirectories. This spreads the load on shared storage like NFS at
+	// the cost of a slower listing. Zero disables the delay.
+	ListDirDelay time.Duration
+
+	// CacheDir is the directory LoadCached stores copies of files in. If
+	// it is empty, cache/ below the repository is used.
+	CacheDir string
+
+	// CacheSize is the maximum number of bytes stored by LoadCached, the
+	// least recently used files are removed when it is exceeded. Zero
+	// means no limit.
+	CacheSize int64
+
+	// SnapshotCommands are run by SnapshotRepo, ListRepoSnapshots and
+	// RollbackRepo to manage file system snapshots of the repository.
+	SnapshotCommands SnapshotCommands
+
+	// AccessLog makes Save, Load, Stat, Test and Remove record each call in
+	// an append-only log below log/, which ReadAccessLog returns. The
+	// events are written in the background, when too many are pending, new
+	// ones are dropped.
+	AccessLog bool
+
+	// PermittedTypes restricts the file types the backend gives access to.
+	// Methods taking a handle or a file type, e.g. Save, Load, Stat, Test
+	// and Remove, return ErrTypeNotPermitted for other types without
+	// accessing the file system, and List and ListFrom return no names.
+	// An empty list permits all types.
+	PermittedTypes []restic.FileType
+
+	// ParityShards makes Save store Reed-Solomon parity for data files in
+	// a sidecar file below parity/, which Repair uses to restore a
+	// damaged file. The file is split into 32 parts, and the parity takes
+	// the size of ParityShards parts, e.g. about 3% of the file for one.
+	// Up to ParityShards damaged parts can be restored. Computing the
+	// parity reads the whole file into memory. Zero disables the parity.
+	ParityShards int
+
+	// SyncOnClose makes Close sync the access log, the checksum cache and
+	// the directories files were saved to since the last Close, after the
+	// pending events and checksums were written. The files themselves are
+	// synced by Save already, this makes the renames durable as well, so
+	// that everything saved before a clean Close survives a crash.
+	SyncOnClose bool
+
+	// DetectTruncation makes Load check the size of the opened file when a
+	// length is given, and return ErrTruncated if the file ends before
+	// offset+length. Otherwise, a truncated file yields less data than
+	// requested, which the caller may only notice much later.
+	DetectTruncation bool
+
+	// SkipExistCheck omits checking whether the file already exists before
+	// Save renames it into place, which saves a Stat for callers that have
+	// checked this already, e.g. with TestMany. An existing file is then
+	// replaced and OnExist is ignored. This is harmless for
+	// content-addressed files, which have the same content for the same
+	// name, but silently overwrites config, key and lock files.
+	SkipExistCheck bool
+
+	// ReadBufferSize is the initial size of the buffers LoadBuffer reuses.
+	// Buffers grow as needed for larger files. Zero selects
+	// DefaultReadBufferSize.
+	ReadBufferSize int
+
+	// TempPrefix is the prefix of the names of tempfiles created below
+	// Paths.Temp. Hosts sharing a repository can use HostTempPrefix so that
+	// CleanupTempFiles leaves tempfiles of other hosts alone. Empty
+	// selects "temp-".
+	TempPrefix string
+
+	// CopyBufferSize is the size of the buffer Save uses to copy the data
+	// to the tempfile. Zero selects DefaultCopyBufferSize.
+	CopyBufferSize int
+
+	// Journal makes Save record each file in journal/ before it is
+	// renamed into place, and remove the entry afterwards. Open then calls
+	// RecoverJournal to complete or discard saves interrupted by a crash.
+	Journal bool
+
+	// RepairSource is the backend Verify fetches a file from when the
+	// local copy does not match its name, e.g. the remote repository a
+	// local cache is kept for. The fetched data replaces the local file
+	// only if it matches the name. Each repair is recorded in the debug
+	// log and, if enabled, in the access log. Nil disables repairs.
+	RepairSource restic.Backend
+
+	// SaveHook is called by Save with the handle and the data to be
+	// saved, the reader it returns is stored instead. This allows wrapping
+	// the data, e.g. for compression or instrumentation. If it returns an
+	// error, nothing is saved. The data returned by the hook is what
+	// VerifyHash checks. Nil disables the hook.
+	SaveHook func(h restic.Handle, rd io.Reader) (io.Reader, error)
+
+	// OnExist selects what Save does when the file already exists.
+	OnExist OnExistPolicy
 }
 
+// OnExistPolicy is the behavior of Save when the file already exists.
+type OnExistPolicy int
+
+const (
+	// OnExistError makes Save return an error, this is the default.
+	OnExistError OnExistPolicy = iota
+
+	// OnExistSkip discards the new data and reports success. This is safe
+	// for content-addressed files, since the content is the same.
+	OnExistSkip
+
+	// OnExistOverwrite replaces the existing file, which is appropriate for
+	// mutable files like locks.
+	OnExistOverwrite
+)
+
 // ParseConfig parses a local backend config.
 func ParseConfig(cfg string) (interface{}, error) {
 	if !strings.HasPrefix(cfg, "local:") {
@@ -0,0 +1,40 @@
+package local
+
+import (
+	"os"
+	"time"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrRemoveUnconfirmed is returned by Remove if ConfirmRemove is set and the
+// file can still be found after it was removed.
+var ErrRemoveUnconfirmed = errors.New("removed file is still present")
+
+// removeConfirmRetries is the number of times confirmRemoved checks whether
+// the file is gone, waiting removeConfirmDelay (doubled each time) in
+// between.
+const removeConfirmRetries = 8
+
+const removeConfirmDelay = 5 * time.Millisecond
+
+// confirmRemoved waits until Stat reports that the file fn does not exist.
+func (b *Local) confirmRemoved(fn string) error {
+	delay := removeConfirmDelay
+	for i := 0; i < removeConfirmRetries; i++ {
+		_, err := b.FS.Stat(fn)
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "Stat")
+		}
+
+		debug.Log("%v still present after Remove, waiting %v", fn, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+
+	return errors.Wrap(ErrRemoveUnconfirmed, fn)
+}
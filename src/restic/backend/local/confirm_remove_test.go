@@ -0,0 +1,82 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"restic"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// lingeringFS reports removed files as present for the next n calls to Stat.
+type lingeringFS struct {
+	FS
+	n     int
+	calls int
+	fi    map[string]os.FileInfo
+}
+
+func (f *lingeringFS) Remove(name string) error {
+	fi, err := f.FS.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	if err = f.FS.Remove(name); err != nil {
+		return err
+	}
+
+	f.fi[name] = fi
+	return nil
+}
+
+func (f *lingeringFS) Stat(name string) (os.FileInfo, error) {
+	if fi, ok := f.fi[name]; ok {
+		f.calls++
+		if f.calls <= f.n {
+			return fi, nil
+		}
+		delete(f.fi, name)
+	}
+	return f.FS.Stat(name)
+}
+
+func TestConfirmRemove(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.ConfirmRemove = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	fsys := &lingeringFS{FS: be.FS, n: 1, fi: make(map[string]os.FileInfo)}
+	be.FS = fsys
+	OK(t, be.Remove(h))
+	Equals(t, 2, fsys.calls)
+
+	// the file can be saved again right away
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	// a file which does not disappear is reported
+	fsys = &lingeringFS{FS: fsys.FS, n: removeConfirmRetries, fi: make(map[string]os.FileInfo)}
+	be.FS = fsys
+	err := be.Remove(h)
+	Assert(t, errors.Cause(err) == ErrRemoveUnconfirmed, "expected ErrRemoveUnconfirmed, got %v", err)
+}
+
+func TestRemoveWithoutConfirm(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	fsys := &lingeringFS{FS: be.FS, n: 1, fi: make(map[string]os.FileInfo)}
+	be.FS = fsys
+	OK(t, be.Remove(h))
+	Equals(t, 0, fsys.calls)
+}
@@ -0,0 +1,49 @@
+package local
+
+import (
+	"sync"
+)
+
+// DefaultCopyBufferSize is the size of the buffer used to write tempfiles
+// if CopyBufferSize is not set. BenchmarkSaveLarge shows about 10% more
+// throughput than with the 32KiB used by io.Copy, and no gain for larger
+// buffers.
+const DefaultCopyBufferSize = 128 * 1024
+
+// copyBuffers holds a pool of buffers for each buffer size in use, so that
+// large buffers are not allocated for each save.
+var copyBuffers struct {
+	m     sync.Mutex
+	pools map[int]*sync.Pool
+}
+
+func copyBufferPool(size int) *sync.Pool {
+	copyBuffers.m.Lock()
+	defer copyBuffers.m.Unlock()
+
+	if copyBuffers.pools == nil {
+		copyBuffers.pools = make(map[int]*sync.Pool)
+	}
+
+	pool, ok := copyBuffers.pools[size]
+	if !ok {
+		pool = &sync.Pool{New: func() interface{} {
+			return make([]byte, size)
+		}}
+		copyBuffers.pools[size] = pool
+	}
+	return pool
+}
+
+// getCopyBuffer returns a buffer of the given size, or of the default size
+// for zero. It must be returned with putCopyBuffer.
+func getCopyBuffer(size int) []byte {
+	if size <= 0 {
+		size = DefaultCopyBufferSize
+	}
+	return copyBufferPool(size).Get().([]byte)
+}
+
+func putCopyBuffer(buf []byte) {
+	copyBufferPool(len(buf)).Put(buf)
+}
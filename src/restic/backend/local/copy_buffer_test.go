@@ -0,0 +1,74 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+// readSizes records the size of the buffers passed to Read.
+type readSizes struct {
+	rd    io.Reader
+	sizes map[int]int
+}
+
+func (r *readSizes) Read(p []byte) (int, error) {
+	r.sizes[len(p)]++
+	return r.rd.Read(p)
+}
+
+func TestCopyBufferSize(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 100000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	for _, size := range []int{0, 4096, 1 << 20} {
+		be.CopyBufferSize = size
+		rd := &readSizes{rd: bytes.NewReader(data), sizes: make(map[int]int)}
+		OK(t, be.Save(h, rd))
+		OK(t, be.Remove(h))
+
+		want := size
+		if want == 0 {
+			want = DefaultCopyBufferSize
+		}
+		Assert(t, len(rd.sizes) == 1 && rd.sizes[want] > 0,
+			"buffer size %d: reads with sizes %v", size, rd.sizes)
+	}
+
+	Equals(t, DefaultCopyBufferSize, len(getCopyBuffer(0)))
+}
+
+func BenchmarkSaveLarge(b *testing.B) {
+	data := Random(23, 64<<20)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	for _, size := range []int{4 << 10, 32 << 10, 128 << 10, 1 << 20, 4 << 20} {
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			be, cleanup := TestBackend(b)
+			defer cleanup()
+			be.CopyBufferSize = size
+
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := be.Save(h, struct{ io.Reader }{bytes.NewReader(data)})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				if err = be.Remove(h); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
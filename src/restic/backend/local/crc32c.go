@@ -0,0 +1,153 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"strconv"
+	"strings"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrCRCMismatch is returned by Scrub when the CRC32C checksum of a file does
+// not match the one recorded when it was saved.
+var ErrCRCMismatch = errors.New("CRC32C checksum mismatch")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func newCRC() hash.Hash32 {
+	return crc32.New(castagnoli)
+}
+
+// crcFile returns the path of the sidecar file holding the CRC32C checksum
+// for h.
+func (b *Local) crcFile(h restic.Handle) string {
+	return filepath.Join(b.Path, localPaths.Checksums, "crc32c", string(h.Type), h.Name)
+}
+
+// writeCRC stores sum in the sidecar file for h, the file is replaced
+// atomically.
+func (b *Local) writeCRC(h restic.Handle, sum uint32) error {
+	fn := b.crcFile(h)
+	if err := b.FS.MkdirAll(filepath.Dir(fn), backend.Modes.Dir); err != nil {
+		return errors.Wrap(err, "MkdirAll")
+	}
+
+	buf := []byte(fmt.Sprintf("%08x\n", sum))
+	tmpfile, _, err := b.copyToTempfile(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	if err = b.FS.Rename(tmpfile, fn); err != nil {
+		b.FS.Remove(tmpfile)
+		return errors.Wrap(err, "Rename")
+	}
+
+	return nil
+}
+
+// readCRC returns the checksum stored for h. ok is false if there is none.
+func (b *Local) readCRC(h restic.Handle) (sum uint32, ok bool, err error) {
+	f, err := b.FS.Open(b.crcFile(h))
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return 0, false, nil
+		}
+		return 0, false, errors.Wrap(err, "Open")
+	}
+
+	buf, err := ioutil.ReadAll(f)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "Read")
+	}
+
+	v, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 16, 32)
+	if err != nil {
+		debug.Log("invalid CRC32C sidecar for %v: %v", h, err)
+		return 0, false, nil
+	}
+
+	return uint32(v), true, nil
+}
+
+// removeCRC removes the sidecar file for h, errors are ignored.
+func (b *Local) removeCRC(h restic.Handle) {
+	if err := b.FS.Remove(b.crcFile(h)); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		debug.Log("unable to remove CRC32C sidecar for %v: %v", h, err)
+	}
+}
+
+// Scrub checks the file at h against the CRC32C checksum recorded by Save
+// when CRC32C is enabled. This is much faster than Verify and catches
+// accidental corruption, but it gives no cryptographic guarantee. If the
+// checksums differ for a content-addressed file, the file is verified with
+// Verify: ErrHashMismatch is returned if the content is really damaged,
+// otherwise the sidecar was wrong and is rewritten. For other files,
+// ErrCRCMismatch is returned. Content-addressed files without a recorded
+// checksum are verified with Verify, other files are not checked.
+func (b *Local) Scrub(h restic.Handle) error {
+	debug.Log("Scrub %v", h)
+	if err := h.Valid(); err != nil {
+		return err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return err
+	}
+
+	want, ok, err := b.readCRC(h)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if isContentAddressed(h.Type) {
+			return b.Verify(h)
+		}
+		debug.Log("no CRC32C recorded for %v", h)
+		return nil
+	}
+
+	f, size, err := b.openContent(h)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+
+	crc := newCRC()
+	_, err = io.Copy(crc, io.LimitReader(f, size))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return errors.Wrap(err, "Read")
+	}
+
+	sum := crc.Sum32()
+	if sum == want {
+		return nil
+	}
+
+	debug.Log("CRC32C mismatch for %v: %08x != %08x", h, sum, want)
+	if !isContentAddressed(h.Type) {
+		return errors.Wrapf(ErrCRCMismatch, "%v", h)
+	}
+
+	if err := b.Verify(h); err != nil {
+		return err
+	}
+
+	return b.writeCRC(h, sum)
+}
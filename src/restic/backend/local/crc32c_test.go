@@ -0,0 +1,115 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"restic"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// corrupt flips a byte in the file fn.
+func corrupt(t testing.TB, fn string) {
+	OK(t, os.Chmod(fn, 0600))
+	buf, err := ioutil.ReadFile(fn)
+	OK(t, err)
+	buf[len(buf)/2] ^= 0xff
+	OK(t, ioutil.WriteFile(fn, buf, 0600))
+}
+
+func TestScrub(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.CRC32C = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	_, err := os.Stat(be.crcFile(h))
+	OK(t, err)
+
+	// the fast path reads the file only once
+	fn := filename(be.Path, h.Type, h.Name)
+	opens := 0
+	be.FS = countOpens(be.FS, fn, &opens)
+	OK(t, be.Scrub(h))
+	Equals(t, 1, opens)
+
+	corrupt(t, fn)
+	err = be.Scrub(h)
+	Assert(t, errors.Cause(err) == ErrHashMismatch, "expected hash mismatch, got %v", err)
+
+	OK(t, be.Remove(h))
+	_, err = os.Stat(be.crcFile(h))
+	Assert(t, os.IsNotExist(err), "sidecar for %v not removed", h)
+}
+
+func TestScrubStaleSidecar(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.CRC32C = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	OK(t, be.writeCRC(h, 0))
+
+	// the content is fine, so the sidecar is repaired
+	OK(t, be.Scrub(h))
+	sum, ok, err := be.readCRC(h)
+	OK(t, err)
+	Assert(t, ok, "sidecar missing")
+	Assert(t, sum != 0, "sidecar was not rewritten")
+}
+
+func TestScrubMutable(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.CRC32C = true
+
+	h := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	w, err := be.SaveWriter(h)
+	OK(t, err)
+	_, err = w.Write(Random(5, 200))
+	OK(t, err)
+	OK(t, w.Close())
+	OK(t, be.Scrub(h))
+
+	corrupt(t, filename(be.Path, h.Type, h.Name))
+	err = be.Scrub(h)
+	Assert(t, errors.Cause(err) == ErrCRCMismatch, "expected CRC mismatch, got %v", err)
+
+	be.CRC32C = false
+	other := restic.Handle{Type: restic.LockFile, Name: "other"}
+	OK(t, be.Save(other, bytes.NewReader([]byte("foo"))))
+	OK(t, be.Scrub(other))
+}
+
+func benchmarkCheck(b *testing.B, check func(be *Local, h restic.Handle) error) {
+	be, cleanup := TestBackend(b)
+	defer cleanup()
+	be.CRC32C = true
+
+	data := Random(23, 8*1024*1024)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(b, be.Save(h, bytes.NewReader(data)))
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		OK(b, check(be, h))
+	}
+}
+
+func BenchmarkScrub(b *testing.B) {
+	benchmarkCheck(b, (*Local).Scrub)
+}
+
+func BenchmarkVerify(b *testing.B) {
+	benchmarkCheck(b, (*Local).Verify)
+}
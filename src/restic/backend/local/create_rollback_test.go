@@ -0,0 +1,71 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"restic/backend"
+	. "restic/test"
+)
+
+// failMkdirAll returns a function for fakeFS.fail which fails the nth
+// MkdirAll call.
+func failMkdirAll(n int) func(op, name string) error {
+	calls := 0
+	return func(op, name string) error {
+		if op != "MkdirAll" {
+			return nil
+		}
+		calls++
+		if calls == n {
+			return syscall.EACCES
+		}
+		return nil
+	}
+}
+
+func TestCreateRollback(t *testing.T) {
+	tempdir, err := ioutil.TempDir(TestTempDir, "restic-local-test-")
+	OK(t, err)
+	defer RemoveAll(t, tempdir)
+
+	// the repository directory and data are created, snapshots fails
+	dir := filepath.Join(tempdir, "repo")
+	_, err = create(Config{Path: dir}, &fakeFS{FS: defaultFS, fail: failMkdirAll(3)})
+	Assert(t, err != nil, "Create did not fail")
+
+	_, err = os.Stat(dir)
+	Assert(t, os.IsNotExist(err), "repository directory was not removed: %v", err)
+	_, err = os.Stat(tempdir)
+	OK(t, err)
+
+	// a second attempt succeeds
+	be, err := Create(Config{Path: dir})
+	OK(t, err)
+	OK(t, be.Close())
+}
+
+func TestCreateRollbackExisting(t *testing.T) {
+	tempdir, err := ioutil.TempDir(TestTempDir, "restic-local-test-")
+	OK(t, err)
+	defer RemoveAll(t, tempdir)
+
+	// directories which existed before are left alone
+	data := filepath.Join(tempdir, backend.Paths.Data)
+	OK(t, os.Mkdir(data, 0700))
+	OK(t, ioutil.WriteFile(filepath.Join(tempdir, "foo"), []byte("foo"), 0600))
+
+	_, err = create(Config{Path: tempdir}, &fakeFS{FS: defaultFS, fail: failMkdirAll(4)})
+	Assert(t, err != nil, "Create did not fail")
+
+	entries, err := ioutil.ReadDir(tempdir)
+	OK(t, err)
+	var names []string
+	for _, fi := range entries {
+		names = append(names, fi.Name())
+	}
+	Equals(t, []string{backend.Paths.Data, "foo"}, names)
+}
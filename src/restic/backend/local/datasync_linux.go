@@ -0,0 +1,12 @@
+package local
+
+import "syscall"
+
+// syncData flushes the data of f to disk with fdatasync.
+func syncData(f File) error {
+	return fdOp(f, "Fdatasync", func() error {
+		return retryEINTR("Fdatasync", func() error {
+			return syscall.Fdatasync(int(f.Fd()))
+		})
+	})
+}
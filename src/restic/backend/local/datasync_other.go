@@ -0,0 +1,9 @@
+// +build !linux
+
+package local
+
+// syncData flushes the data of f to disk. fdatasync is only used on Linux,
+// other platforms sync data and metadata.
+func syncData(f File) error {
+	return f.Sync()
+}
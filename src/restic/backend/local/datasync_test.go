@@ -0,0 +1,43 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestDataSync(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.DataSync = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	Equals(t, data, load(t, be, h, 0, 0))
+}
+
+func benchmarkSaveSync(b *testing.B, dataSync bool) {
+	be, cleanup := TestBackend(b)
+	defer cleanup()
+	be.DataSync = dataSync
+
+	data := Random(23, 512)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data[0], data[1], data[2] = byte(i), byte(i>>8), byte(i>>16)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(b, be.Save(h, bytes.NewReader(data)))
+	}
+}
+
+func BenchmarkSaveSmallFsync(b *testing.B) {
+	benchmarkSaveSync(b, false)
+}
+
+func BenchmarkSaveSmallFdatasync(b *testing.B) {
+	benchmarkSaveSync(b, true)
+}
@@ -0,0 +1,84 @@
+package local
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// Compression selects the decompressor used by LoadDecompressed.
+type Compression string
+
+// These are the supported compression algorithms.
+const (
+	CompressionGzip Compression = "gzip"
+	CompressionZlib Compression = "zlib"
+)
+
+var decompressors = map[Compression]func(io.Reader) (io.ReadCloser, error){
+	CompressionGzip: func(rd io.Reader) (io.ReadCloser, error) { return gzip.NewReader(rd) },
+	CompressionZlib: zlib.NewReader,
+}
+
+// decompressReader closes both the decompressor and the file.
+type decompressReader struct {
+	io.Reader
+	dec  io.Closer
+	file io.Closer
+}
+
+func (rd decompressReader) Close() error {
+	err := rd.dec.Close()
+	if e := rd.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// LoadDecompressed works like Load, but returns the content of the file
+// decompressed with algo. offset and length refer to the decompressed data.
+// Compressed streams do not allow random access, so for a non-zero offset the
+// file is decompressed from the start and the data before the offset is
+// discarded.
+func (b *Local) LoadDecompressed(h restic.Handle, algo Compression, length int, offset int64) (io.ReadCloser, error) {
+	debug.Log("LoadDecompressed %v (%v), length %v, offset %v", h, algo, length, offset)
+	newReader, ok := decompressors[algo]
+	if !ok {
+		return nil, errors.Errorf("unsupported compression %q", algo)
+	}
+
+	if offset < 0 {
+		return nil, errors.New("offset is negative")
+	}
+
+	f, err := b.Load(h, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := newReader(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "decompress")
+	}
+
+	rd := decompressReader{Reader: dec, dec: dec, file: f}
+
+	if offset > 0 {
+		if _, err = io.CopyN(ioutil.Discard, dec, offset); err != nil {
+			rd.Close()
+			return nil, errors.Wrap(err, "decompress")
+		}
+	}
+
+	if length > 0 {
+		rd.Reader = io.LimitReader(dec, int64(length))
+	}
+
+	return rd, nil
+}
@@ -0,0 +1,60 @@
+package local_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func loadDecompressed(t testing.TB, be *local.Local, h restic.Handle, algo local.Compression, length int, offset int64) []byte {
+	rd, err := be.LoadDecompressed(h, algo, length, offset)
+	OK(t, err)
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	return buf
+}
+
+func TestLoadDecompressed(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := bytes.Repeat(Random(23, 1000), 20)
+
+	writers := map[local.Compression]func(io.Writer) io.WriteCloser{
+		local.CompressionGzip: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		local.CompressionZlib: func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	}
+
+	for algo, newWriter := range writers {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		_, err := w.Write(data)
+		OK(t, err)
+		OK(t, w.Close())
+
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(buf.Bytes()).String()}
+		OK(t, be.Save(h, bytes.NewReader(buf.Bytes())))
+
+		Equals(t, data, loadDecompressed(t, be, h, algo, 0, 0))
+		Equals(t, data[:100], loadDecompressed(t, be, h, algo, 100, 0))
+		Equals(t, data[5000:5100], loadDecompressed(t, be, h, algo, 100, 5000))
+		Equals(t, data[19000:], loadDecompressed(t, be, h, algo, 0, 19000))
+
+		_, err = be.LoadDecompressed(h, "lz4", 0, 0)
+		Assert(t, err != nil, "unsupported compression accepted")
+	}
+
+	// uncompressed data is rejected
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	_, err := be.LoadDecompressed(h, local.CompressionGzip, 0, 0)
+	Assert(t, err != nil, "uncompressed data accepted")
+}
@@ -0,0 +1,80 @@
+package local
+
+import (
+	"path/filepath"
+	"restic"
+
+	"restic/debug"
+)
+
+// DeleteStats reports the files removed by DeleteReport.
+type DeleteStats struct {
+	Files map[restic.FileType]int
+	Bytes map[restic.FileType]int64
+}
+
+// Total returns the number of files and bytes removed of all types.
+func (s DeleteStats) Total() (files int, bytes int64) {
+	for t, n := range s.Files {
+		files += n
+		bytes += s.Bytes[t]
+	}
+	return files, bytes
+}
+
+// DeleteReport works like Delete and returns the number and size of the
+// files of each type which were removed. The files are counted before the
+// repository is removed, files which cannot be counted are still removed.
+// Other files, e.g. in the temp directory, are not counted.
+func (b *Local) DeleteReport() (DeleteStats, error) {
+	debug.Log("DeleteReport()")
+	stats := DeleteStats{
+		Files: make(map[restic.FileType]int),
+		Bytes: make(map[restic.FileType]int64),
+	}
+
+	if err := b.checkDelete(); err != nil {
+		return stats, err
+	}
+
+	for _, t := range fileTypes {
+		if t == restic.ConfigFile {
+			if fi, err := b.FS.Stat(filename(b.Path, t, "")); err == nil {
+				stats.Files[t]++
+				stats.Bytes[t] += fi.Size()
+			}
+			continue
+		}
+
+		files, size := b.countFiles(dirname(b.Path, t, ""))
+		stats.Files[t] += files
+		stats.Bytes[t] += size
+	}
+
+	b.dirs.reset()
+	return stats, b.FS.RemoveAll(b.Path)
+}
+
+// countFiles returns the number and size of all files below dir, including
+// subdirectories. Directories which cannot be read are skipped.
+func (b *Local) countFiles(dir string) (files int, size int64) {
+	entries, err := readdir(b.FS, dir)
+	if err != nil {
+		debug.Log("unable to read %v: %v", dir, err)
+		return 0, 0
+	}
+
+	for _, fi := range entries {
+		switch {
+		case fi.IsDir():
+			n, s := b.countFiles(filepath.Join(dir, fi.Name()))
+			files += n
+			size += s
+		case isFile(fi):
+			files++
+			size += fi.Size()
+		}
+	}
+
+	return files, size
+}
@@ -0,0 +1,91 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"syscall"
+	"testing"
+
+	"restic/backend"
+	. "restic/test"
+)
+
+// deletableBackend returns a new backend, the cleanup function only removes
+// it if it still exists.
+func deletableBackend(t testing.TB) (*Local, func()) {
+	dir, err := ioutil.TempDir(TestTempDir, "restic-test-local-")
+	OK(t, err)
+
+	be, err := Create(Config{Path: dir})
+	OK(t, err)
+
+	return be, func() {
+		if _, err := os.Stat(dir); err == nil {
+			RemoveAll(t, dir)
+		}
+	}
+}
+
+func TestDeleteReport(t *testing.T) {
+	be, cleanup := deletableBackend(t)
+	defer cleanup()
+
+	OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, bytes.NewReader([]byte("config"))))
+	var dataBytes int64
+	for i := 0; i < 10; i++ {
+		_, data := saveData(t, be, i, 100*(i+1))
+		dataBytes += int64(len(data))
+	}
+	OK(t, be.Save(restic.Handle{Type: restic.LockFile, Name: "lock"}, bytes.NewReader([]byte("lock"))))
+	OK(t, be.Save(restic.Handle{Type: restic.KeyFile, Name: "key1"}, bytes.NewReader([]byte("key1"))))
+	OK(t, be.Save(restic.Handle{Type: restic.KeyFile, Name: "key2"}, bytes.NewReader([]byte("key22"))))
+
+	stats, err := be.DeleteReport()
+	OK(t, err)
+
+	Equals(t, map[restic.FileType]int{
+		restic.ConfigFile:   1,
+		restic.DataFile:     10,
+		restic.IndexFile:    0,
+		restic.KeyFile:      2,
+		restic.LockFile:     1,
+		restic.SnapshotFile: 0,
+	}, stats.Files)
+	Equals(t, int64(6), stats.Bytes[restic.ConfigFile])
+	Equals(t, dataBytes, stats.Bytes[restic.DataFile])
+	Equals(t, int64(9), stats.Bytes[restic.KeyFile])
+
+	files, size := stats.Total()
+	Equals(t, 14, files)
+	Equals(t, dataBytes+19, size)
+
+	_, err = os.Stat(be.Path)
+	Assert(t, os.IsNotExist(err), "repository was not removed")
+}
+
+func TestDeleteReportUnreadable(t *testing.T) {
+	be, cleanup := deletableBackend(t)
+	defer cleanup()
+
+	saveData(t, be, 1, 100)
+	h, _ := saveData(t, be, 2, 100)
+
+	// a shard which cannot be counted is removed anyway
+	shard := filepath.Dir(filename(be.Path, h.Type, h.Name))
+	be.FS = &fakeFS{FS: defaultFS, fail: func(op, name string) error {
+		if op == "Open" && name == shard {
+			return syscall.EACCES
+		}
+		return nil
+	}}
+
+	stats, err := be.DeleteReport()
+	OK(t, err)
+	Assert(t, stats.Files[restic.DataFile] < 2, "unreadable shard was counted")
+
+	_, err = os.Stat(filepath.Join(be.Path, backend.Paths.Data))
+	Assert(t, os.IsNotExist(err), "repository was not removed")
+}
@@ -0,0 +1,114 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrConfirmationRequired is returned by DeleteTypes when asked to remove the
+// config or data files without confirmation.
+var ErrConfirmationRequired = errors.New("removing config or data files requires confirmation")
+
+// DeleteTypes removes all files of the given types together with their
+// sidecars, the rest of the repository is left intact. The directories for
+// the types are recreated empty, so the repository can still be opened.
+// Removing the config or the data files is refused with
+// ErrConfirmationRequired unless confirm is true. Like Delete, DeleteTypes
+// is refused for a protected repository, and like Remove, nothing is removed
+// if a file is younger than the retention configured for its type.
+func (b *Local) DeleteTypes(types []restic.FileType, confirm bool) error {
+	debug.Log("DeleteTypes %v, confirm %v", types, confirm)
+	for _, t := range types {
+		if err := (restic.Handle{Type: t, Name: "x"}).Valid(); err != nil {
+			return err
+		}
+
+		if err := b.checkPermitted(t); err != nil {
+			return err
+		}
+
+		if !confirm && (t == restic.ConfigFile || t == restic.DataFile) {
+			return errors.Wrapf(ErrConfirmationRequired, "type %v", t)
+		}
+	}
+
+	if err := b.checkDelete(); err != nil {
+		return err
+	}
+
+	for _, t := range types {
+		fileInfos, err := b.listFileInfos(t)
+		if err != nil {
+			return err
+		}
+
+		for _, fi := range fileInfos {
+			if err := b.checkAge(restic.Handle{Type: t, Name: fi.Name()}, fi); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, t := range types {
+		for _, name := range b.listPacked(t) {
+			if err := b.removePacked(restic.Handle{Type: t, Name: name}); err != nil {
+				return err
+			}
+		}
+
+		if err := b.removeSidecars(t); err != nil {
+			return err
+		}
+
+		if t == restic.ConfigFile {
+			err := b.FS.Remove(filename(b.Path, t, ""))
+			if err != nil && !os.IsNotExist(errors.Cause(err)) {
+				return errors.Wrap(err, "Remove")
+			}
+			continue
+		}
+
+		dir := dirname(b.Path, t, "")
+		b.dirs.reset()
+		if err := b.FS.RemoveAll(dir); err != nil {
+			return errors.Wrap(err, "RemoveAll")
+		}
+
+		if err := b.FS.MkdirAll(dir, backend.Modes.Dir); err != nil {
+			return errors.Wrap(err, "MkdirAll")
+		}
+
+		if t == restic.SnapshotFile {
+			b.buckets.reset()
+		}
+	}
+
+	return nil
+}
+
+// removeSidecars removes the CRC32C checksums and the metadata of all files
+// of type t, and for data files the offset tables and the parity.
+func (b *Local) removeSidecars(t restic.FileType) error {
+	dirs := []string{
+		filepath.Join(b.Path, localPaths.Checksums, "crc32c", string(t)),
+		filepath.Join(b.Path, localPaths.Meta, string(t)),
+	}
+	if t == restic.DataFile {
+		dirs = append(dirs,
+			filepath.Join(b.Path, localPaths.Offsets),
+			filepath.Join(b.Path, localPaths.Parity))
+	}
+
+	for _, dir := range dirs {
+		if err := b.FS.RemoveAll(dir); err != nil {
+			return errors.Wrap(err, "RemoveAll")
+		}
+	}
+
+	return nil
+}
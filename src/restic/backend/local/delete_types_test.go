@@ -0,0 +1,92 @@
+package local_test
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+	"time"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func countFiles(t testing.TB, be restic.Backend, tpe restic.FileType) int {
+	n := 0
+	for range be.List(tpe, nil) {
+		n++
+	}
+	return n
+}
+
+func TestDeleteTypes(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, strings.NewReader("config")))
+	saveRandom(t, be, restic.DataFile, 5)
+	saveRandom(t, be, restic.LockFile, 3)
+	saveRandom(t, be, restic.SnapshotFile, 2)
+
+	OK(t, be.DeleteTypes([]restic.FileType{restic.LockFile}, false))
+	Equals(t, 0, countFiles(t, be, restic.LockFile))
+	Equals(t, 5, countFiles(t, be, restic.DataFile))
+	Equals(t, 2, countFiles(t, be, restic.SnapshotFile))
+
+	// the repository can still be opened and used
+	be2, err := local.Open(local.Config{Path: be.Location()})
+	OK(t, err)
+	saveRandom(t, be2, restic.LockFile, 1)
+	Equals(t, 1, countFiles(t, be2, restic.LockFile))
+
+	for _, tpe := range []restic.FileType{restic.ConfigFile, restic.DataFile} {
+		err = be.DeleteTypes([]restic.FileType{restic.SnapshotFile, tpe}, false)
+		Assert(t, errors.Cause(err) == local.ErrConfirmationRequired,
+			"expected ErrConfirmationRequired for %v, got %v", tpe, err)
+	}
+	Equals(t, 5, countFiles(t, be, restic.DataFile))
+	Equals(t, 2, countFiles(t, be, restic.SnapshotFile))
+
+	OK(t, be.DeleteTypes([]restic.FileType{restic.DataFile, restic.ConfigFile}, true))
+	Equals(t, 0, countFiles(t, be, restic.DataFile))
+	ok, err := be.Test(restic.Handle{Type: restic.ConfigFile})
+	OK(t, err)
+	Assert(t, !ok, "config was not removed")
+	Equals(t, 2, countFiles(t, be, restic.SnapshotFile))
+}
+
+func TestDeleteTypesChecks(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	be.CRC32C = true
+	OK(t, be.PackSmallBlobs(50))
+
+	locks := saveRandom(t, be, restic.LockFile, 3)
+	OK(t, be.SetMeta(locks[0], map[string]string{"host": "foo"}))
+
+	OK(t, be.Protect())
+	err := be.DeleteTypes([]restic.FileType{restic.LockFile}, false)
+	Assert(t, errors.Cause(err) == local.ErrProtected, "expected ErrProtected, got %v", err)
+	OK(t, be.Unprotect())
+
+	be.RetentionByType = map[restic.FileType]time.Duration{restic.LockFile: time.Hour}
+	err = be.DeleteTypes([]restic.FileType{restic.LockFile}, false)
+	Assert(t, errors.Cause(err) == local.ErrRetention, "expected ErrRetention, got %v", err)
+	Equals(t, 3, countFiles(t, be, restic.LockFile))
+	be.RetentionByType = nil
+
+	OK(t, be.DeleteTypes([]restic.FileType{restic.LockFile}, false))
+	Equals(t, 0, countFiles(t, be, restic.LockFile))
+
+	for _, dir := range []string{"checksums/crc32c/lock", "meta/lock"} {
+		_, err := os.Stat(filepath.Join(be.Location(), filepath.FromSlash(dir)))
+		Assert(t, os.IsNotExist(err), "sidecar directory %v was not removed", dir)
+	}
+
+	// new files of the type and their sidecars are saved as usual
+	lock := saveRandom(t, be, restic.LockFile, 1)[0]
+	OK(t, be.SetMeta(lock, map[string]string{"host": "bar"}))
+	OK(t, be.Scrub(lock))
+}
@@ -0,0 +1,87 @@
+package local
+
+import (
+	"os"
+	"restic"
+	"sort"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrListIncomplete is returned by Diff together with a partial result
+// when one of the backends could not be listed completely.
+var ErrListIncomplete = errors.New("listing is incomplete")
+
+// Diff lists the files of type t in this and the other backend and returns
+// the sorted names which are only found here, only in other, and in both.
+// The lists are compared by a merge of the sorted names. If listing one of
+// the backends fails or done is closed, the partition of the names listed so
+// far is returned with an error wrapping ErrListIncomplete. Listing errors
+// are only detected for local backends, List of other backends does not
+// report them.
+func (b *Local) Diff(other restic.Backend, t restic.FileType, done <-chan struct{}) (onlyHere, onlyThere, both []string, err error) {
+	debug.Log("Diff %v with %v", t, other.Location())
+	if err := b.checkPermitted(t); err != nil {
+		return nil, nil, nil, err
+	}
+
+	here, errHere := listNames(b, t, done)
+	there, errThere := listNames(other, t, done)
+
+	i, j := 0, 0
+	for i < len(here) && j < len(there) {
+		switch {
+		case here[i] < there[j]:
+			onlyHere = append(onlyHere, here[i])
+			i++
+		case here[i] > there[j]:
+			onlyThere = append(onlyThere, there[j])
+			j++
+		default:
+			both = append(both, here[i])
+			i++
+			j++
+		}
+	}
+	onlyHere = append(onlyHere, here[i:]...)
+	onlyThere = append(onlyThere, there[j:]...)
+
+	switch {
+	case errHere != nil:
+		err = errors.Wrapf(ErrListIncomplete, "%v: %v", b.Location(), errHere)
+	case errThere != nil:
+		err = errors.Wrapf(ErrListIncomplete, "%v: %v", other.Location(), errThere)
+	}
+
+	return onlyHere, onlyThere, both, err
+}
+
+// listNames returns the sorted names of the files of type t in be. Errors
+// are only returned for local backends, and when done is closed.
+func listNames(be restic.Backend, t restic.FileType, done <-chan struct{}) ([]string, error) {
+	var names []string
+	var err error
+
+	if l, ok := be.(*Local); ok {
+		var fileInfos []os.FileInfo
+		fileInfos, err = l.listFileInfos(t)
+		for _, fi := range fileInfos {
+			names = append(names, fi.Name())
+		}
+		names = l.decodeNames(names)
+	} else {
+		for name := range be.List(t, done) {
+			names = append(names, name)
+		}
+	}
+
+	select {
+	case <-done:
+		err = errors.New("Diff canceled")
+	default:
+	}
+
+	sort.Strings(names)
+	return names, err
+}
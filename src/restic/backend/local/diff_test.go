@@ -0,0 +1,78 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+	"testing"
+
+	"restic/backend"
+	"restic/errors"
+	. "restic/test"
+)
+
+func saveLocks(t testing.TB, be *Local, names ...string) {
+	for _, name := range names {
+		OK(t, be.Save(restic.Handle{Type: restic.LockFile, Name: name}, bytes.NewReader([]byte(name))))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	other, cleanup2 := TestBackend(t)
+	defer cleanup2()
+
+	saveLocks(t, be, "a", "c", "d", "f")
+	saveLocks(t, other, "b", "c", "e", "f", "g")
+
+	onlyHere, onlyThere, both, err := be.Diff(other, restic.LockFile, nil)
+	OK(t, err)
+	Equals(t, []string{"a", "d"}, onlyHere)
+	Equals(t, []string{"b", "e", "g"}, onlyThere)
+	Equals(t, []string{"c", "f"}, both)
+
+	// disjoint sets
+	onlyHere, onlyThere, both, err = be.Diff(other, restic.KeyFile, nil)
+	OK(t, err)
+	Equals(t, 0, len(onlyHere)+len(onlyThere)+len(both))
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		h, _ := saveData(t, be, i, 100)
+		want = append(want, h.Name)
+	}
+	sort.Strings(want)
+
+	onlyHere, onlyThere, both, err = be.Diff(other, restic.DataFile, nil)
+	OK(t, err)
+	Equals(t, want, onlyHere)
+	Equals(t, 0, len(onlyThere)+len(both))
+
+	onlyHere, onlyThere, both, err = other.Diff(be, restic.DataFile, nil)
+	OK(t, err)
+	Equals(t, want, onlyThere)
+	Equals(t, 0, len(onlyHere)+len(both))
+}
+
+func TestDiffIncomplete(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	other, cleanup2 := TestBackend(t)
+	defer cleanup2()
+
+	saveLocks(t, be, "a", "b")
+	OK(t, os.RemoveAll(filepath.Join(other.Path, backend.Paths.Locks)))
+
+	onlyHere, onlyThere, both, err := be.Diff(other, restic.LockFile, nil)
+	Assert(t, errors.Cause(err) == ErrListIncomplete, "expected ErrListIncomplete, got %v", err)
+	Equals(t, []string{"a", "b"}, onlyHere)
+	Equals(t, 0, len(onlyThere)+len(both))
+
+	done := make(chan struct{})
+	close(done)
+	_, _, _, err = be.Diff(other, restic.KeyFile, done)
+	Assert(t, errors.Cause(err) == ErrListIncomplete, "expected ErrListIncomplete, got %v", err)
+}
@@ -0,0 +1,144 @@
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// fileTypes contains all file types stored in the repository.
+var fileTypes = []restic.FileType{
+	restic.ConfigFile,
+	restic.DataFile,
+	restic.IndexFile,
+	restic.KeyFile,
+	restic.LockFile,
+	restic.SnapshotFile,
+}
+
+// listFileInfos returns the os.FileInfo of all files of type t, including
+// the files in all subdirectories for data, in the date buckets for
+// snapshots and in the containers of PackSmallBlobs. In contrast to List,
+// errors reading a subdirectory are returned.
+func (b *Local) listFileInfos(t restic.FileType) ([]os.FileInfo, error) {
+	if t == restic.ConfigFile {
+		fi, err := b.FS.Stat(filename(b.Path, t, ""))
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Stat")
+		}
+		return []os.FileInfo{fi}, nil
+	}
+
+	dir := dirname(b.Path, t, "")
+	entries, err := readdir(b.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileInfos []os.FileInfo
+	for _, fi := range entries {
+		if t == restic.DataFile && fi.IsDir() {
+			subentries, err := readdir(b.FS, filepath.Join(dir, fi.Name()))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, subfi := range subentries {
+				if isFile(subfi) {
+					fileInfos = append(fileInfos, subfi)
+					continue
+				}
+
+				if !subfi.IsDir() {
+					continue
+				}
+
+				// files in a sub-shard
+				files, err := readdir(b.FS, filepath.Join(dir, fi.Name(), subfi.Name()))
+				if err != nil {
+					return nil, err
+				}
+				for _, f := range files {
+					if isFile(f) {
+						fileInfos = append(fileInfos, f)
+					}
+				}
+			}
+			continue
+		}
+
+		if t != restic.DataFile && isFile(fi) {
+			fileInfos = append(fileInfos, fi)
+		}
+	}
+
+	if t == restic.SnapshotFile && b.bucketed() {
+		for _, bucket := range b.snapshotBucketDirs() {
+			entries, err := readdir(b.FS, bucket)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, fi := range entries {
+				if isFile(fi) {
+					fileInfos = append(fileInfos, fi)
+				}
+			}
+		}
+	}
+
+	packed, err := b.packedFileInfos(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(fileInfos, packed...), nil
+}
+
+// byName sorts file infos by name.
+type byName []os.FileInfo
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// StateDigest returns a hash over the names and sizes of all files in the
+// repository. Two repositories containing the same set of files with equal
+// sizes produce the same digest, regardless of the order in which the file
+// system lists the files. File contents are not read. If done is closed, an
+// error is returned.
+func (b *Local) StateDigest(done <-chan struct{}) (string, error) {
+	debug.Log("StateDigest")
+	hash := sha256.New()
+
+	for _, t := range fileTypes {
+		select {
+		case <-done:
+			return "", errors.New("StateDigest canceled")
+		default:
+		}
+
+		fileInfos, err := b.listFileInfos(t)
+		if err != nil {
+			return "", err
+		}
+
+		sort.Sort(byName(fileInfos))
+
+		for _, fi := range fileInfos {
+			fmt.Fprintf(hash, "%s %s %d\n", t, fi.Name(), fi.Size())
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,60 @@
+package local_test
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestStateDigest(t *testing.T) {
+	be1, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	be2, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	// save the files in different order
+	handles := saveRandom(t, be1, restic.DataFile, 10)
+	for i := len(handles) - 1; i >= 0; i-- {
+		rd, err := be1.Load(handles[i], 0, 0)
+		OK(t, err)
+		OK(t, be2.Save(handles[i], rd))
+		OK(t, rd.Close())
+	}
+
+	for _, be := range []*local.Local{be1, be2} {
+		OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, strings.NewReader("config")))
+		saveRandom(t, be, restic.SnapshotFile, 2)
+	}
+
+	digest1, err := be1.StateDigest(nil)
+	OK(t, err)
+	digest2, err := be2.StateDigest(nil)
+	OK(t, err)
+	Equals(t, digest1, digest2)
+
+	// resizing a file changes the digest
+	fn := filepath.Join(be2.Location(), "data", handles[0].Name[:2], handles[0].Name)
+	OK(t, os.Chmod(fn, 0600))
+	OK(t, os.Truncate(fn, 5))
+
+	digest3, err := be2.StateDigest(nil)
+	OK(t, err)
+	Assert(t, digest3 != digest1, "digest did not change after resizing a file")
+
+	// removing a file changes the digest
+	OK(t, be1.Remove(handles[0]))
+	digest4, err := be1.StateDigest(nil)
+	OK(t, err)
+	Assert(t, digest4 != digest1 && digest4 != digest3, "digest did not change after removing a file")
+
+	done := make(chan struct{})
+	close(done)
+	_, err = be1.StateDigest(done)
+	Assert(t, err != nil, "canceled StateDigest did not return an error")
+}
@@ -0,0 +1,149 @@
+package local
+
+import (
+	"os"
+	"syscall"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// maxEINTRRetries is the number of times an operation interrupted by a
+// signal is retried before the error is returned.
+const maxEINTRRetries = 10
+
+// isEINTR returns true if err reports an interrupted system call.
+func isEINTR(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case *os.PathError:
+		return e.Err == syscall.EINTR
+	case *os.LinkError:
+		return e.Err == syscall.EINTR
+	case *os.SyscallError:
+		return e.Err == syscall.EINTR
+	default:
+		return e == syscall.EINTR
+	}
+}
+
+// retryEINTR runs fn until it returns an error other than EINTR, at most
+// maxEINTRRetries+1 times.
+func retryEINTR(op string, fn func() error) (err error) {
+	for i := 0; i <= maxEINTRRetries; i++ {
+		err = fn()
+		if !isEINTR(err) {
+			return err
+		}
+		debug.Log("%v interrupted, retrying: %v", op, err)
+	}
+	return err
+}
+
+// eintrFS retries operations of the underlying FS which fail because the
+// system call was interrupted by a signal. Older Go runtimes and some cgo
+// paths return EINTR to the caller instead of restarting the call.
+type eintrFS struct {
+	FS
+}
+
+func (f eintrFS) openFile(op string, open func() (File, error)) (File, error) {
+	var file File
+	err := retryEINTR(op, func() (err error) {
+		file, err = open()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eintrFile{File: file}, nil
+}
+
+func (f eintrFS) Open(name string) (File, error) {
+	return f.openFile("Open", func() (File, error) { return f.FS.Open(name) })
+}
+
+func (f eintrFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return f.openFile("OpenFile", func() (File, error) { return f.FS.OpenFile(name, flag, perm) })
+}
+
+func (f eintrFS) TempFile(dir, prefix string) (File, error) {
+	return f.openFile("TempFile", func() (File, error) { return f.FS.TempFile(dir, prefix) })
+}
+
+func (f eintrFS) Stat(name string) (fi os.FileInfo, err error) {
+	err = retryEINTR("Stat", func() (err error) {
+		fi, err = f.FS.Stat(name)
+		return err
+	})
+	return fi, err
+}
+
+func (f eintrFS) Lstat(name string) (fi os.FileInfo, err error) {
+	err = retryEINTR("Lstat", func() (err error) {
+		fi, err = f.FS.Lstat(name)
+		return err
+	})
+	return fi, err
+}
+
+func (f eintrFS) Chmod(name string, mode os.FileMode) error {
+	return retryEINTR("Chmod", func() error { return f.FS.Chmod(name, mode) })
+}
+
+func (f eintrFS) MkdirAll(path string, perm os.FileMode) error {
+	return retryEINTR("MkdirAll", func() error { return f.FS.MkdirAll(path, perm) })
+}
+
+func (f eintrFS) Rename(oldpath, newpath string) error {
+	return retryEINTR("Rename", func() error { return f.FS.Rename(oldpath, newpath) })
+}
+
+func (f eintrFS) Link(oldname, newname string) error {
+	return retryEINTR("Link", func() error { return f.FS.Link(oldname, newname) })
+}
+
+func (f eintrFS) Remove(name string) error {
+	return retryEINTR("Remove", func() error { return f.FS.Remove(name) })
+}
+
+func (f eintrFS) RemoveAll(path string) error {
+	return retryEINTR("RemoveAll", func() error { return f.FS.RemoveAll(path) })
+}
+
+// eintrFile retries the operations on a file opened by eintrFS.
+type eintrFile struct {
+	File
+}
+
+func (f eintrFile) Read(p []byte) (n int, err error) {
+	err = retryEINTR("Read", func() (err error) {
+		n, err = f.File.Read(p)
+		if n > 0 && isEINTR(err) {
+			// return the data read so far, the caller reads again
+			err = nil
+		}
+		return err
+	})
+	return n, err
+}
+
+func (f eintrFile) Write(p []byte) (n int, err error) {
+	err = retryEINTR("Write", func() error {
+		m, err := f.File.Write(p[n:])
+		n += m
+		return err
+	})
+	return n, err
+}
+
+func (f eintrFile) Seek(offset int64, whence int) (n int64, err error) {
+	err = retryEINTR("Seek", func() (err error) {
+		n, err = f.File.Seek(offset, whence)
+		return err
+	})
+	return n, err
+}
+
+func (f eintrFile) Sync() error {
+	return retryEINTR("Sync", f.File.Sync)
+}
@@ -0,0 +1,83 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"restic"
+	"syscall"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// interrupt returns a function for fakeFS.fail which returns EINTR for the
+// first n calls of each operation.
+func interrupt(n int) func(op, name string) error {
+	calls := make(map[string]int)
+	return func(op, name string) error {
+		calls[op]++
+		if calls[op] <= n {
+			return syscall.EINTR
+		}
+		return nil
+	}
+}
+
+func TestEINTR(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	fsys := defaultFS
+	be.FS = eintrFS{&fakeFS{FS: fsys, fail: interrupt(3)}}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	be.FS = eintrFS{&fakeFS{FS: fsys, fail: interrupt(3)}}
+	fi, err := be.Stat(h)
+	OK(t, err)
+	Equals(t, int64(len(data)), fi.Size)
+
+	be.FS = eintrFS{&fakeFS{FS: fsys, fail: interrupt(3)}}
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, ok, "file %v not found", h)
+
+	be.FS = eintrFS{&fakeFS{FS: fsys, fail: interrupt(3)}}
+	rd, err := be.Load(h, 100, 10)
+	OK(t, err)
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	Equals(t, data[10:110], buf)
+
+	be.FS = eintrFS{&fakeFS{FS: fsys, fail: interrupt(3)}}
+	OK(t, be.Remove(h))
+}
+
+func TestEINTRRetryLimit(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	be.FS = eintrFS{&fakeFS{FS: defaultFS, fail: interrupt(maxEINTRRetries + 1)}}
+	err := be.Save(h, bytes.NewReader(data))
+	Assert(t, isEINTR(err), "expected EINTR after exceeding the retry limit, got %v", err)
+
+	// other errors are not retried
+	calls := 0
+	be.FS = eintrFS{&fakeFS{FS: defaultFS, fail: func(op, name string) error {
+		if op == "Stat" {
+			calls++
+			return syscall.EIO
+		}
+		return nil
+	}}}
+	_, err = be.Stat(h)
+	Assert(t, err != nil && !isEINTR(errors.Cause(err)), "expected EIO, got %v", err)
+	Equals(t, 1, calls)
+}
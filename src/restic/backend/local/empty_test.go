@@ -0,0 +1,38 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestRejectEmpty(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.SnapshotFile, Name: "empty"}
+
+	be.RejectEmpty = true
+	err := be.Save(h, bytes.NewReader(nil))
+	Assert(t, errors.Cause(err) == local.ErrEmptyBlob, "expected ErrEmptyBlob, got %v", err)
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "empty file was saved")
+
+	files, err := ioutil.ReadDir(filepath.Join(be.Location(), "tmp"))
+	OK(t, err)
+	Equals(t, 0, len(files))
+
+	OK(t, be.Save(restic.Handle{Type: restic.SnapshotFile, Name: "foo"}, strings.NewReader("foo")))
+
+	be.RejectEmpty = false
+	OK(t, be.Save(h, bytes.NewReader(nil)))
+}
@@ -0,0 +1,85 @@
+package local
+
+import (
+	"path/filepath"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// readOnlyDirs are the directories whose files are made read-only by Save,
+// and restored to that mode by EnforceModes.
+var readOnlyDirs = map[string]bool{
+	backend.Paths.Data:      true,
+	backend.Paths.Index:     true,
+	backend.Paths.Snapshots: true,
+}
+
+// EnforceModes sets the mode of all directories in the repository to
+// backend.Modes.Dir, and makes the data, index and snapshot files read-only
+// again, e.g. after their modes were changed by accident. It returns the
+// number of directories and files changed. Files are left alone if
+// NoReadOnly is set, and on systems where Save does not make them
+// read-only. EnforceModes refuses to run on a backend opened with ReadOnly.
+func (b *Local) EnforceModes() (fixed int, err error) {
+	debug.Log("EnforceModes %v", b.Path)
+	if b.ReadOnly {
+		return 0, errors.New("EnforceModes on read-only backend")
+	}
+
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	fixFiles := readOnlyFilesSupported && !b.NoReadOnly
+
+	var walk func(dir string, files bool) error
+	walk = func(dir string, files bool) error {
+		fi, err := b.FS.Lstat(dir)
+		if err != nil {
+			return errors.Wrap(err, "Lstat")
+		}
+
+		if fi.Mode().Perm() != backend.Modes.Dir {
+			debug.Log("%v has mode %v, want %v", dir, fi.Mode().Perm(), backend.Modes.Dir)
+			if err := b.FS.Chmod(dir, backend.Modes.Dir); err != nil {
+				return errors.Wrap(err, "Chmod")
+			}
+			fixed++
+		}
+
+		entries, err := readdir(b.FS, dir)
+		if err != nil {
+			return err
+		}
+
+		for _, fi := range entries {
+			fn := filepath.Join(dir, fi.Name())
+			switch {
+			case fi.IsDir():
+				sub := files
+				if dir == b.Path {
+					sub = fixFiles && readOnlyDirs[fi.Name()]
+				}
+				if err := walk(fn, sub); err != nil {
+					return err
+				}
+			case files && isFile(fi) && fi.Mode()&0222 != 0:
+				debug.Log("%v has mode %v, making it read-only", fn, fi.Mode())
+				if err := setNewFileMode(b.FS, fn, fi); err != nil {
+					return errors.Wrap(err, "Chmod")
+				}
+				fixed++
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(b.Path, false); err != nil {
+		return fixed, err
+	}
+
+	return fixed, nil
+}
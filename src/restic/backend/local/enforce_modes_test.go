@@ -0,0 +1,64 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"runtime"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestEnforceModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("modes are not supported on windows")
+	}
+
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data, _ := saveData(t, be, 23, 100)
+	snapshot := restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash([]byte("snapshot")).String()}
+	OK(t, be.Save(snapshot, bytes.NewReader([]byte("snapshot"))))
+	key := restic.Handle{Type: restic.KeyFile, Name: "key"}
+	OK(t, be.Save(key, bytes.NewReader([]byte("key"))))
+
+	// loosen the modes
+	dataFile := filename(be.Path, data.Type, data.Name)
+	snapshotFile := filename(be.Path, snapshot.Type, snapshot.Name)
+	keyFile := filename(be.Path, key.Type, key.Name)
+	OK(t, os.Chmod(dataFile, 0644))
+	OK(t, os.Chmod(snapshotFile, 0666))
+	OK(t, os.Chmod(keyFile, 0600))
+	OK(t, os.Chmod(filepath.Dir(dataFile), 0755))
+
+	// nothing is changed for a read-only backend
+	be.ReadOnly = true
+	_, err := be.EnforceModes()
+	Assert(t, err != nil, "EnforceModes on read-only backend succeeded")
+	fi, err := os.Stat(dataFile)
+	OK(t, err)
+	Equals(t, os.FileMode(0644), fi.Mode().Perm())
+	be.ReadOnly = false
+
+	fixed, err := be.EnforceModes()
+	OK(t, err)
+	Equals(t, 3, fixed)
+
+	for fn, mode := range map[string]os.FileMode{
+		dataFile:               0444,
+		snapshotFile:           0444,
+		keyFile:                0600,
+		filepath.Dir(dataFile): 0700,
+	} {
+		fi, err := os.Stat(fn)
+		OK(t, err)
+		Equals(t, mode, fi.Mode().Perm())
+	}
+
+	fixed, err = be.EnforceModes()
+	OK(t, err)
+	Equals(t, 0, fixed)
+}
@@ -0,0 +1,93 @@
+package local
+
+import (
+	"bytes"
+	"io"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// Equal returns true if the files at a and b have the same content. The
+// sizes are compared first. For two content-addressed files, the names are
+// the hashes of the content, so files of the same size are equal exactly if
+// their names are and the content is not read. Other files are read and
+// compared until the first differing byte. If either file does not exist,
+// the error from Stat is returned, which satisfies os.IsNotExist.
+func (b *Local) Equal(x, y restic.Handle) (bool, error) {
+	debug.Log("Equal %v and %v", x, y)
+
+	xfi, err := b.Stat(x)
+	if err != nil {
+		return false, err
+	}
+	yfi, err := b.Stat(y)
+	if err != nil {
+		return false, err
+	}
+
+	if xfi.Size != yfi.Size {
+		debug.Log("sizes differ: %d != %d", xfi.Size, yfi.Size)
+		return false, nil
+	}
+
+	if x == y {
+		return true, nil
+	}
+
+	if isContentAddressed(x.Type) && isContentAddressed(y.Type) {
+		return x.Name == y.Name, nil
+	}
+
+	return b.equalContent(x, y)
+}
+
+// equalContent reads the files at x and y and reports whether their content
+// is the same.
+func (b *Local) equalContent(x, y restic.Handle) (equal bool, err error) {
+	xrd, err := b.Load(x, 0, 0)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if e := xrd.Close(); err == nil {
+			err = errors.Wrap(e, "Close")
+		}
+	}()
+
+	yrd, err := b.Load(y, 0, 0)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if e := yrd.Close(); err == nil {
+			err = errors.Wrap(e, "Close")
+		}
+	}()
+
+	xbuf := getCopyBuffer(b.CopyBufferSize)
+	defer putCopyBuffer(xbuf)
+	ybuf := getCopyBuffer(b.CopyBufferSize)
+	defer putCopyBuffer(ybuf)
+
+	for {
+		xn, xerr := io.ReadFull(xrd, xbuf)
+		yn, yerr := io.ReadFull(yrd, ybuf)
+		if xerr != nil && xerr != io.EOF && xerr != io.ErrUnexpectedEOF {
+			return false, errors.Wrap(xerr, "Read")
+		}
+		if yerr != nil && yerr != io.EOF && yerr != io.ErrUnexpectedEOF {
+			return false, errors.Wrap(yerr, "Read")
+		}
+
+		if !bytes.Equal(xbuf[:xn], ybuf[:yn]) {
+			return false, nil
+		}
+
+		if xerr != nil || yerr != nil {
+			// at the end of either file the other one must end as well
+			return xerr != nil && yerr != nil, nil
+		}
+	}
+}
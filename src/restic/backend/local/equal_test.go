@@ -0,0 +1,64 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"restic"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestEqual(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.CopyBufferSize = 64
+
+	data := Random(23, 1000)
+	changed := append([]byte{}, data...)
+	changed[999] ^= 0xff
+
+	save := func(name string, buf []byte) restic.Handle {
+		h := restic.Handle{Type: restic.KeyFile, Name: name}
+		OK(t, be.Save(h, bytes.NewReader(buf)))
+		return h
+	}
+	a := save("a", data)
+	same := save("same", data)
+	shorter := save("shorter", data[:999])
+	differs := save("differs", changed)
+
+	var tests = []struct {
+		x, y  restic.Handle
+		equal bool
+	}{
+		{a, a, true},
+		{a, same, true},
+		{a, shorter, false},
+		{shorter, a, false},
+		{a, differs, false},
+	}
+
+	for i, test := range tests {
+		equal, err := be.Equal(test.x, test.y)
+		OK(t, err)
+		Assert(t, equal == test.equal, "test %d: Equal(%v, %v) returned %v", i, test.x, test.y, equal)
+	}
+
+	// content-addressed files are compared by name
+	d1, _ := saveData(t, be, 5, 500)
+	d2, _ := saveData(t, be, 6, 500)
+	equal, err := be.Equal(d1, d1)
+	OK(t, err)
+	Assert(t, equal, "data file not equal to itself")
+	equal, err = be.Equal(d1, d2)
+	OK(t, err)
+	Assert(t, !equal, "different data files reported equal")
+
+	missing := restic.Handle{Type: restic.KeyFile, Name: "missing"}
+	_, err = be.Equal(a, missing)
+	Assert(t, os.IsNotExist(errors.Cause(err)), "expected not-exist error, got %v", err)
+	_, err = be.Equal(missing, a)
+	Assert(t, os.IsNotExist(errors.Cause(err)), "expected not-exist error, got %v", err)
+}
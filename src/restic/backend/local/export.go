@@ -0,0 +1,83 @@
+package local
+
+import (
+	"encoding/json"
+	"io"
+	"restic"
+	"sort"
+	"time"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ListingEntry describes a file in the listing written by ExportListing.
+type ListingEntry struct {
+	Type    restic.FileType `json:"type"`
+	Name    string          `json:"name"`
+	Size    int64           `json:"size"`
+	ModTime time.Time       `json:"mtime"`
+}
+
+// ExportListing writes a JSON array with an entry for each file in the
+// repository to w. The entries are sorted by type and name, so the output
+// for an unchanged repository is always the same. Entries are written as
+// they are found, only the listing of a single file type is held in memory.
+// If done is closed, an error is returned and the array is not completed.
+func (b *Local) ExportListing(w io.Writer, done <-chan struct{}) error {
+	debug.Log("ExportListing")
+	if _, err := io.WriteString(w, "["); err != nil {
+		return errors.Wrap(err, "Write")
+	}
+
+	first := true
+	for _, t := range fileTypes {
+		fileInfos, err := b.listFileInfos(t)
+		if err != nil {
+			return err
+		}
+
+		sort.Sort(byName(fileInfos))
+
+		for _, fi := range fileInfos {
+			select {
+			case <-done:
+				return errors.New("ExportListing canceled")
+			default:
+			}
+
+			name := fi.Name()
+			if t != restic.ConfigFile {
+				if name = b.naming().Decode(name); name == "" {
+					continue
+				}
+			}
+
+			buf, err := json.Marshal(ListingEntry{
+				Type:    t,
+				Name:    name,
+				Size:    fi.Size(),
+				ModTime: fi.ModTime().UTC(),
+			})
+			if err != nil {
+				return errors.Wrap(err, "Marshal")
+			}
+
+			sep := ",\n"
+			if first {
+				sep = "\n"
+				first = false
+			}
+
+			if _, err = io.WriteString(w, sep); err != nil {
+				return errors.Wrap(err, "Write")
+			}
+			if _, err = w.Write(buf); err != nil {
+				return errors.Wrap(err, "Write")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, "\n]\n")
+	return errors.Wrap(err, "Write")
+}
@@ -0,0 +1,93 @@
+package local
+
+import (
+	"archive/tar"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ExportTar writes all files and directories of the repository to w as a tar
+// stream, with paths relative to the repository directory. The directory
+// for temporary files is skipped. Entries are written in sorted order, so
+// exporting the same repository twice produces the same stream. Files are
+// read one at a time, nothing is buffered in memory. If done is closed, the
+// export stops and an error is returned.
+func (b *Local) ExportTar(w io.Writer, done <-chan struct{}) error {
+	debug.Log("ExportTar %v", b.Path)
+	tw := tar.NewWriter(w)
+
+	if err := b.exportTarDir(tw, "", done); err != nil {
+		return err
+	}
+
+	return errors.Wrap(tw.Close(), "Close")
+}
+
+// exportTarDir writes the entries of the directory rel below the repository
+// directory, recursively.
+func (b *Local) exportTarDir(tw *tar.Writer, rel string, done <-chan struct{}) error {
+	entries, err := readdir(b.FS, filepath.Join(b.Path, rel))
+	if err != nil {
+		return err
+	}
+
+	sort.Sort(byName(entries))
+
+	for _, fi := range entries {
+		select {
+		case <-done:
+			return errors.New("ExportTar canceled")
+		default:
+		}
+
+		name := filepath.Join(rel, fi.Name())
+		if name == backend.Paths.Temp || !(fi.IsDir() || isFile(fi)) {
+			continue
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return errors.Wrap(err, "FileInfoHeader")
+		}
+		hdr.Name = filepath.ToSlash(name)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err = tw.WriteHeader(hdr); err != nil {
+			return errors.Wrap(err, "WriteHeader")
+		}
+
+		if fi.IsDir() {
+			if err = b.exportTarDir(tw, name, done); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err = b.exportTarFile(tw, name, fi.Size()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportTarFile copies size bytes of the file rel to tw.
+func (b *Local) exportTarFile(tw *tar.Writer, rel string, size int64) error {
+	f, err := b.FS.Open(filepath.Join(b.Path, rel))
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+
+	_, err = io.CopyN(tw, f, size)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return errors.Wrap(err, "Copy")
+}
@@ -0,0 +1,94 @@
+package local_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"sort"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestExportTar(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	files := make(map[string][]byte)
+	for i := 0; i < 10; i++ {
+		data := Random(i, 100+i)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		files["data/"+h.Name[:2]+"/"+h.Name] = data
+	}
+
+	for fn, h := range map[string]restic.Handle{
+		"config":             {Type: restic.ConfigFile},
+		"keys/key":           {Type: restic.KeyFile, Name: "key"},
+		"locks/lock":         {Type: restic.LockFile, Name: "lock"},
+		"index/index":        {Type: restic.IndexFile, Name: "index"},
+		"snapshots/snapshot": {Type: restic.SnapshotFile, Name: "snapshot"},
+	} {
+		data := []byte(fn)
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		files[fn] = data
+	}
+
+	// tempfiles are not exported
+	OK(t, ioutil.WriteFile(filepath.Join(be.Path, "tmp", "temp-foo"), []byte("foo"), 0600))
+
+	var buf bytes.Buffer
+	OK(t, be.ExportTar(&buf, nil))
+
+	var names []string
+	found := make(map[string][]byte)
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		OK(t, err)
+		names = append(names, hdr.Name)
+
+		if strings.HasSuffix(hdr.Name, "/") {
+			Assert(t, hdr.Typeflag == tar.TypeDir, "%v is not a directory", hdr.Name)
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		OK(t, err)
+		found[hdr.Name] = data
+	}
+
+	Equals(t, files, found)
+	Assert(t, sort.StringsAreSorted(names), "entries are not sorted: %v", names)
+
+	for _, dir := range []string{"data/", "keys/", "locks/", "snapshots/", "index/"} {
+		i := sort.SearchStrings(names, dir)
+		Assert(t, i < len(names) && names[i] == dir, "directory %v not exported", dir)
+	}
+
+	// the export is reproducible
+	var buf2 bytes.Buffer
+	OK(t, be.ExportTar(&buf2, nil))
+	var buf3 bytes.Buffer
+	OK(t, be.ExportTar(&buf3, nil))
+	Assert(t, bytes.Equal(buf2.Bytes(), buf3.Bytes()), "exports differ")
+}
+
+func TestExportTarCancel(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	OK(t, be.Save(restic.Handle{Type: restic.LockFile, Name: "lock"}, bytes.NewReader([]byte("lock"))))
+
+	done := make(chan struct{})
+	close(done)
+	Assert(t, be.ExportTar(ioutil.Discard, done) != nil, "canceled export did not return an error")
+}
@@ -0,0 +1,54 @@
+package local_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"restic"
+	"sort"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestExportListing(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	OK(t, be.ExportListing(&buf, nil))
+	var entries []local.ListingEntry
+	OK(t, json.Unmarshal(buf.Bytes(), &entries))
+	Equals(t, 0, len(entries))
+
+	OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, strings.NewReader("config")))
+	data := saveRandom(t, be, restic.DataFile, 10)
+	saveRandom(t, be, restic.SnapshotFile, 3)
+
+	buf.Reset()
+	OK(t, be.ExportListing(&buf, nil))
+	OK(t, json.Unmarshal(buf.Bytes(), &entries))
+	Equals(t, 14, len(entries))
+
+	var names []string
+	for _, e := range entries {
+		if e.Type == restic.DataFile {
+			names = append(names, e.Name)
+			Assert(t, e.Size > 0, "wrong size for %v", e.Name)
+		}
+	}
+	Equals(t, 10, len(names))
+	Assert(t, sort.StringsAreSorted(names), "entries are not sorted")
+	Equals(t, restic.FileType(restic.ConfigFile), entries[0].Type)
+
+	for _, h := range data {
+		i := sort.SearchStrings(names, h.Name)
+		Assert(t, i < len(names) && names[i] == h.Name, "%v not listed", h)
+	}
+
+	// the output is stable
+	var buf2 bytes.Buffer
+	OK(t, be.ExportListing(&buf2, nil))
+	Equals(t, buf.String(), buf2.String())
+}
@@ -0,0 +1,33 @@
+package local
+
+import "golang.org/x/sys/unix"
+
+// The advice values of posix_fadvise on Linux.
+const (
+	fadvNormal     = 0
+	fadvRandom     = 1
+	fadvSequential = 2
+)
+
+// fadviseFunc is the system call issued by fadvise, tests replace it.
+var fadviseFunc = func(fd int, offset, length int64, advice int) error {
+	return unix.Fadvise(fd, offset, length, advice)
+}
+
+// fadvise announces the access pattern for length bytes of f at offset, a
+// length of zero extends to the end of the file.
+func fadvise(f File, offset, length int64, pattern AccessPattern) error {
+	advice := fadvNormal
+	switch pattern {
+	case AccessSequential:
+		advice = fadvSequential
+	case AccessRandom:
+		advice = fadvRandom
+	}
+
+	return fdOp(f, "Fadvise", func() error {
+		return retryEINTR("Fadvise", func() error {
+			return fadviseFunc(int(f.Fd()), offset, length, advice)
+		})
+	})
+}
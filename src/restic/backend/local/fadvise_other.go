@@ -0,0 +1,8 @@
+// +build !linux
+
+package local
+
+// fadvise is only available on Linux.
+func fadvise(f File, offset, length int64, pattern AccessPattern) error {
+	return nil
+}
@@ -0,0 +1,154 @@
+package local
+
+import (
+	"io"
+	"os"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// FallbackBackend reads files from a primary backend and falls back to a
+// secondary backend for files the primary does not have, e.g. a local
+// cache in front of a slow remote repository. New files are only saved to
+// the primary.
+type FallbackBackend struct {
+	Primary, Secondary restic.Backend
+
+	// Populate makes Load save files found in the secondary to the
+	// primary, so that later loads are served by the primary.
+	Populate bool
+}
+
+var _ restic.Backend = &FallbackBackend{}
+
+// NewFallbackBackend returns a FallbackBackend for primary and secondary.
+func NewFallbackBackend(primary, secondary restic.Backend, populate bool) *FallbackBackend {
+	return &FallbackBackend{Primary: primary, Secondary: secondary, Populate: populate}
+}
+
+// missing returns true if the primary does not have the file h, err is the
+// error the primary returned for it. Backends report missing files in
+// different ways, so unless err says so, the primary is asked with Test.
+func (b *FallbackBackend) missing(h restic.Handle, err error) bool {
+	if os.IsNotExist(errors.Cause(err)) {
+		return true
+	}
+
+	ok, terr := b.Primary.Test(h)
+	return terr == nil && !ok
+}
+
+// Location returns the location of the primary backend.
+func (b *FallbackBackend) Location() string {
+	return b.Primary.Location()
+}
+
+// Test returns true if the file exists in either backend.
+func (b *FallbackBackend) Test(h restic.Handle) (bool, error) {
+	ok, err := b.Primary.Test(h)
+	if err != nil || ok {
+		return ok, err
+	}
+	return b.Secondary.Test(h)
+}
+
+// Remove removes the file from the primary backend.
+func (b *FallbackBackend) Remove(h restic.Handle) error {
+	return b.Primary.Remove(h)
+}
+
+// Close closes both backends.
+func (b *FallbackBackend) Close() error {
+	err := b.Primary.Close()
+	if e := b.Secondary.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Save stores the data in the primary backend.
+func (b *FallbackBackend) Save(h restic.Handle, rd io.Reader) error {
+	return b.Primary.Save(h, rd)
+}
+
+// Load returns a reader for the file from the primary backend, or from the
+// secondary if the primary does not have it. With Populate, the file is
+// then copied to the primary and read from there. If copying fails, the
+// file is read from the secondary.
+func (b *FallbackBackend) Load(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	rd, err := b.Primary.Load(h, length, offset)
+	if err == nil || !b.missing(h, err) {
+		return rd, err
+	}
+
+	if b.Populate {
+		err := b.populate(h)
+		if err == nil {
+			return b.Primary.Load(h, length, offset)
+		}
+		debug.Log("unable to copy %v to the primary backend: %v", h, err)
+	}
+
+	return b.Secondary.Load(h, length, offset)
+}
+
+// populate copies the file at h from the secondary to the primary backend.
+func (b *FallbackBackend) populate(h restic.Handle) error {
+	debug.Log("copying %v to the primary backend", h)
+	rd, err := b.Secondary.Load(h, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	err = b.Primary.Save(h, rd)
+	if e := rd.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Stat returns information about the file from the primary backend, or
+// from the secondary if the primary does not have it.
+func (b *FallbackBackend) Stat(h restic.Handle) (restic.FileInfo, error) {
+	fi, err := b.Primary.Stat(h)
+	if err == nil || !b.missing(h, err) {
+		return fi, err
+	}
+	return b.Secondary.Stat(h)
+}
+
+// List returns the names of the files in both backends, each name once.
+// The names of the primary are collected before the secondary is listed.
+func (b *FallbackBackend) List(t restic.FileType, done <-chan struct{}) <-chan string {
+	ch := make(chan string)
+
+	go func() {
+		defer close(ch)
+
+		seen := make(map[string]struct{})
+		for name := range b.Primary.List(t, done) {
+			seen[name] = struct{}{}
+			select {
+			case ch <- name:
+			case <-done:
+				return
+			}
+		}
+
+		for name := range b.Secondary.List(t, done) {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+
+			select {
+			case ch <- name:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,109 @@
+package local_test
+
+import (
+	"bytes"
+	"io"
+	"restic"
+	"sort"
+	"testing"
+
+	"restic/backend/local"
+	"restic/backend/mem"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestFallbackBackend(t *testing.T) {
+	for _, populate := range []bool{false, true} {
+		primary, cleanupPrimary := local.TestBackend(t)
+		secondary, cleanupSecondary := local.TestBackend(t)
+
+		onlyPrimary := []byte("primary")
+		onlySecondary := Random(23, 1000)
+		hp := restic.Handle{Type: restic.DataFile, Name: restic.Hash(onlyPrimary).String()}
+		hs := restic.Handle{Type: restic.DataFile, Name: restic.Hash(onlySecondary).String()}
+		OK(t, primary.Save(hp, bytes.NewReader(onlyPrimary)))
+		OK(t, secondary.Save(hs, bytes.NewReader(onlySecondary)))
+
+		be := local.NewFallbackBackend(primary, secondary, populate)
+
+		// primary hit
+		Equals(t, onlyPrimary, loadAll(t, be, hp))
+
+		// secondary fallthrough
+		ok, err := be.Test(hs)
+		OK(t, err)
+		Assert(t, ok, "file in secondary not found")
+
+		fi, err := be.Stat(hs)
+		OK(t, err)
+		Equals(t, int64(len(onlySecondary)), fi.Size)
+
+		rd, err := be.Load(hs, 100, 10)
+		OK(t, err)
+		Equals(t, onlySecondary[10:110], readAll(t, rd))
+
+		ok, err = primary.Test(hs)
+		OK(t, err)
+		Equals(t, populate, ok)
+		if populate {
+			Equals(t, onlySecondary, loadAll(t, primary, hs))
+		}
+
+		missing := restic.Handle{Type: restic.DataFile, Name: restic.Hash([]byte("missing")).String()}
+		ok, err = be.Test(missing)
+		OK(t, err)
+		Assert(t, !ok, "missing file found")
+		_, err = be.Load(missing, 0, 0)
+		Assert(t, err != nil, "Load of a missing file succeeded")
+
+		var names []string
+		for name := range be.List(restic.DataFile, nil) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		want := []string{hp.Name, hs.Name}
+		sort.Strings(want)
+		Equals(t, want, names)
+
+		// new files are only saved to the primary
+		data := []byte("new")
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		ok, err = secondary.Test(h)
+		OK(t, err)
+		Assert(t, !ok, "file saved to the secondary")
+
+		cleanupPrimary()
+		cleanupSecondary()
+	}
+}
+
+func TestFallbackBackendErrors(t *testing.T) {
+	secondary, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, secondary.Save(h, bytes.NewReader(data)))
+
+	// the mem backend does not report missing files with os.ErrNotExist
+	be := local.NewFallbackBackend(mem.New(), secondary, false)
+	Equals(t, data, loadAll(t, be, h))
+	fi, err := be.Stat(h)
+	OK(t, err)
+	Equals(t, int64(len(data)), fi.Size)
+
+	// a file which cannot be copied to the primary is read from the secondary
+	primary, cleanupPrimary := local.TestBackend(t)
+	defer cleanupPrimary()
+	primary.SaveHook = func(restic.Handle, io.Reader) (io.Reader, error) {
+		return nil, errors.New("no space left")
+	}
+
+	be = local.NewFallbackBackend(primary, secondary, true)
+	Equals(t, data, loadAll(t, be, h))
+	ok, err := primary.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "file copied to the primary")
+}
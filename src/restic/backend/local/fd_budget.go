@@ -0,0 +1,113 @@
+package local
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// defaultOpenFilesReserve is the number of file descriptors left for the
+// rest of the process if OpenFilesReserve is not set.
+const defaultOpenFilesReserve = 64
+
+// minFDBudget is the smallest budget, some operations keep two files open,
+// e.g. LoadToFile reads the file while writing the tempfile.
+const minFDBudget = 2
+
+// fdBudgetWait is the time an open waits for a slot of the budget before
+// ErrTooManyOpenFiles is returned, tests replace it.
+var fdBudgetWait = time.Minute
+
+// ErrTooManyOpenFiles is returned when no file is closed within
+// fdBudgetWait while the budget of open files is exhausted.
+var ErrTooManyOpenFiles = errors.New("too many open files")
+
+// fdBudget returns the number of files the backend may keep open at the same
+// time, zero means there is no limit.
+func fdBudget(cfg Config) int {
+	budget := cfg.MaxOpenFiles
+
+	if limit, ok := openFileLimit(); ok {
+		reserve := cfg.OpenFilesReserve
+		if reserve <= 0 {
+			reserve = defaultOpenFilesReserve
+		}
+
+		n := int64(limit) - int64(reserve)
+		if n < minFDBudget {
+			n = minFDBudget
+		}
+
+		if budget <= 0 || int64(budget) > n {
+			budget = int(n)
+		}
+	}
+
+	if budget < 0 {
+		budget = 0
+	}
+	if budget > 0 && budget < minFDBudget {
+		budget = minFDBudget
+	}
+	return budget
+}
+
+// fdBudgetFS limits the number of files opened through it. When the budget
+// is exhausted, opening a file blocks until another file is closed instead
+// of failing with EMFILE. If no file is closed within fdBudgetWait, e.g.
+// because all slots are held by callers waiting for a second file,
+// ErrTooManyOpenFiles is returned.
+type fdBudgetFS struct {
+	FS
+	sem chan struct{}
+}
+
+func newFDBudgetFS(fsys FS, n int) fdBudgetFS {
+	debug.Log("limiting open files to %d", n)
+	return fdBudgetFS{FS: fsys, sem: make(chan struct{}, n)}
+}
+
+func (f fdBudgetFS) openFile(open func() (File, error)) (File, error) {
+	t := time.NewTimer(fdBudgetWait)
+	select {
+	case f.sem <- struct{}{}:
+		t.Stop()
+	case <-t.C:
+		return nil, errors.Wrapf(ErrTooManyOpenFiles, "no file closed within %v", fdBudgetWait)
+	}
+
+	file, err := open()
+	if err != nil {
+		<-f.sem
+		return nil, err
+	}
+	return &budgetFile{File: file, sem: f.sem}, nil
+}
+
+func (f fdBudgetFS) Open(name string) (File, error) {
+	return f.openFile(func() (File, error) { return f.FS.Open(name) })
+}
+
+func (f fdBudgetFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return f.openFile(func() (File, error) { return f.FS.OpenFile(name, flag, perm) })
+}
+
+func (f fdBudgetFS) TempFile(dir, prefix string) (File, error) {
+	return f.openFile(func() (File, error) { return f.FS.TempFile(dir, prefix) })
+}
+
+// budgetFile returns its slot to the budget when it is closed.
+type budgetFile struct {
+	File
+	sem  chan struct{}
+	once sync.Once
+}
+
+func (f *budgetFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(func() { <-f.sem })
+	return err
+}
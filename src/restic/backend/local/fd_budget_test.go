@@ -0,0 +1,115 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"sync"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestFDBudget(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var handles []restic.Handle
+	for i := 0; i < 10; i++ {
+		data := Random(i, 1000)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		handles = append(handles, h)
+	}
+
+	const budget = 4
+	var m sync.Mutex
+	var budgetFS fdBudgetFS
+	peak := 0
+	inner := &fakeFS{FS: defaultFS, fail: func(op, name string) error {
+		if op == "Open" || op == "OpenFile" || op == "TempFile" {
+			m.Lock()
+			if n := len(budgetFS.sem); n > peak {
+				peak = n
+			}
+			m.Unlock()
+		}
+		return nil
+	}}
+
+	be2, err := open(Config{Path: be.Path, MaxOpenFiles: budget}, inner)
+	OK(t, err)
+	budgetFS = be2.FS.(fdBudgetFS)
+	Equals(t, budget, cap(budgetFS.sem))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(h restic.Handle) {
+			defer wg.Done()
+			rd, err := be2.Load(h, 0, 0)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			// keep the file open for a while
+			time.Sleep(time.Millisecond)
+			if _, err = ioutil.ReadAll(rd); err != nil {
+				errs <- err
+			}
+			if err = rd.Close(); err != nil {
+				errs <- err
+			}
+		}(handles[i%len(handles)])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Load failed: %v", err)
+	}
+
+	Assert(t, peak <= budget, "%d files open at the same time, budget is %d", peak, budget)
+	Equals(t, 0, len(budgetFS.sem))
+}
+
+func TestFDBudgetNested(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h, data := saveData(t, be, 23, 1000)
+
+	// LoadToFile keeps the file open while it writes the tempfile
+	be2, err := open(Config{Path: be.Path, MaxOpenFiles: 1}, defaultFS)
+	OK(t, err)
+	Equals(t, minFDBudget, cap(be2.FS.(fdBudgetFS).sem))
+
+	dest := filepath.Join(be.Path, "restored")
+	OK(t, be2.LoadToFile(h, dest))
+	buf, err := ioutil.ReadFile(dest)
+	OK(t, err)
+	Equals(t, data, buf)
+
+	// an exhausted budget fails after a while instead of blocking forever
+	defer func(d time.Duration) { fdBudgetWait = d }(fdBudgetWait)
+	fdBudgetWait = 10 * time.Millisecond
+
+	fsys := be2.FS.(fdBudgetFS)
+	fn := filename(be.Path, h.Type, h.Name)
+	var files []File
+	for i := 0; i < minFDBudget; i++ {
+		f, err := fsys.Open(fn)
+		OK(t, err)
+		files = append(files, f)
+	}
+	_, err = fsys.Open(fn)
+	Assert(t, errors.Cause(err) == ErrTooManyOpenFiles, "expected ErrTooManyOpenFiles, got %v", err)
+	for _, f := range files {
+		OK(t, f.Close())
+	}
+}
@@ -0,0 +1,111 @@
+package local
+
+import (
+	"restic"
+	"sync"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// findCorruptWorkers is the number of files hashed in parallel by
+// FindCorrupt.
+const findCorruptWorkers = 4
+
+// FindCorrupt reads all content-addressed files (data, index and snapshots)
+// and sends the handles of those whose content does not match their name to
+// the first channel, as soon as they are found. Errors reading a file are
+// sent to the second channel and do not stop the search. Both channels are
+// closed when all files have been checked or done is closed, the caller must
+// receive from both until then. If progress is not nil, it is called with
+// the number of files checked so far after each file.
+func (b *Local) FindCorrupt(done <-chan struct{}, progress func(checked int)) (<-chan restic.Handle, <-chan error) {
+	debug.Log("FindCorrupt")
+
+	corrupt := make(chan restic.Handle)
+	errs := make(chan error)
+
+	handles := make(chan restic.Handle)
+	go func() {
+		defer close(handles)
+
+		for _, t := range []restic.FileType{restic.SnapshotFile, restic.IndexFile, restic.DataFile} {
+			for name := range b.List(t, done) {
+				select {
+				case handles <- restic.Handle{Type: t, Name: name}:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	var m sync.Mutex
+	checked := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < findCorruptWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range handles {
+				ok, err := b.matchesName(h)
+
+				if progress != nil {
+					m.Lock()
+					checked++
+					progress(checked)
+					m.Unlock()
+				}
+
+				switch {
+				case err != nil:
+					select {
+					case errs <- err:
+					case <-done:
+						return
+					}
+				case !ok:
+					debug.Log("%v is corrupt", h)
+					select {
+					case corrupt <- h:
+					case <-done:
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(corrupt)
+		close(errs)
+	}()
+
+	return corrupt, errs
+}
+
+// matchesName returns true if the content of the file at h hashes to its
+// name. Files with names which are not a valid hash do not match.
+func (b *Local) matchesName(h restic.Handle) (bool, error) {
+	if err := b.checkHashName(h); err != nil {
+		return false, nil
+	}
+
+	var id string
+	var err error
+	if e, ok := b.packed(h); ok {
+		id, err = b.hashPacked(e)
+	} else {
+		var fn string
+		if fn, _, err = b.locate(h); err == nil {
+			id, err = b.hashFile(fn)
+		}
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "%v", h)
+	}
+
+	return id == h.Name, nil
+}
@@ -0,0 +1,83 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"sync"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestFindCorrupt(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		data := Random(i, 1000)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+	}
+
+	data := Random(23, 500)
+	snapshot := restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(snapshot, bytes.NewReader(data)))
+
+	// plant a file with content which does not match its name
+	bad := restic.Handle{Type: restic.DataFile, Name: restic.Hash([]byte("foo")).String()}
+	OK(t, be.Save(bad, bytes.NewReader([]byte("bar"))))
+
+	// lock files are not content-addressed
+	OK(t, be.Save(restic.Handle{Type: restic.LockFile, Name: "lock"}, bytes.NewReader([]byte("x"))))
+
+	var m sync.Mutex
+	last := 0
+	corrupt, errs := be.FindCorrupt(nil, func(checked int) {
+		m.Lock()
+		last = checked
+		m.Unlock()
+	})
+
+	var found []restic.Handle
+	for corrupt != nil || errs != nil {
+		select {
+		case h, ok := <-corrupt:
+			if !ok {
+				corrupt = nil
+				continue
+			}
+			found = append(found, h)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			OK(t, err)
+		}
+	}
+
+	Equals(t, []restic.Handle{bad}, found)
+	Equals(t, 12, last)
+}
+
+func TestFindCorruptCancel(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		OK(t, be.Save(restic.Handle{Type: restic.DataFile, Name: restic.Hash(Random(i, 10)).String()},
+			bytes.NewReader([]byte("corrupt"))))
+	}
+
+	done := make(chan struct{})
+	corrupt, errs := be.FindCorrupt(done, nil)
+	<-corrupt
+	close(done)
+
+	// both channels are closed after cancellation
+	for range corrupt {
+	}
+	for range errs {
+	}
+}
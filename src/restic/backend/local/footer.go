@@ -0,0 +1,173 @@
+package local
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"restic"
+
+	"restic/backend"
+	"restic/errors"
+)
+
+// FooterSize is the size of the footer appended to files when Footer is
+// enabled.
+const FooterSize = 16
+
+// footerMagic marks the start of a footer.
+var footerMagic = []byte("rsf1")
+
+// footerTypes maps the file types to the codes stored in the footer.
+var footerTypes = []restic.FileType{
+	1: restic.DataFile,
+	2: restic.KeyFile,
+	3: restic.LockFile,
+	4: restic.SnapshotFile,
+	5: restic.IndexFile,
+	6: restic.ConfigFile,
+}
+
+// encodeFooter returns the footer for a file of type t with length bytes of
+// content. The footer consists of the magic, the type code, three reserved
+// bytes and the length as little-endian uint64.
+func encodeFooter(t restic.FileType, length int64) []byte {
+	buf := make([]byte, FooterSize)
+	copy(buf, footerMagic)
+	for code, ft := range footerTypes {
+		if ft == t && code > 0 {
+			buf[4] = byte(code)
+		}
+	}
+	binary.LittleEndian.PutUint64(buf[8:], uint64(length))
+	return buf
+}
+
+// DecodeFooter parses the last FooterSize bytes of a file of size bytes. It
+// returns the type and the length of the content, ok is false if buf is not
+// a valid footer for the file. This allows recovery tools to identify a
+// stray file without the index.
+func DecodeFooter(buf []byte, size int64) (t restic.FileType, length int64, ok bool) {
+	if len(buf) != FooterSize || !bytes.Equal(buf[:4], footerMagic) {
+		return "", 0, false
+	}
+
+	code := int(buf[4])
+	if code == 0 || code >= len(footerTypes) {
+		return "", 0, false
+	}
+
+	length = int64(binary.LittleEndian.Uint64(buf[8:]))
+	if length != size-FooterSize {
+		return "", 0, false
+	}
+
+	return footerTypes[code], length, true
+}
+
+// appendFooter appends the footer for h to the tempfile holding size bytes.
+func (b *Local) appendFooter(h restic.Handle, tmpfile string, size int64) error {
+	f, err := b.FS.OpenFile(tmpfile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return errors.Wrap(err, "OpenFile")
+	}
+
+	if _, err = f.Write(encodeFooter(h.Type, size)); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Write")
+	}
+
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Sync")
+	}
+
+	return errors.Wrap(f.Close(), "Close")
+}
+
+// contentSize returns the size of the content of f with size bytes, which
+// excludes the footer. Files without a valid footer, e.g. those saved before
+// Footer was enabled, consist of content only. The offset of f is reset to
+// the start of the file.
+func (b *Local) contentSize(f File, size int64) (int64, error) {
+	if !b.Footer || size < FooterSize {
+		return size, nil
+	}
+
+	if _, err := f.Seek(size-FooterSize, 0); err != nil {
+		return 0, errors.Wrap(err, "Seek")
+	}
+
+	buf := make([]byte, FooterSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return 0, errors.Wrap(err, "ReadFull")
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return 0, errors.Wrap(err, "Seek")
+	}
+
+	if _, length, ok := DecodeFooter(buf, size); ok {
+		return length, nil
+	}
+
+	return size, nil
+}
+
+// openContent opens the file for h and returns a reader which yields its
+// content without the footer, together with the content size.
+func (b *Local) openContent(h restic.Handle) (File, int64, error) {
+	f, err := b.openFile(h)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, errors.Wrap(err, "Stat")
+	}
+
+	size, err := b.contentSize(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, size, nil
+}
+
+// loadContent implements Load for files which may have a footer.
+func (b *Local) loadContent(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	f, size, err := b.openContent(h)
+	if os.IsNotExist(errors.Cause(err)) {
+		return b.loadParts(h, length, offset, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if b.DetectTruncation && length > 0 {
+		if err = checkTruncated(h, size, length, offset); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if offset > 0 {
+		if _, err = f.Seek(offset, 0); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	n := size - offset
+	if n < 0 {
+		n = 0
+	}
+	if length > 0 && int64(length) < n {
+		n = int64(length)
+	}
+
+	return backend.LimitReadCloser(f, n), nil
+}
@@ -0,0 +1,82 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+func load(t testing.TB, be *Local, h restic.Handle, length int, offset int64) []byte {
+	rd, err := be.Load(h, length, offset)
+	OK(t, err)
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	return buf
+}
+
+func TestFooter(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.Footer = true
+	be.VerifyHash = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	raw, err := ioutil.ReadFile(filename(be.Path, h.Type, h.Name))
+	OK(t, err)
+	Equals(t, len(data)+FooterSize, len(raw))
+
+	tpe, length, ok := DecodeFooter(raw[len(raw)-FooterSize:], int64(len(raw)))
+	Assert(t, ok, "no valid footer found")
+	Equals(t, restic.FileType(restic.DataFile), tpe)
+	Equals(t, int64(len(data)), length)
+
+	fi, err := be.Stat(h)
+	OK(t, err)
+	Equals(t, int64(len(data)), fi.Size)
+
+	Equals(t, data, load(t, be, h, 0, 0))
+	Equals(t, data[100:200], load(t, be, h, 100, 100))
+	Equals(t, data[900:], load(t, be, h, 500, 900))
+	Equals(t, data[990:], load(t, be, h, 0, 990))
+	Equals(t, []byte{}, load(t, be, h, 0, int64(len(data))))
+
+	OK(t, be.Verify(h))
+}
+
+func TestFooterMixed(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	// files saved before the footer was enabled are still read correctly
+	data := Random(5, 500)
+	h := restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	be.Footer = true
+	fi, err := be.Stat(h)
+	OK(t, err)
+	Equals(t, int64(len(data)), fi.Size)
+	Equals(t, data, load(t, be, h, 0, 0))
+
+	// files with a footer written by SaveWriter are stripped as well
+	lock := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	w, err := be.SaveWriter(lock)
+	OK(t, err)
+	_, err = w.Write([]byte("foobar"))
+	OK(t, err)
+	OK(t, w.Close())
+	Equals(t, []byte("foobar"), load(t, be, lock, 0, 0))
+
+	raw, err := ioutil.ReadFile(filename(be.Path, lock.Type, lock.Name))
+	OK(t, err)
+	tpe, _, ok := DecodeFooter(raw[len(raw)-FooterSize:], int64(len(raw)))
+	Assert(t, ok, "no valid footer found")
+	Equals(t, restic.FileType(restic.LockFile), tpe)
+}
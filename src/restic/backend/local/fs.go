@@ -0,0 +1,96 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+
+	"restic/fs"
+)
+
+// File is an open file as returned by FS.
+type File interface {
+	fs.File
+
+	Name() string
+	Sync() error
+}
+
+// FS is the file system the local backend operates on. By default, the
+// functions from the fs package are used, tests can inject an implementation
+// which simulates errors.
+type FS interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	TempFile(dir, prefix string) (File, error)
+
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Chmod(name string, mode os.FileMode) error
+	MkdirAll(path string, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	Link(oldname, newname string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+}
+
+// realFS implements FS with the functions from the fs package.
+type realFS struct{}
+
+// defaultFS is the FS used by Open and Create.
+var defaultFS FS = realFS{}
+
+func (realFS) Open(name string) (File, error) {
+	f, err := fs.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (realFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (realFS) TempFile(dir, prefix string) (File, error) {
+	f, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (realFS) Stat(name string) (os.FileInfo, error) {
+	return fs.Stat(name)
+}
+
+func (realFS) Lstat(name string) (os.FileInfo, error) {
+	return fs.Lstat(name)
+}
+
+func (realFS) Chmod(name string, mode os.FileMode) error {
+	return fs.Chmod(name, mode)
+}
+
+func (realFS) MkdirAll(path string, perm os.FileMode) error {
+	return fs.MkdirAll(path, perm)
+}
+
+func (realFS) Rename(oldpath, newpath string) error {
+	return fs.Rename(oldpath, newpath)
+}
+
+func (realFS) Link(oldname, newname string) error {
+	return fs.Link(oldname, newname)
+}
+
+func (realFS) Remove(name string) error {
+	return fs.Remove(name)
+}
+
+func (realFS) RemoveAll(path string) error {
+	return fs.RemoveAll(path)
+}
@@ -0,0 +1,224 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"syscall"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// fakeFS wraps an FS and allows tests to make operations fail. For each
+// operation, fail is called with the name of the operation (e.g. "Rename",
+// "Write") and the path of the file. If it returns an error, the operation is
+// not executed and the error is returned instead.
+type fakeFS struct {
+	FS
+	fail func(op, name string) error
+}
+
+func (f *fakeFS) check(op, name string) error {
+	if f.fail == nil {
+		return nil
+	}
+
+	err := f.fail(op, name)
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return nil
+}
+
+func (f *fakeFS) wrap(file File, err error) (File, error) {
+	if err != nil {
+		return nil, err
+	}
+	return fakeFile{File: file, fs: f}, nil
+}
+
+func (f *fakeFS) Open(name string) (File, error) {
+	if err := f.check("Open", name); err != nil {
+		return nil, err
+	}
+	return f.wrap(f.FS.Open(name))
+}
+
+func (f *fakeFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if err := f.check("OpenFile", name); err != nil {
+		return nil, err
+	}
+	return f.wrap(f.FS.OpenFile(name, flag, perm))
+}
+
+func (f *fakeFS) TempFile(dir, prefix string) (File, error) {
+	if err := f.check("TempFile", dir); err != nil {
+		return nil, err
+	}
+	return f.wrap(f.FS.TempFile(dir, prefix))
+}
+
+func (f *fakeFS) Stat(name string) (os.FileInfo, error) {
+	if err := f.check("Stat", name); err != nil {
+		return nil, err
+	}
+	return f.FS.Stat(name)
+}
+
+func (f *fakeFS) Lstat(name string) (os.FileInfo, error) {
+	if err := f.check("Lstat", name); err != nil {
+		return nil, err
+	}
+	return f.FS.Lstat(name)
+}
+
+func (f *fakeFS) Chmod(name string, mode os.FileMode) error {
+	if err := f.check("Chmod", name); err != nil {
+		return err
+	}
+	return f.FS.Chmod(name, mode)
+}
+
+func (f *fakeFS) MkdirAll(path string, perm os.FileMode) error {
+	if err := f.check("MkdirAll", path); err != nil {
+		return err
+	}
+	return f.FS.MkdirAll(path, perm)
+}
+
+func (f *fakeFS) Rename(oldpath, newpath string) error {
+	if err := f.check("Rename", newpath); err != nil {
+		return err
+	}
+	return f.FS.Rename(oldpath, newpath)
+}
+
+func (f *fakeFS) Link(oldname, newname string) error {
+	if err := f.check("Link", newname); err != nil {
+		return err
+	}
+	return f.FS.Link(oldname, newname)
+}
+
+func (f *fakeFS) Remove(name string) error {
+	if err := f.check("Remove", name); err != nil {
+		return err
+	}
+	return f.FS.Remove(name)
+}
+
+func (f *fakeFS) RemoveAll(path string) error {
+	if err := f.check("RemoveAll", path); err != nil {
+		return err
+	}
+	return f.FS.RemoveAll(path)
+}
+
+// fakeFile wraps a File returned by a fakeFS.
+type fakeFile struct {
+	File
+	fs *fakeFS
+}
+
+func (f fakeFile) Read(p []byte) (int, error) {
+	if err := f.fs.check("Read", f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+func (f fakeFile) Write(p []byte) (int, error) {
+	if err := f.fs.check("Write", f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
+func (f fakeFile) Sync() error {
+	if err := f.fs.check("Sync", f.Name()); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
+
+// failOp returns a function for fakeFS.fail which returns err for operation op.
+func failOp(op string, err error) func(string, string) error {
+	return func(o, name string) error {
+		if o == op {
+			return err
+		}
+		return nil
+	}
+}
+
+func TestSaveErrors(t *testing.T) {
+	var tests = []struct {
+		op  string
+		err error
+
+		// cause is the error expected from Save if it is not the
+		// *os.PathError with err
+		cause error
+	}{
+		{"TempFile", syscall.EACCES, nil},
+		{"Write", syscall.ENOSPC, ErrNoSpace},
+		{"Sync", syscall.EIO, nil},
+		{"MkdirAll", syscall.ENOSPC, ErrNoSpace},
+		{"Rename", syscall.EXDEV, nil},
+		{"Chmod", syscall.EPERM, nil},
+	}
+
+	for _, test := range tests {
+		be, cleanup := TestBackend(t)
+		be.FS = &fakeFS{FS: be.FS, fail: failOp(test.op, test.err)}
+
+		data := Random(23, 1000)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		err := be.Save(h, bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("%v: expected error not returned", test.op)
+			cleanup()
+			continue
+		}
+
+		if test.cause != nil {
+			if errors.Cause(err) != test.cause {
+				t.Errorf("%v: unexpected error returned: %v", test.op, err)
+			}
+		} else if e, ok := errors.Cause(err).(*os.PathError); !ok || e.Err != test.err {
+			t.Errorf("%v: unexpected error returned: %v", test.op, err)
+		}
+
+		cleanup()
+	}
+}
+
+func TestFakeFSList(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	shard := filepath.Join(be.Path, "data", h.Name[:2])
+	be.FS = &fakeFS{FS: be.FS, fail: func(op, name string) error {
+		if op == "Open" && name == shard {
+			return syscall.EIO
+		}
+		return nil
+	}}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var names []string
+	for name := range be.List(restic.DataFile, done) {
+		names = append(names, name)
+	}
+	Equals(t, 0, len(names))
+}
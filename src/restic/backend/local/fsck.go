@@ -0,0 +1,128 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// essentialDirs holds the directories which contain repository data. If one
+// of them is missing, data has been lost, so FsckStructure never recreates
+// them.
+var essentialDirs = map[string]bool{
+	backend.Paths.Data:      true,
+	backend.Paths.Snapshots: true,
+	backend.Paths.Index:     true,
+	backend.Paths.Keys:      true,
+}
+
+// optionalDirs holds the directories which are created on demand.
+var optionalDirs = []string{
+	localPaths.Checksums,
+	localPaths.Pool,
+	localPaths.Meta,
+	localPaths.Log,
+	localPaths.Cache,
+	localPaths.Journal,
+	localPaths.Offsets,
+	localPaths.Parity,
+	localPaths.Packed,
+}
+
+// isShardName returns true if name is a valid name for a data subdirectory.
+func isShardName(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	for _, c := range name {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// FsckStructure checks the directory tree of the repository and returns a
+// description of each problem found: directories which are missing or not
+// directories, and unexpected directories at the top level and in the data
+// directory. If repair is true, missing directories which do not hold data
+// (locks and tmp) are created again. Files and unexpected directories are
+// never modified.
+func (b *Local) FsckStructure(repair bool) (problems []string, err error) {
+	debug.Log("FsckStructure %v, repair %v", b.Path, repair)
+	if repair {
+		if err := b.checkRecoverMode(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := b.FS.Stat(b.Path); err != nil {
+		return nil, errors.Wrap(err, "Stat")
+	}
+
+	expected := make(map[string]bool)
+	for _, d := range paths(b.Path)[1:] {
+		name := filepath.Base(d)
+		expected[name] = true
+
+		fi, err := b.FS.Lstat(d)
+		switch {
+		case os.IsNotExist(errors.Cause(err)):
+			if !repair || essentialDirs[name] {
+				problems = append(problems, fmt.Sprintf("missing directory %v", name))
+				continue
+			}
+
+			if err := b.FS.MkdirAll(d, backend.Modes.Dir); err != nil {
+				return problems, errors.Wrap(err, "MkdirAll")
+			}
+			problems = append(problems, fmt.Sprintf("missing directory %v (recreated)", name))
+		case err != nil:
+			return problems, errors.Wrap(err, "Lstat")
+		case !fi.IsDir():
+			problems = append(problems, fmt.Sprintf("%v is not a directory", name))
+		}
+	}
+	for _, name := range optionalDirs {
+		expected[name] = true
+	}
+
+	unexpected := func(dir string, valid func(string) bool) error {
+		entries, err := readdir(b.FS, dir)
+		if err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				return nil
+			}
+			return err
+		}
+
+		var names []string
+		for _, fi := range entries {
+			if fi.IsDir() && !valid(fi.Name()) {
+				names = append(names, fi.Name())
+			}
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			rel, _ := filepath.Rel(b.Path, filepath.Join(dir, name))
+			problems = append(problems, fmt.Sprintf("unexpected directory %v", filepath.ToSlash(rel)))
+		}
+		return nil
+	}
+
+	if err := unexpected(b.Path, func(name string) bool { return expected[name] }); err != nil {
+		return problems, err
+	}
+
+	if err := unexpected(filepath.Join(b.Path, backend.Paths.Data), isShardName); err != nil {
+		return problems, err
+	}
+
+	return problems, nil
+}
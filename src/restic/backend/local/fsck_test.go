@@ -0,0 +1,93 @@
+package local_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestFsckStructure(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	problems, err := be.FsckStructure(false)
+	OK(t, err)
+	Equals(t, 0, len(problems))
+
+	locks := filepath.Join(be.Path, backend.Paths.Locks)
+	OK(t, os.Remove(locks))
+
+	problems, err = be.FsckStructure(false)
+	OK(t, err)
+	Equals(t, []string{"missing directory locks"}, problems)
+	_, err = os.Stat(locks)
+	Assert(t, os.IsNotExist(err), "locks directory was created without repair")
+
+	problems, err = be.FsckStructure(true)
+	OK(t, err)
+	Equals(t, []string{"missing directory locks (recreated)"}, problems)
+
+	fi, err := os.Stat(locks)
+	OK(t, err)
+	Assert(t, fi.IsDir(), "locks is not a directory")
+
+	problems, err = be.FsckStructure(false)
+	OK(t, err)
+	Equals(t, 0, len(problems))
+}
+
+func TestFsckStructureStray(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	stray := filepath.Join(be.Path, "stray")
+	shard := filepath.Join(be.Path, backend.Paths.Data, "zz")
+	OK(t, os.Mkdir(stray, 0700))
+	OK(t, os.Mkdir(shard, 0700))
+	OK(t, os.Mkdir(filepath.Join(be.Path, backend.Paths.Data, "ab"), 0700))
+
+	// a missing data directory is never recreated
+	index := filepath.Join(be.Path, backend.Paths.Index)
+	OK(t, os.Remove(index))
+
+	problems, err := be.FsckStructure(true)
+	OK(t, err)
+	Equals(t, []string{
+		"missing directory index",
+		"unexpected directory stray",
+		"unexpected directory data/zz",
+	}, problems)
+
+	for _, d := range []string{stray, shard} {
+		_, err = os.Stat(d)
+		OK(t, err)
+	}
+	_, err = os.Stat(index)
+	Assert(t, os.IsNotExist(err), "index directory was recreated")
+
+	OK(t, os.Mkdir(index, 0700))
+}
+
+func TestFsckStructureAccessLog(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	be.AccessLog = true
+
+	data := []byte("foo")
+	OK(t, be.Save(restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}, bytes.NewReader(data)))
+	OK(t, be.Close())
+
+	fi, err := os.Stat(filepath.Join(be.Path, "log"))
+	OK(t, err)
+	Assert(t, fi.IsDir(), "log is not a directory")
+
+	problems, err := be.FsckStructure(false)
+	OK(t, err)
+	Equals(t, 0, len(problems))
+}
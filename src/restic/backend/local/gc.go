@@ -0,0 +1,116 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"time"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// gcMarkerFile is the name of the marker file which exists while GC runs.
+const gcMarkerFile = "gc-in-progress"
+
+func (b *Local) gcMarker() string {
+	return filepath.Join(b.Path, gcMarkerFile)
+}
+
+// GCInterrupted returns true if a previous run of GC did not complete.
+func (b *Local) GCInterrupted() (bool, error) {
+	_, err := b.FS.Lstat(b.gcMarker())
+	if os.IsNotExist(errors.Cause(err)) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "Lstat")
+	}
+	return true, nil
+}
+
+// GC removes all content-addressed files for which reachable returns false.
+// reachable is called with the handle of each data, index and snapshot file,
+// so a caller which only tracks one type must return true for the others.
+// A marker file is written before the first file is removed and only removed
+// after the sweep completed, so an interrupted run can be detected with
+// GCInterrupted. Since a sweep only removes unreachable files, an
+// interrupted run is completed by calling GC again with the same predicate.
+// Files protected by the retention settings are skipped. GC refuses to run
+// on a backend opened with ReadOnly. If done is closed, GC stops and returns
+// an error, the marker is kept.
+func (b *Local) GC(reachable func(h restic.Handle) bool, done <-chan struct{}) (removed int, err error) {
+	debug.Log("GC")
+	if b.ReadOnly {
+		return 0, errors.New("GC on read-only backend")
+	}
+
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	interrupted, err := b.GCInterrupted()
+	if err != nil {
+		return 0, err
+	}
+	if interrupted {
+		debug.Log("previous GC was interrupted, resuming")
+	}
+
+	if err = b.writeGCMarker(); err != nil {
+		return 0, err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	for _, t := range []restic.FileType{restic.DataFile, restic.IndexFile, restic.SnapshotFile} {
+		for name := range b.List(t, stop) {
+			select {
+			case <-done:
+				return removed, errors.New("GC canceled")
+			default:
+			}
+
+			h := restic.Handle{Type: t, Name: name}
+			if reachable(h) {
+				continue
+			}
+
+			err := b.Remove(h)
+			if errors.Cause(err) == ErrRetention {
+				debug.Log("skipping %v/%v: %v", t, name, err)
+				continue
+			}
+			if err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	if err = b.FS.Remove(b.gcMarker()); err != nil {
+		return removed, errors.Wrap(err, "Remove")
+	}
+
+	debug.Log("GC removed %d files", removed)
+	return removed, nil
+}
+
+// writeGCMarker atomically creates the GC marker, which records the time the
+// run started.
+func (b *Local) writeGCMarker() error {
+	buf := []byte(time.Now().UTC().Format(time.RFC3339) + "\n")
+	tmpfile, _, err := b.copyToTempfile(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	if err = b.FS.Rename(tmpfile, b.gcMarker()); err != nil {
+		b.FS.Remove(tmpfile)
+		return errors.Wrap(err, "Rename")
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package local_test
+
+import (
+	"restic"
+	"testing"
+	"time"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestGC(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := saveRandom(t, be, restic.DataFile, 20)
+	saveRandom(t, be, restic.SnapshotFile, 4)
+
+	keep := make(map[string]bool)
+	for _, h := range data[:10] {
+		keep[h.Name] = true
+	}
+
+	// only data files are checked, the others are kept
+	types := make(map[restic.FileType]int)
+	reachable := func(h restic.Handle) bool {
+		types[h.Type]++
+		return h.Type != restic.DataFile || keep[h.Name]
+	}
+
+	// everything reachable is a no-op
+	removed, err := be.GC(func(restic.Handle) bool { return true }, nil)
+	OK(t, err)
+	Equals(t, 0, removed)
+
+	removed, err = be.GC(reachable, nil)
+	OK(t, err)
+	Equals(t, 10, removed)
+	Equals(t, map[restic.FileType]int{restic.DataFile: 20, restic.SnapshotFile: 4}, types)
+
+	interrupted, err := be.GCInterrupted()
+	OK(t, err)
+	Assert(t, !interrupted, "GC marker not removed")
+
+	Equals(t, 10, len(listNames(be, restic.DataFile)))
+	Equals(t, 4, len(listNames(be, restic.SnapshotFile)))
+	for _, h := range data[:10] {
+		ok, err := be.Test(h)
+		OK(t, err)
+		Assert(t, ok, "reachable file %v removed", h)
+	}
+}
+
+func TestGCInterrupted(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	saveRandom(t, be, restic.DataFile, 20)
+
+	// interrupt while the third file is removed
+	done := make(chan struct{})
+	calls := 0
+	removed, err := be.GC(func(restic.Handle) bool {
+		calls++
+		if calls == 3 {
+			close(done)
+		}
+		return false
+	}, done)
+	Assert(t, err != nil, "interrupted GC returned no error")
+	Equals(t, 3, removed)
+
+	interrupted, err := be.GCInterrupted()
+	OK(t, err)
+	Assert(t, interrupted, "interrupted GC not detected")
+
+	removed, err = be.GC(func(restic.Handle) bool { return false }, nil)
+	OK(t, err)
+	Equals(t, 17, removed)
+
+	interrupted, err = be.GCInterrupted()
+	OK(t, err)
+	Assert(t, !interrupted, "GC marker not removed after resume")
+	Equals(t, 0, len(listNames(be, restic.DataFile)))
+}
+
+func TestGCRetention(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	saveRandom(t, be, restic.DataFile, 5)
+	be.RetentionByType = map[restic.FileType]time.Duration{restic.DataFile: time.Hour}
+
+	removed, err := be.GC(func(restic.Handle) bool { return false }, nil)
+	OK(t, err)
+	Equals(t, 0, removed)
+	Equals(t, 5, len(listNames(be, restic.DataFile)))
+
+	be.ReadOnly = true
+	_, err = be.GC(func(restic.Handle) bool { return false }, nil)
+	Assert(t, err != nil, "GC ran on read-only backend")
+}
@@ -0,0 +1,158 @@
+package local
+
+import "restic/errors"
+
+// Arithmetic in GF(2^8) with the polynomial x^8+x^4+x^3+x^2+1, used for the
+// Reed-Solomon parity computed by writeParity.
+var (
+	gfExp [510]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+// gfInv returns the multiplicative inverse of a, which must not be zero.
+func gfInv(a byte) byte {
+	return gfExp[255-gfLog[a]]
+}
+
+// gfMulAdd adds c*src to dst.
+func gfMulAdd(dst []byte, c byte, src []byte) {
+	if c == 0 {
+		return
+	}
+	for i, v := range src {
+		dst[i] ^= gfMul(c, v)
+	}
+}
+
+// rsMatrixRow returns row r of the systematic generator matrix for k data
+// shards: the unit vector for the data shards, and a row of a Cauchy matrix
+// for the parity shards. Every k rows of this matrix are linearly
+// independent, so any k shards suffice to reconstruct the data.
+func rsMatrixRow(k, r int) []byte {
+	row := make([]byte, k)
+	if r < k {
+		row[r] = 1
+		return row
+	}
+
+	for j := range row {
+		row[j] = gfInv(byte(r) ^ byte(j))
+	}
+	return row
+}
+
+// rsEncode computes the parity shards for the data shards, which must have
+// the same size.
+func rsEncode(data [][]byte, parity [][]byte) {
+	k := len(data)
+	for i, p := range parity {
+		for n := range p {
+			p[n] = 0
+		}
+
+		row := rsMatrixRow(k, k+i)
+		for j, d := range data {
+			gfMulAdd(p, row[j], d)
+		}
+	}
+}
+
+// rsReconstruct fills in the missing (nil) data shards in shards, which
+// holds the k data shards followed by the parity shards. At least k shards
+// of size shardSize must be present.
+func rsReconstruct(shards [][]byte, k, shardSize int) error {
+	var rows [][]byte
+	var present [][]byte
+	for r, s := range shards {
+		if s != nil && len(rows) < k {
+			rows = append(rows, rsMatrixRow(k, r))
+			present = append(present, s)
+		}
+	}
+
+	if len(rows) < k {
+		return errors.Errorf("%d of %d shards required", len(rows), k)
+	}
+
+	inv, err := gfInvert(rows)
+	if err != nil {
+		return err
+	}
+
+	for j := 0; j < k; j++ {
+		if shards[j] != nil {
+			continue
+		}
+
+		buf := make([]byte, shardSize)
+		for l, s := range present {
+			gfMulAdd(buf, inv[j][l], s)
+		}
+		shards[j] = buf
+	}
+
+	return nil
+}
+
+// gfInvert returns the inverse of the square matrix m.
+func gfInvert(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	a := make([][]byte, n)
+	inv := make([][]byte, n)
+	for i := range m {
+		a[i] = append([]byte{}, m[i]...)
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && a[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return nil, errors.New("matrix is singular")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		scale := gfInv(a[col][col])
+		for j := 0; j < n; j++ {
+			a[col][j] = gfMul(a[col][j], scale)
+			inv[col][j] = gfMul(inv[col][j], scale)
+		}
+
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			gfMulAdd(a[r], f, a[col])
+			gfMulAdd(inv[r], f, inv[col])
+		}
+	}
+
+	return inv, nil
+}
@@ -0,0 +1,58 @@
+package local
+
+import (
+	"crypto"
+	"encoding/hex"
+	"hash"
+	"io"
+	"restic"
+
+	// register the hash functions which are available by default
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"restic/errors"
+)
+
+// ErrInvalidHashName is returned when the name of a content-addressed file
+// is not a hex-encoded digest of the configured hash function.
+var ErrInvalidHashName = errors.New("name is not a valid digest")
+
+// hashFunc returns the hash function used for content-addressed files.
+func (b *Local) hashFunc() crypto.Hash {
+	if b.Hash == 0 {
+		return crypto.SHA256
+	}
+	return b.Hash
+}
+
+func (b *Local) newHash() hash.Hash {
+	return b.hashFunc().New()
+}
+
+// checkHashName returns ErrInvalidHashName if the name of h cannot be a
+// digest computed by the configured hash function.
+func (b *Local) checkHashName(h restic.Handle) error {
+	size := b.hashFunc().Size()
+	if len(h.Name) != 2*size {
+		return errors.Wrapf(ErrInvalidHashName, "%v has length %d, want %d", h, len(h.Name), 2*size)
+	}
+
+	if _, err := hex.DecodeString(h.Name); err != nil {
+		return errors.Wrapf(ErrInvalidHashName, "%v", h)
+	}
+
+	return nil
+}
+
+// Digest returns the name under which the data read from rd is stored as a
+// content-addressed file, which is the hex-encoded digest of the configured
+// hash function.
+func (b *Local) Digest(rd io.Reader) (string, error) {
+	hash := b.newHash()
+	if _, err := io.Copy(hash, rd); err != nil {
+		return "", errors.Wrap(err, "Read")
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
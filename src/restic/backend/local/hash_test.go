@@ -0,0 +1,65 @@
+package local_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+var hashTests = []struct {
+	hash crypto.Hash
+	sum  func([]byte) []byte
+}{
+	{0, func(data []byte) []byte { s := sha256.Sum256(data); return s[:] }},
+	{crypto.SHA256, func(data []byte) []byte { s := sha256.Sum256(data); return s[:] }},
+	{crypto.SHA512, func(data []byte) []byte { s := sha512.Sum512(data); return s[:] }},
+}
+
+func TestHashFunc(t *testing.T) {
+	for _, test := range hashTests {
+		be, cleanup := local.TestBackend(t)
+		be.Hash = test.hash
+		be.VerifyHash = true
+
+		data := Random(23, 500)
+		name, err := be.Digest(bytes.NewReader(data))
+		OK(t, err)
+		Equals(t, hex.EncodeToString(test.sum(data)), name)
+
+		h := restic.Handle{Type: restic.DataFile, Name: name}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		OK(t, be.Verify(h))
+
+		// a name with the wrong length is rejected before anything is written
+		other := restic.Hash(data).String() + "00"
+		err = be.Save(restic.Handle{Type: restic.DataFile, Name: other}, bytes.NewReader(data))
+		Assert(t, errors.Cause(err) == local.ErrInvalidHashName,
+			"%v: expected ErrInvalidHashName for long name, got %v", test.hash, err)
+
+		// as is a name which is not hex
+		notHex := "zz" + name[2:]
+		err = be.Verify(restic.Handle{Type: restic.DataFile, Name: notHex})
+		Assert(t, errors.Cause(err) == local.ErrInvalidHashName,
+			"%v: expected ErrInvalidHashName for non-hex name, got %v", test.hash, err)
+
+		cleanup()
+	}
+}
+
+func TestHashFuncUnavailable(t *testing.T) {
+	dir, err := ioutil.TempDir(TestTempDir, "restic-test-local-")
+	OK(t, err)
+	defer RemoveAll(t, dir)
+
+	_, err = local.Create(local.Config{Path: dir, Hash: crypto.BLAKE2b_256})
+	Assert(t, err != nil, "Create with unavailable hash function succeeded")
+}
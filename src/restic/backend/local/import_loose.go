@@ -0,0 +1,160 @@
+package local
+
+import (
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"restic"
+	"sort"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ImportLoose saves the content-addressed files found below srcDir, e.g.
+// recovered by a data recovery tool, in the repository. A file is imported
+// if it ends with a footer (see Footer) of a content-addressed type, which
+// is stripped, or if its name is the hash of its content, which is stored as
+// a data file. The name in the repository is the hash of the content, so
+// each imported file is valid. Other files, and those which exist in the
+// repository already, are skipped and logged. The files are saved like with
+// Save, srcDir is not modified. If done is closed, ImportLoose stops and
+// returns an error.
+func (b *Local) ImportLoose(srcDir string, done <-chan struct{}) (imported, skipped int, err error) {
+	debug.Log("ImportLoose %v", srcDir)
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, 0, err
+	}
+
+	files, err := b.looseFiles(srcDir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, fn := range files {
+		select {
+		case <-done:
+			return imported, skipped, errors.New("ImportLoose canceled")
+		default:
+		}
+
+		ok, err := b.importLooseFile(fn)
+		if err != nil {
+			return imported, skipped, err
+		}
+
+		if ok {
+			imported++
+		} else {
+			skipped++
+		}
+	}
+
+	return imported, skipped, nil
+}
+
+// looseFiles returns the files below dir, sorted by name.
+func (b *Local) looseFiles(dir string) ([]string, error) {
+	entries, err := readdir(b.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, fi := range entries {
+		fn := filepath.Join(dir, fi.Name())
+		switch {
+		case fi.IsDir():
+			sub, err := b.looseFiles(fn)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+		case isFile(fi):
+			files = append(files, fn)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// importLooseFile saves the file fn in the repository and returns true, or
+// false if it was skipped.
+func (b *Local) importLooseFile(fn string) (bool, error) {
+	f, err := b.FS.Open(fn)
+	if err != nil {
+		return false, errors.Wrap(err, "Open")
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false, errors.Wrap(err, "Stat")
+	}
+
+	t, size, footer, err := looseContent(f, fi.Size())
+	if err != nil {
+		return false, err
+	}
+
+	hash := b.newHash()
+	if _, err = io.Copy(hash, io.LimitReader(f, size)); err != nil {
+		return false, errors.Wrap(err, "Read")
+	}
+	h := restic.Handle{Type: t, Name: hex.EncodeToString(hash.Sum(nil))}
+
+	if !footer && filepath.Base(fn) != h.Name {
+		debug.Log("skipping %v, not a content-addressed file", fn)
+		return false, nil
+	}
+
+	exists, err := b.Test(h)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		debug.Log("skipping %v, %v exists", fn, h)
+		return false, nil
+	}
+
+	if _, err = f.Seek(0, 0); err != nil {
+		return false, errors.Wrap(err, "Seek")
+	}
+
+	if err = b.Save(h, io.LimitReader(f, size)); err != nil {
+		return false, err
+	}
+
+	debug.Log("imported %v as %v", fn, h)
+	return true, nil
+}
+
+// looseContent returns the type and the size of the content of the file f
+// with size bytes. If f does not end with the footer of a content-addressed
+// type, it is assumed to be a data file without footer. The offset of f is
+// reset to the start of the file.
+func looseContent(f File, size int64) (t restic.FileType, length int64, footer bool, err error) {
+	if size < FooterSize {
+		return restic.DataFile, size, false, nil
+	}
+
+	if _, err := f.Seek(size-FooterSize, 0); err != nil {
+		return "", 0, false, errors.Wrap(err, "Seek")
+	}
+
+	buf := make([]byte, FooterSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return "", 0, false, errors.Wrap(err, "ReadFull")
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", 0, false, errors.Wrap(err, "Seek")
+	}
+
+	if t, length, ok := DecodeFooter(buf, size); ok && isContentAddressed(t) {
+		return t, length, true, nil
+	}
+
+	return restic.DataFile, size, false, nil
+}
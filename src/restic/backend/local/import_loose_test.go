@@ -0,0 +1,66 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestImportLoose(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	src, err := ioutil.TempDir(TestTempDir, "restic-local-test-")
+	OK(t, err)
+	defer RemoveAll(t, src)
+	OK(t, os.Mkdir(filepath.Join(src, "recup_dir.1"), 0700))
+
+	write := func(name string, data []byte) {
+		OK(t, ioutil.WriteFile(filepath.Join(src, name), data, 0600))
+	}
+
+	// a data file named by its hash
+	blob := Random(23, 1000)
+	write(restic.Hash(blob).String(), blob)
+
+	// a snapshot with a footer, under another name
+	snapshot := Random(5, 300)
+	write(filepath.Join("recup_dir.1", "f0001.bin"), append(append([]byte{}, snapshot...), encodeFooter(restic.SnapshotFile, int64(len(snapshot)))...))
+
+	// a data file which exists in the repository already
+	dup, data := saveData(t, be, 7, 500)
+	write(dup.Name, data)
+
+	// junk
+	write("junk", Random(8, 100))
+	write(filepath.Join("recup_dir.1", restic.Hash(blob).String()[:10]), Random(9, 100))
+	other := Random(10, 200)
+	write(restic.Hash(Random(11, 200)).String(), other)
+
+	imported, skipped, err := be.ImportLoose(src, nil)
+	OK(t, err)
+	Equals(t, 2, imported)
+	Equals(t, 4, skipped)
+
+	Equals(t, blob, load(t, be, restic.Handle{Type: restic.DataFile, Name: restic.Hash(blob).String()}, 0, 0))
+	Equals(t, snapshot, load(t, be, restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash(snapshot).String()}, 0, 0))
+
+	ok, err := be.Test(restic.Handle{Type: restic.DataFile, Name: restic.Hash(Random(11, 200)).String()})
+	OK(t, err)
+	Assert(t, !ok, "file with wrong content was imported")
+
+	// importing again skips everything
+	imported, skipped, err = be.ImportLoose(src, nil)
+	OK(t, err)
+	Equals(t, 0, imported)
+	Equals(t, 6, skipped)
+
+	done := make(chan struct{})
+	close(done)
+	_, _, err = be.ImportLoose(src, done)
+	Assert(t, err != nil, "canceled ImportLoose returned no error")
+}
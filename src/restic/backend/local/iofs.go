@@ -0,0 +1,259 @@
+// +build go1.16
+
+package local
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ioFS is a read-only backend reading a repository from an fs.FS, returned
+// by OpenFS.
+type ioFS struct {
+	fsys fs.FS
+	root string
+}
+
+// OpenFS returns a read-only backend for the repository in the directory
+// root of fsys, e.g. a repository embedded in the binary or stored in a zip
+// file. The directories for data, snapshots, index and keys must exist.
+// All methods which modify the repository return ErrReadOnlyView.
+func OpenFS(fsys fs.FS, root string) (restic.Backend, error) {
+	debug.Log("OpenFS %v", root)
+	if root != "" && root != "." {
+		sub, err := fs.Sub(fsys, root)
+		if err != nil {
+			return nil, errors.Wrap(err, "Sub")
+		}
+		fsys = sub
+	}
+
+	for _, dir := range []string{
+		backend.Paths.Data,
+		backend.Paths.Snapshots,
+		backend.Paths.Index,
+		backend.Paths.Keys,
+	} {
+		if _, err := fs.Stat(fsys, dir); err != nil {
+			return nil, errors.Wrap(err, "OpenFS")
+		}
+	}
+
+	return &ioFS{fsys: fsys, root: root}, nil
+}
+
+// ioFSDir returns the directory holding the files of type t in an fs.FS,
+// which always uses forward slashes.
+func ioFSDir(t restic.FileType) string {
+	switch t {
+	case restic.DataFile:
+		return backend.Paths.Data
+	case restic.SnapshotFile:
+		return backend.Paths.Snapshots
+	case restic.IndexFile:
+		return backend.Paths.Index
+	case restic.LockFile:
+		return backend.Paths.Locks
+	case restic.KeyFile:
+		return backend.Paths.Keys
+	default:
+		return "."
+	}
+}
+
+// find returns the path of the file for h.
+func (b *ioFS) find(h restic.Handle) (string, error) {
+	if err := h.Valid(); err != nil {
+		return "", err
+	}
+
+	var name string
+	switch h.Type {
+	case restic.ConfigFile:
+		name = backend.Paths.Config
+	case restic.DataFile:
+		name = path.Join(backend.Paths.Data, h.Name[:2], h.Name)
+	default:
+		name = path.Join(ioFSDir(h.Type), h.Name)
+	}
+
+	_, err := fs.Stat(b.fsys, name)
+	if err == nil || h.Type != restic.SnapshotFile || !os.IsNotExist(err) {
+		return name, err
+	}
+
+	// snapshots may be stored in date buckets
+	for _, dir := range b.snapshotBuckets() {
+		p := path.Join(dir, h.Name)
+		if _, e := fs.Stat(b.fsys, p); e == nil {
+			return p, nil
+		}
+	}
+
+	return name, err
+}
+
+// snapshotBuckets returns the date bucket directories below the snapshots
+// directory.
+func (b *ioFS) snapshotBuckets() (dirs []string) {
+	years, _ := fs.ReadDir(b.fsys, backend.Paths.Snapshots)
+	for _, year := range years {
+		if !year.IsDir() || !isNumber(year.Name(), 4) {
+			continue
+		}
+
+		dir := path.Join(backend.Paths.Snapshots, year.Name())
+		months, _ := fs.ReadDir(b.fsys, dir)
+		for _, month := range months {
+			if month.IsDir() && isNumber(month.Name(), 2) {
+				dirs = append(dirs, path.Join(dir, month.Name()))
+			}
+		}
+	}
+
+	return dirs
+}
+
+func (b *ioFS) Location() string {
+	return b.root
+}
+
+func (b *ioFS) Test(h restic.Handle) (bool, error) {
+	_, err := b.find(h)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "Stat")
+	}
+	return true, nil
+}
+
+func (b *ioFS) Remove(h restic.Handle) error {
+	return errors.Wrapf(ErrReadOnlyView, "Remove %v", h)
+}
+
+func (b *ioFS) Close() error {
+	return nil
+}
+
+func (b *ioFS) Save(h restic.Handle, rd io.Reader) error {
+	return errors.Wrapf(ErrReadOnlyView, "Save %v", h)
+}
+
+// Load returns a reader for the file at h. If the file does not implement
+// io.Seeker, the data before offset is read and discarded.
+func (b *ioFS) Load(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	debug.Log("Load %v, length %v, offset %v", h, length, offset)
+	if offset < 0 {
+		return nil, errors.New("offset is negative")
+	}
+
+	name, err := b.find(h)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := b.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		if s, ok := f.(io.Seeker); ok {
+			_, err = s.Seek(offset, io.SeekStart)
+		} else {
+			_, err = io.CopyN(ioutil.Discard, f, offset)
+			if err == io.EOF {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "Seek")
+		}
+	}
+
+	if length > 0 {
+		return backend.LimitReadCloser(f, int64(length)), nil
+	}
+
+	return f, nil
+}
+
+func (b *ioFS) Stat(h restic.Handle) (restic.FileInfo, error) {
+	name, err := b.find(h)
+	if err != nil {
+		return restic.FileInfo{}, errors.Wrap(err, "Stat")
+	}
+
+	fi, err := fs.Stat(b.fsys, name)
+	if err != nil {
+		return restic.FileInfo{}, errors.Wrap(err, "Stat")
+	}
+
+	return restic.FileInfo{Size: fi.Size()}, nil
+}
+
+// files returns the names of the regular files in dir.
+func (b *ioFS) files(dir string) (names []string) {
+	entries, err := fs.ReadDir(b.fsys, dir)
+	if err != nil {
+		debug.Log("unable to read %v: %v", dir, err)
+		return nil
+	}
+
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names
+}
+
+func (b *ioFS) List(t restic.FileType, done <-chan struct{}) <-chan string {
+	debug.Log("List %v", t)
+	ch := make(chan string)
+
+	go func() {
+		defer close(ch)
+
+		var dirs []string
+		switch t {
+		case restic.ConfigFile:
+		case restic.DataFile:
+			shards, _ := fs.ReadDir(b.fsys, backend.Paths.Data)
+			for _, shard := range shards {
+				if shard.IsDir() {
+					dirs = append(dirs, path.Join(backend.Paths.Data, shard.Name()))
+				}
+			}
+		case restic.SnapshotFile:
+			dirs = append([]string{backend.Paths.Snapshots}, b.snapshotBuckets()...)
+		default:
+			dirs = []string{ioFSDir(t)}
+		}
+
+		for _, dir := range dirs {
+			for _, name := range b.files(dir) {
+				select {
+				case ch <- name:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
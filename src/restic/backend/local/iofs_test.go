@@ -0,0 +1,134 @@
+// +build go1.16
+
+package local_test
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"restic"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+// noSeekFS wraps an fs.FS and hides the Seek method of the files.
+type noSeekFS struct {
+	fs.FS
+}
+
+type noSeekFile struct {
+	fs.File
+}
+
+func (f noSeekFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// directories must still support ReadDir
+	if fi, err := file.Stat(); err == nil && fi.IsDir() {
+		return file, nil
+	}
+	return noSeekFile{file}, nil
+}
+
+func testRepoFS(data []byte) (fstest.MapFS, string) {
+	id := restic.Hash(data).String()
+	return fstest.MapFS{
+		"repo/config":                       {Data: []byte("config")},
+		"repo/data/" + id[:2] + "/" + id:    {Data: data},
+		"repo/index/idx":                    {Data: []byte("index")},
+		"repo/keys/key":                     {Data: []byte("key")},
+		"repo/snapshots/snap":               {Data: []byte("snapshot")},
+		"repo/snapshots/2016/09/bucketed":   {Data: []byte("bucketed snapshot")},
+		"repo/locks/lock":                   {Data: []byte("lock")},
+		"repo/data/" + id[:2] + "/notafile": {Mode: fs.ModeDir},
+	}, id
+}
+
+func readAll(t testing.TB, rd io.ReadCloser) []byte {
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	return buf
+}
+
+func TestOpenFS(t *testing.T) {
+	data := Random(23, 1000)
+	mapfs, id := testRepoFS(data)
+
+	for _, fsys := range []fs.FS{mapfs, noSeekFS{mapfs}} {
+		be, err := local.OpenFS(fsys, "repo")
+		OK(t, err)
+
+		h := restic.Handle{Type: restic.DataFile, Name: id}
+		ok, err := be.Test(h)
+		OK(t, err)
+		Assert(t, ok, "data file not found")
+
+		fi, err := be.Stat(h)
+		OK(t, err)
+		Equals(t, int64(len(data)), fi.Size)
+
+		rd, err := be.Load(h, 0, 0)
+		OK(t, err)
+		Equals(t, data, readAll(t, rd))
+
+		rd, err = be.Load(h, 100, 200)
+		OK(t, err)
+		Equals(t, data[200:300], readAll(t, rd))
+
+		rd, err = be.Load(h, 0, 990)
+		OK(t, err)
+		Equals(t, data[990:], readAll(t, rd))
+
+		rd, err = be.Load(restic.Handle{Type: restic.ConfigFile}, 0, 0)
+		OK(t, err)
+		Equals(t, []byte("config"), readAll(t, rd))
+
+		rd, err = be.Load(restic.Handle{Type: restic.SnapshotFile, Name: "bucketed"}, 0, 0)
+		OK(t, err)
+		Equals(t, []byte("bucketed snapshot"), readAll(t, rd))
+
+		missing := restic.Handle{Type: restic.IndexFile, Name: "missing"}
+		ok, err = be.Test(missing)
+		OK(t, err)
+		Assert(t, !ok, "missing file found")
+		_, err = be.Load(missing, 0, 0)
+		Assert(t, err != nil, "Load of a missing file succeeded")
+
+		for tpe, want := range map[restic.FileType][]string{
+			restic.DataFile:     {id},
+			restic.IndexFile:    {"idx"},
+			restic.KeyFile:      {"key"},
+			restic.LockFile:     {"lock"},
+			restic.SnapshotFile: {"bucketed", "snap"},
+		} {
+			var names []string
+			for name := range be.List(tpe, nil) {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			Equals(t, want, names)
+		}
+
+		err = be.Save(h, nil)
+		Assert(t, errors.Cause(err) == local.ErrReadOnlyView, "expected ErrReadOnlyView, got %v", err)
+		err = be.Remove(h)
+		Assert(t, errors.Cause(err) == local.ErrReadOnlyView, "expected ErrReadOnlyView, got %v", err)
+		OK(t, be.Close())
+	}
+}
+
+func TestOpenFSInvalid(t *testing.T) {
+	mapfs, _ := testRepoFS(Random(5, 100))
+
+	_, err := local.OpenFS(mapfs, "other")
+	Assert(t, err != nil, "OpenFS succeeded for a missing repository")
+}
@@ -0,0 +1,191 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// journalEntry records a save between writing the tempfile and renaming it
+// into place. The paths are relative to the repository.
+type journalEntry struct {
+	Type   restic.FileType `json:"type"`
+	Name   string          `json:"name"`
+	Temp   string          `json:"temp"`
+	Target string          `json:"target"`
+	Size   int64           `json:"size"`
+}
+
+func (b *Local) journalDir() string {
+	return filepath.Join(b.Path, localPaths.Journal)
+}
+
+// writeJournal records that tmpfile is about to be renamed to filename for
+// h and returns the name of the journal entry. The entry is written
+// atomically and synced.
+func (b *Local) writeJournal(h restic.Handle, tmpfile, filename string) (string, error) {
+	fi, err := b.FS.Stat(tmpfile)
+	if err != nil {
+		return "", errors.Wrap(err, "Stat")
+	}
+
+	entry := journalEntry{Type: h.Type, Name: h.Name, Size: fi.Size()}
+	if entry.Temp, err = filepath.Rel(b.Path, tmpfile); err != nil {
+		return "", errors.Wrap(err, "Rel")
+	}
+	if entry.Target, err = filepath.Rel(b.Path, filename); err != nil {
+		return "", errors.Wrap(err, "Rel")
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal")
+	}
+
+	if err = b.createDir(b.journalDir()); err != nil {
+		return "", err
+	}
+
+	tmp, _, err := b.copyToTempfile(bytes.NewReader(buf))
+	if err != nil {
+		return "", err
+	}
+
+	fn := filepath.Join(b.journalDir(), filepath.Base(tmpfile)+".json")
+	if err = b.renameSync(tmp, fn); err != nil {
+		b.FS.Remove(tmp)
+		return "", err
+	}
+
+	return fn, nil
+}
+
+// clearJournal removes the journal entry fn after the save has finished. A
+// remaining entry is harmless, RecoverJournal removes it.
+func (b *Local) clearJournal(fn string) {
+	if err := b.FS.Remove(fn); err != nil {
+		debug.Log("unable to remove journal entry %v: %v", fn, err)
+	}
+}
+
+// RecoverJournal processes the entries left in journal/ by saves which
+// were interrupted, and returns the number of entries. If the tempfile is
+// still there, it is renamed into place if it is intact (it has the recorded
+// size and, for content-addressed files, matches the name) and the target
+// does not exist yet, and removed otherwise. Afterwards, the entry is
+// removed.
+func (b *Local) RecoverJournal() (int, error) {
+	debug.Log("RecoverJournal")
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	names, err := listDir(b.FS, b.journalDir())
+	if os.IsNotExist(errors.Cause(err)) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		fn := filepath.Join(b.journalDir(), name)
+		if err = b.replayJournal(fn); err != nil {
+			return recovered, err
+		}
+
+		b.clearJournal(fn)
+		recovered++
+	}
+
+	return recovered, nil
+}
+
+// replayJournal completes or rolls back the save recorded in fn.
+func (b *Local) replayJournal(fn string) error {
+	f, err := b.FS.Open(fn)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+
+	var entry journalEntry
+	err = json.NewDecoder(f).Decode(&entry)
+	f.Close()
+	if err != nil {
+		// the entry is written atomically, so this is not caused by a crash
+		return errors.Wrapf(err, "Decode %v", fn)
+	}
+
+	h := restic.Handle{Type: entry.Type, Name: entry.Name}
+	tmpfile := filepath.Join(b.Path, entry.Temp)
+	target := filepath.Join(b.Path, entry.Target)
+
+	fi, err := b.FS.Stat(tmpfile)
+	if os.IsNotExist(errors.Cause(err)) {
+		// the rename happened, make sure the file is read-only
+		debug.Log("save of %v was completed", h)
+		if fi, err = b.FS.Stat(target); err == nil && !b.NoReadOnly {
+			return setNewFileMode(b.FS, target, fi)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	if !b.journalIntact(h, tmpfile, fi, entry.Size) {
+		debug.Log("discarding interrupted save of %v", h)
+		return errors.Wrap(b.FS.Remove(tmpfile), "Remove")
+	}
+
+	if _, err = b.FS.Lstat(target); err == nil {
+		debug.Log("%v exists, discarding interrupted save", h)
+		return errors.Wrap(b.FS.Remove(tmpfile), "Remove")
+	}
+
+	debug.Log("completing interrupted save of %v", h)
+	if err = b.FS.MkdirAll(filepath.Dir(target), backend.Modes.Dir); err != nil {
+		return errors.Wrap(err, "MkdirAll")
+	}
+
+	if err = b.renameSync(tmpfile, target); err != nil {
+		return err
+	}
+
+	if b.NoReadOnly {
+		return nil
+	}
+
+	fi, err = b.FS.Stat(target)
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+	return setNewFileMode(b.FS, target, fi)
+}
+
+// journalIntact returns true if the tempfile fn with the file info fi for h
+// has the expected size and, for content-addressed files, matches the name.
+func (b *Local) journalIntact(h restic.Handle, fn string, fi os.FileInfo, size int64) bool {
+	if fi.Size() != size {
+		return false
+	}
+
+	if !isContentAddressed(h.Type) || b.checkHashName(h) != nil {
+		return true
+	}
+
+	id, err := b.hashFile(fn)
+	return err == nil && id == h.Name
+}
@@ -0,0 +1,109 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"syscall"
+	"testing"
+
+	"restic/backend"
+	. "restic/test"
+)
+
+// crashAfterRename returns a function for fakeFS.fail which fails all
+// operations after the nth rename succeeded.
+func crashAfterRename(n int) func(op, name string) error {
+	renames := 0
+	return func(op, name string) error {
+		if renames >= n {
+			return syscall.EIO
+		}
+		if op == "Rename" {
+			renames++
+		}
+		return nil
+	}
+}
+
+func journalEntries(t testing.TB, be *Local) int {
+	entries, err := ioutil.ReadDir(be.journalDir())
+	if os.IsNotExist(err) {
+		return 0
+	}
+	OK(t, err)
+	return len(entries)
+}
+
+func TestJournal(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.Journal = true
+
+	h, data := saveData(t, be, 23, 1000)
+	Equals(t, data, load(t, be, h, 0, 0))
+	Equals(t, 0, journalEntries(t, be))
+
+	n, err := be.RecoverJournal()
+	OK(t, err)
+	Equals(t, 0, n)
+}
+
+func TestJournalCrash(t *testing.T) {
+	// the renames are: journal entry and tempfile into place
+	for _, renames := range []int{1, 2} {
+		be, cleanup := TestBackend(t)
+		be.Journal = true
+
+		data := Random(23, 1000)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		be.FS = &fakeFS{FS: defaultFS, fail: crashAfterRename(renames)}
+		Assert(t, be.Save(h, bytes.NewReader(data)) != nil, "Save did not fail after %d renames", renames)
+		Equals(t, 1, journalEntries(t, be))
+
+		// the save is completed by Open
+		be2, err := Open(be.Config)
+		OK(t, err)
+		Equals(t, data, load(t, be2, h, 0, 0))
+		Equals(t, 0, journalEntries(t, be2))
+
+		fi, err := os.Stat(filename(be.Path, h.Type, h.Name))
+		OK(t, err)
+		Assert(t, fi.Mode()&0222 == 0, "file %v is writable", h)
+
+		cleanup()
+	}
+}
+
+func TestJournalDiscard(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.Journal = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	be.FS = &fakeFS{FS: defaultFS, fail: crashAfterRename(1)}
+	Assert(t, be.Save(h, bytes.NewReader(data)) != nil, "Save did not fail")
+
+	// damage the tempfile
+	tempdir := filepath.Join(be.Path, backend.Paths.Temp)
+	tmpfiles, err := listDir(defaultFS, tempdir)
+	OK(t, err)
+	Equals(t, 1, len(tmpfiles))
+	OK(t, ioutil.WriteFile(filepath.Join(tempdir, tmpfiles[0]), data[:500], 0600))
+
+	be.FS = defaultFS
+	n, err := be.RecoverJournal()
+	OK(t, err)
+	Equals(t, 1, n)
+	Equals(t, 0, journalEntries(t, be))
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "damaged file was saved")
+	tmpfiles, err = listDir(defaultFS, tempdir)
+	OK(t, err)
+	Equals(t, 0, len(tmpfiles))
+}
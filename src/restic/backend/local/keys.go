@@ -0,0 +1,49 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+)
+
+// RotateKeyFiles loads all key files and passes the name and content of each
+// to rewrite, which returns the new content (e.g. encrypted with a new master
+// key). Each file is replaced atomically, so an interrupted rotation leaves
+// every key file either in its old or its new state and can be resumed by
+// calling RotateKeyFiles again. Files for which rewrite returns the unchanged
+// content are not written. The first error returned by rewrite aborts the
+// rotation.
+func (b *Local) RotateKeyFiles(rewrite func(name string, old []byte) ([]byte, error)) error {
+	debug.Log("RotateKeyFiles")
+
+	done := make(chan struct{})
+	defer close(done)
+
+	for name := range b.List(restic.KeyFile, done) {
+		h := restic.Handle{Type: restic.KeyFile, Name: name}
+		old, err := backend.LoadAll(b, h)
+		if err != nil {
+			return err
+		}
+
+		buf, err := rewrite(name, old)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(buf, old) {
+			debug.Log("key file %v unchanged", h)
+			continue
+		}
+
+		if err = b.Replace(h, bytes.NewReader(buf)); err != nil {
+			return err
+		}
+
+		debug.Log("rotated key file %v", h)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"strings"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestRotateKeyFiles(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	keys := map[string]string{
+		"key1": "old secret 1",
+		"key2": "old secret 2",
+		"key3": "new secret 3",
+	}
+
+	for name, data := range keys {
+		OK(t, be.Save(restic.Handle{Type: restic.KeyFile, Name: name}, strings.NewReader(data)))
+	}
+
+	rewrite := func(name string, old []byte) ([]byte, error) {
+		return bytes.Replace(old, []byte("old"), []byte("new"), 1), nil
+	}
+
+	OK(t, be.RotateKeyFiles(rewrite))
+
+	for name, data := range keys {
+		buf, err := backend.LoadAll(be, restic.Handle{Type: restic.KeyFile, Name: name})
+		OK(t, err)
+		Equals(t, strings.Replace(data, "old", "new", 1), string(buf))
+	}
+
+	// a second rotation does not change anything
+	OK(t, be.RotateKeyFiles(func(name string, old []byte) ([]byte, error) {
+		buf, err := rewrite(name, old)
+		Assert(t, bytes.Equal(buf, old), "key file %v rewritten twice", name)
+		return buf, err
+	}))
+}
+
+func TestRotateKeyFilesError(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.KeyFile, Name: "key"}
+	OK(t, be.Save(h, strings.NewReader("old secret")))
+
+	err := be.RotateKeyFiles(func(name string, old []byte) ([]byte, error) {
+		return nil, errors.New("rewrite failed")
+	})
+	Assert(t, err != nil, "expected error not returned")
+
+	buf, err := backend.LoadAll(be, h)
+	OK(t, err)
+	Equals(t, "old secret", string(buf))
+}
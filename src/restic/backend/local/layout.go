@@ -0,0 +1,123 @@
+package local
+
+import (
+	"path"
+	"path/filepath"
+	"restic"
+	"sort"
+	"strings"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// layoutDirs sorts the directories of a layout by path.
+type layoutDirs []restic.LayoutDir
+
+func (s layoutDirs) Len() int           { return len(s) }
+func (s layoutDirs) Less(i, j int) bool { return s[i].Path < s[j].Path }
+func (s layoutDirs) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ExportLayout returns the directories of the repository with their modes,
+// and the sharding of the data directory. Files are not included. Together
+// with ImportLayout, this allows preparing the structure of a repository on
+// another host before the files are copied, e.g. when the directories use
+// modes other than the defaults.
+func (b *Local) ExportLayout() (restic.LayoutManifest, error) {
+	debug.Log("ExportLayout %v", b.Path)
+	m := restic.LayoutManifest{SnapshotBuckets: b.SnapshotBuckets}
+
+	var walk func(dir, rel string, depth int) error
+	walk = func(dir, rel string, depth int) error {
+		fi, err := b.FS.Lstat(dir)
+		if err != nil {
+			return errors.Wrap(err, "Lstat")
+		}
+		m.Dirs = append(m.Dirs, restic.LayoutDir{Path: rel, Mode: fi.Mode().Perm()})
+
+		// depth counts the levels below the data directory
+		if depth > m.ShardDepth {
+			m.ShardDepth = depth
+		}
+
+		entries, err := readdir(b.FS, dir)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+
+			subdepth := 0
+			switch {
+			case depth > 0:
+				subdepth = depth + 1
+			case rel == backend.Paths.Data:
+				subdepth = 1
+			}
+
+			if err := walk(filepath.Join(dir, e.Name()), path.Join(rel, e.Name()), subdepth); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(b.Path, ".", 0); err != nil {
+		return restic.LayoutManifest{}, err
+	}
+
+	sort.Sort(layoutDirs(m.Dirs))
+
+	return m, nil
+}
+
+// ImportLayout creates the directories of m in the repository and sets
+// their modes, existing directories are kept and only their mode is
+// changed. It is meant for a repository returned by Create, before any files
+// are saved, and refuses to run on a backend opened with ReadOnly.
+// SnapshotBuckets is enabled if it was set for the exported repository.
+// Paths which leave the repository directory are rejected before anything is
+// created.
+func (b *Local) ImportLayout(m restic.LayoutManifest) error {
+	debug.Log("ImportLayout %v, %d dirs", b.Path, len(m.Dirs))
+	if b.ReadOnly {
+		return errors.New("ImportLayout on read-only backend")
+	}
+
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	for _, d := range m.Dirs {
+		if d.Path == "" || path.IsAbs(d.Path) || path.Clean(d.Path) != d.Path ||
+			d.Path == ".." || strings.HasPrefix(d.Path, "../") {
+			return errors.Errorf("invalid directory %q in layout", d.Path)
+		}
+	}
+
+	for _, d := range m.Dirs {
+		dir := filepath.Join(b.Path, filepath.FromSlash(d.Path))
+		if err := b.FS.MkdirAll(dir, d.Mode); err != nil {
+			return errors.Wrap(err, "MkdirAll")
+		}
+
+		// the mode passed to MkdirAll is subject to the umask
+		if err := b.FS.Chmod(dir, d.Mode); err != nil {
+			return errors.Wrap(err, "Chmod")
+		}
+	}
+
+	if m.SnapshotBuckets {
+		b.SnapshotBuckets = true
+	}
+	b.buckets.reset()
+	b.hasBuckets = len(b.snapshotBucketDirs()) > 0
+	b.dirs.reset()
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"runtime"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestLayoutRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory modes are not supported on windows")
+	}
+
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.MaxShardEntries = 1
+	be.SnapshotBuckets = true
+
+	saveData(t, be, 23, 100)
+	for _, dir := range []string{"data/ab/c", "snapshots/2016/05"} {
+		OK(t, os.MkdirAll(filepath.Join(be.Path, filepath.FromSlash(dir)), 0700))
+	}
+	OK(t, os.Chmod(filepath.Join(be.Path, "data"), 0750))
+	OK(t, os.Chmod(filepath.Join(be.Path, "data", "ab"), 0711))
+	OK(t, os.Chmod(filepath.Join(be.Path, "keys"), 0500))
+	defer os.Chmod(filepath.Join(be.Path, "keys"), 0700)
+
+	m, err := be.ExportLayout()
+	OK(t, err)
+	Equals(t, 2, m.ShardDepth)
+	Equals(t, ".", m.Dirs[0].Path)
+
+	modes := make(map[string]os.FileMode)
+	for _, d := range m.Dirs {
+		modes[d.Path] = d.Mode
+	}
+	Equals(t, os.FileMode(0750), modes["data"])
+	Equals(t, os.FileMode(0711), modes["data/ab"])
+	Equals(t, os.FileMode(0700), modes["data/ab/c"])
+	Equals(t, os.FileMode(0500), modes["keys"])
+	_, ok := modes["snapshots/2016/05"]
+	Assert(t, ok, "bucket directory not exported")
+
+	other, cleanup2 := TestBackend(t)
+	defer cleanup2()
+	OK(t, other.ImportLayout(m))
+	defer os.Chmod(filepath.Join(other.Path, "keys"), 0700)
+
+	m2, err := other.ExportLayout()
+	OK(t, err)
+	Equals(t, m.Dirs, m2.Dirs)
+	Equals(t, m.ShardDepth, m2.ShardDepth)
+	Assert(t, other.SnapshotBuckets, "SnapshotBuckets not enabled by import")
+}
+
+func TestImportLayoutInvalid(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	for _, p := range []string{"", "/data", "../data", "data/../../x", "data/"} {
+		m := restic.LayoutManifest{Dirs: []restic.LayoutDir{{Path: p, Mode: 0700}}}
+		err := be.ImportLayout(m)
+		Assert(t, err != nil, "invalid path %q accepted", p)
+	}
+}
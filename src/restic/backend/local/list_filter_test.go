@@ -0,0 +1,50 @@
+package local_test
+
+import (
+	"restic"
+	"sort"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func listFiltered(be *local.Local, t restic.FileType, match func(string) bool) []string {
+	names := []string{}
+	for name := range be.ListFilter(t, match, nil) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestListFilter(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	handles := saveRandom(t, be, restic.DataFile, 50)
+
+	prefix := handles[0].Name[:1]
+	var want []string
+	for _, h := range handles {
+		if strings.HasPrefix(h.Name, prefix) {
+			want = append(want, h.Name)
+		}
+	}
+	sort.Strings(want)
+
+	Equals(t, want, listFiltered(be, restic.DataFile, func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	}))
+
+	set := map[string]bool{handles[3].Name: true, handles[7].Name: true, "missing": true}
+	want = []string{handles[3].Name, handles[7].Name}
+	sort.Strings(want)
+	Equals(t, want, listFiltered(be, restic.DataFile, func(name string) bool {
+		return set[name]
+	}))
+
+	Equals(t, []string{}, listFiltered(be, restic.DataFile, func(string) bool { return false }))
+	Equals(t, 50, len(listNames(be, restic.DataFile)))
+}
@@ -0,0 +1,103 @@
+package local
+
+import (
+	"path/filepath"
+	"restic"
+	"sort"
+
+	"restic/debug"
+)
+
+// ListFrom returns a channel that yields the names of all files of type t
+// which sort lexicographically after the name after, in sorted order. A
+// caller that was interrupted after processing name X can resume the listing
+// by passing X. For data files, shard directories which only contain names
+// up to after are not read at all.
+func (b *Local) ListFrom(t restic.FileType, after string, done <-chan struct{}) <-chan string {
+	debug.Log("ListFrom %v after %q", t, after)
+	ch := make(chan string)
+	if b.checkPermitted(t) != nil {
+		close(ch)
+		return ch
+	}
+
+	dir := dirname(b.Path, t, "")
+
+	var shards []string
+	var items []string
+	packed := b.packedShards(t)
+	if t == restic.DataFile {
+		names, err := readdirnames(b.FS, dir)
+		if err != nil {
+			close(ch)
+			return ch
+		}
+
+		// packed files may be in shards which do not exist on disk
+		for name := range packed {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+
+		for _, name := range names {
+			if len(after) >= len(name) && name < after[:len(name)] {
+				continue
+			}
+			shards = append(shards, name)
+		}
+		sort.Strings(shards)
+	} else {
+		lister := listDir
+		if t == restic.SnapshotFile {
+			lister = b.listSnapshots
+		}
+
+		var err error
+		items, err = lister(b.FS, dir)
+		if err != nil {
+			close(ch)
+			return ch
+		}
+		items = append(b.decodeNames(items), b.listPacked(t)...)
+		sort.Strings(items)
+	}
+
+	send := func(names []string) bool {
+		for _, name := range names {
+			if name <= after {
+				continue
+			}
+
+			select {
+			case ch <- name:
+			case <-done:
+				return false
+			}
+		}
+		return true
+	}
+
+	go func() {
+		defer close(ch)
+		if t != restic.DataFile {
+			send(items)
+			return
+		}
+
+		for _, shard := range shards {
+			names, err := b.shardFiles(filepath.Join(dir, shard))
+			if err != nil && len(packed[shard]) == 0 {
+				continue
+			}
+
+			names = append(names, packed[shard]...)
+			sort.Strings(names)
+			if !send(names) {
+				return
+			}
+		}
+	}()
+
+	return ch
+}
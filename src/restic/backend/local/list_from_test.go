@@ -0,0 +1,74 @@
+package local
+
+import (
+	"bytes"
+	"path/filepath"
+	"restic"
+	"sort"
+	"testing"
+
+	. "restic/test"
+)
+
+func collect(ch <-chan string) []string {
+	names := []string{}
+	for name := range ch {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestListFrom(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var all []string
+	for i := 0; i < 40; i++ {
+		data := Random(i, 100)
+		id := restic.Hash(data)
+		OK(t, be.Save(restic.Handle{Type: restic.DataFile, Name: id.String()}, bytes.NewReader(data)))
+		all = append(all, id.String())
+	}
+	sort.Strings(all)
+
+	Equals(t, all, collect(be.ListFrom(restic.DataFile, "", nil)))
+
+	for _, i := range []int{0, 7, 20, 39} {
+		after := all[i]
+
+		opened := make(map[string]bool)
+		be.FS = &fakeFS{FS: defaultFS, fail: func(op, name string) error {
+			if op == "Open" {
+				opened[filepath.Base(name)] = true
+			}
+			return nil
+		}}
+
+		// drain the channel to be sure that all shards have been read
+		names := collect(be.ListFrom(restic.DataFile, after, nil))
+		Equals(t, all[i+1:], names)
+
+		for shard := range opened {
+			Assert(t, shard == "data" || shard >= after[:2],
+				"shard %v was read although it was already consumed", shard)
+		}
+	}
+
+	Equals(t, 0, len(collect(be.ListFrom(restic.DataFile, "g", nil))))
+}
+
+func TestListFromSnapshots(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var all []string
+	for i := 0; i < 5; i++ {
+		data := Random(i, 50)
+		id := restic.Hash(data)
+		OK(t, be.Save(restic.Handle{Type: restic.SnapshotFile, Name: id.String()}, bytes.NewReader(data)))
+		all = append(all, id.String())
+	}
+	sort.Strings(all)
+
+	Equals(t, all[2:], collect(be.ListFrom(restic.SnapshotFile, all[1], nil)))
+}
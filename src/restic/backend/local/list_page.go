@@ -0,0 +1,126 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ListPage returns up to limit names of files of type t which sort after
+// cursor, in lexicographic order, and the cursor for the next page. The
+// first page is returned for an empty cursor, nextCursor is empty when there
+// are no more files. Data files are read shard by shard, the cursor (the
+// last name returned) also selects the shard to continue with, so only the
+// shards up to the end of the page are read.
+func (b *Local) ListPage(t restic.FileType, cursor string, limit int) (names []string, nextCursor string, err error) {
+	debug.Log("ListPage %v, cursor %q, limit %v", t, cursor, limit)
+	if err := (restic.Handle{Type: t, Name: "x"}).Valid(); err != nil {
+		return nil, "", err
+	}
+
+	if err := b.checkPermitted(t); err != nil {
+		return nil, "", err
+	}
+
+	if t == restic.ConfigFile {
+		return nil, "", errors.New("config file cannot be listed")
+	}
+
+	if limit <= 0 {
+		return nil, "", errors.Errorf("invalid limit %d", limit)
+	}
+
+	if t == restic.DataFile {
+		names, err = b.dataPage(cursor, limit+1)
+	} else {
+		names = b.sortedNames(t)
+		names = names[sort.SearchStrings(names, cursor):]
+		if len(names) > 0 && names[0] == cursor {
+			names = names[1:]
+		}
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	// one name more than requested shows that there is another page
+	if len(names) > limit {
+		names = names[:limit]
+		nextCursor = names[limit-1]
+	}
+
+	return names, nextCursor, nil
+}
+
+// sortedNames returns the sorted names of all files of type t.
+func (b *Local) sortedNames(t restic.FileType) []string {
+	done := make(chan struct{})
+	defer close(done)
+
+	var names []string
+	for name := range b.List(t, done) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dataPage returns up to n names of data files which sort after cursor.
+func (b *Local) dataPage(cursor string, n int) ([]string, error) {
+	basedir := filepath.Join(b.Path, backend.Paths.Data)
+	entries, err := readdir(b.FS, basedir)
+	if err != nil {
+		return nil, err
+	}
+
+	packed := b.packedShards(restic.DataFile)
+
+	var shards []string
+	for _, fi := range entries {
+		if fi.IsDir() {
+			shards = append(shards, fi.Name())
+		}
+	}
+	// packed files may be in shards which do not exist on disk
+	for shard := range packed {
+		if !contains(shards, shard) {
+			shards = append(shards, shard)
+		}
+	}
+	sort.Strings(shards)
+
+	var names []string
+	for _, shard := range shards {
+		if len(names) >= n {
+			break
+		}
+
+		if len(cursor) >= 2 && shard < cursor[:2] {
+			continue
+		}
+
+		files, err := b.shardFiles(filepath.Join(basedir, shard))
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return nil, err
+		}
+
+		files = append(files, packed[shard]...)
+		sort.Strings(files)
+		for _, name := range files {
+			if name > cursor {
+				names = append(names, name)
+			}
+		}
+	}
+
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names, nil
+}
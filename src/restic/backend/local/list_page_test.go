@@ -0,0 +1,75 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"sort"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func listPages(t testing.TB, be *local.Local, tpe restic.FileType, limit int) (names []string, pages int) {
+	cursor := ""
+	for {
+		page, next, err := be.ListPage(tpe, cursor, limit)
+		OK(t, err)
+		Assert(t, len(page) <= limit, "page has %d names, limit is %d", len(page), limit)
+		names = append(names, page...)
+		pages++
+
+		if next == "" {
+			return names, pages
+		}
+		Equals(t, page[len(page)-1], next)
+		cursor = next
+	}
+}
+
+func TestListPage(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		data := Random(i, 100)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		want = append(want, h.Name)
+	}
+	sort.Strings(want)
+
+	for _, limit := range []int{1, 7, 10, 50, 100} {
+		names, pages := listPages(t, be, restic.DataFile, limit)
+		Equals(t, want, names)
+		Equals(t, (len(want)+limit-1)/limit, pages)
+	}
+
+	// a page can start anywhere
+	names, _, err := be.ListPage(restic.DataFile, want[20], 5)
+	OK(t, err)
+	Equals(t, want[21:26], names)
+
+	_, _, err = be.ListPage(restic.DataFile, "", 0)
+	Assert(t, err != nil, "zero limit accepted")
+}
+
+func TestListPageFlat(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	names, next, err := be.ListPage(restic.LockFile, "", 10)
+	OK(t, err)
+	Equals(t, 0, len(names))
+	Equals(t, "", next)
+
+	want := []string{"a", "b", "c", "d", "e"}
+	for _, name := range []string{"d", "b", "e", "a", "c"} {
+		OK(t, be.Save(restic.Handle{Type: restic.LockFile, Name: name}, bytes.NewReader([]byte(name))))
+	}
+
+	names, pages := listPages(t, be, restic.LockFile, 2)
+	Equals(t, want, names)
+	Equals(t, 3, pages)
+}
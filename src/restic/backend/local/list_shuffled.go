@@ -0,0 +1,42 @@
+package local
+
+import (
+	"math/rand"
+	"restic"
+
+	"restic/debug"
+)
+
+// ListShuffled returns a channel that yields the names of all files of type
+// t in a pseudo-random order determined by seed. Workers pulling from the
+// channel then access the shard directories evenly instead of one after
+// another. All names are collected before the first one is sent, so memory
+// usage grows with the number of files, about 100 bytes per data file.
+func (b *Local) ListShuffled(t restic.FileType, seed int64, done <-chan struct{}) <-chan string {
+	debug.Log("ListShuffled %v with seed %v", t, seed)
+
+	var names []string
+	for name := range b.List(t, done) {
+		names = append(names, name)
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	for i := len(names) - 1; i > 0; i-- {
+		j := rnd.Intn(i + 1)
+		names[i], names[j] = names[j], names[i]
+	}
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, name := range names {
+			select {
+			case ch <- name:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,66 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"sort"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestListShuffled(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var all []string
+	for i := 0; i < 50; i++ {
+		data := Random(i, 100)
+		id := restic.Hash(data)
+		OK(t, be.Save(restic.Handle{Type: restic.DataFile, Name: id.String()}, bytes.NewReader(data)))
+		all = append(all, id.String())
+	}
+	sort.Strings(all)
+
+	first := collect(be.ListShuffled(restic.DataFile, 23, nil))
+	Equals(t, first, collect(be.ListShuffled(restic.DataFile, 23, nil)))
+	Assert(t, !sort.StringsAreSorted(first), "names were not shuffled")
+
+	other := collect(be.ListShuffled(restic.DataFile, 42, nil))
+	Assert(t, !stringsEqual(first, other), "different seeds returned the same order")
+
+	// each name is returned exactly once
+	sort.Strings(first)
+	Equals(t, all, first)
+
+	Equals(t, []string{}, collect(be.ListShuffled(restic.LockFile, 23, nil)))
+}
+
+func TestListShuffledDone(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		h := restic.Handle{Type: restic.LockFile, Name: restic.Hash(Random(i, 10)).String()}
+		OK(t, be.Save(h, bytes.NewReader([]byte("lock"))))
+	}
+
+	done := make(chan struct{})
+	ch := be.ListShuffled(restic.LockFile, 1, done)
+	<-ch
+	close(done)
+	for range ch {
+	}
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
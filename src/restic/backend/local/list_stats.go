@@ -0,0 +1,64 @@
+package local
+
+import (
+	"restic"
+	"sync"
+
+	"restic/debug"
+)
+
+// ListStats works like List and also sums up the sizes of the files listed,
+// which are taken from the directory entries without an additional Stat. The
+// returned function returns the statistics for the names sent so far, all
+// files are included once the channel is closed. The sizes are those on
+// disk, which include the footer if Footer is enabled. Packed files are
+// included with the size of their data in the container.
+func (b *Local) ListStats(t restic.FileType, done <-chan struct{}) (<-chan string, func() restic.TypeStats) {
+	debug.Log("ListStats %v", t)
+
+	var m sync.Mutex
+	var stats restic.TypeStats
+	get := func() restic.TypeStats {
+		m.Lock()
+		defer m.Unlock()
+
+		s := stats
+		s.Histogram = append([]int(nil), stats.Histogram...)
+		return s
+	}
+
+	ch := make(chan string)
+	if b.checkPermitted(t) != nil {
+		close(ch)
+		return ch, get
+	}
+
+	fileInfos, err := b.listFileInfos(t)
+	if err != nil {
+		debug.Log("unable to list %v: %v", t, err)
+		close(ch)
+		return ch, get
+	}
+
+	go func() {
+		defer close(ch)
+		for _, fi := range fileInfos {
+			name := b.naming().Decode(fi.Name())
+			if name == "" {
+				continue
+			}
+
+			select {
+			case ch <- name:
+			case <-done:
+				return
+			}
+
+			m.Lock()
+			stats.Add(fi.Size())
+			m.Unlock()
+		}
+	}()
+
+	return ch, get
+}
@@ -0,0 +1,49 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"sort"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestListStats(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	var want restic.TypeStats
+	var names []string
+	for i, size := range []int{0, 1, 2, 3, 100, 1000, 4096, 5000} {
+		data := Random(i, size)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		names = append(names, h.Name)
+		want.Add(int64(size))
+	}
+	sort.Strings(names)
+
+	ch, stats := be.ListStats(restic.DataFile, nil)
+	var listed []string
+	for name := range ch {
+		listed = append(listed, name)
+	}
+	sort.Strings(listed)
+	Equals(t, names, listed)
+
+	got := stats()
+	Equals(t, want, got)
+	Equals(t, 8, got.Count)
+	Equals(t, int64(0+1+2+3+100+1000+4096+5000), got.TotalSize)
+	Equals(t, int64(0), got.Smallest)
+	Equals(t, int64(5000), got.Largest)
+	Equals(t, []int{1, 1, 2, 0, 0, 0, 0, 1, 0, 0, 1, 0, 0, 2}, got.Histogram)
+
+	// no files, no statistics
+	ch, stats = be.ListStats(restic.IndexFile, nil)
+	for range ch {
+	}
+	Equals(t, restic.TypeStats{}, stats())
+}
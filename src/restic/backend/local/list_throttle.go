@@ -0,0 +1,76 @@
+package local
+
+import (
+	"path/filepath"
+	"restic"
+	"time"
+
+	"restic/debug"
+)
+
+// listThrottled implements ListFilter for data files when ListDirDelay is
+// set. The shard directories are read one after another in the background,
+// waiting ListDirDelay between them.
+func (b *Local) listThrottled(match func(name string) bool, done <-chan struct{}) <-chan string {
+	ch := make(chan string)
+	dir := dirname(b.Path, restic.DataFile, "")
+
+	packed := b.listPacked(restic.DataFile)
+
+	go func() {
+		defer close(ch)
+
+		for _, name := range packed {
+			if !match(name) {
+				continue
+			}
+
+			select {
+			case ch <- name:
+			case <-done:
+				return
+			}
+		}
+
+		shards, err := readdir(b.FS, dir)
+		if err != nil {
+			debug.Log("unable to read %v: %v", dir, err)
+			return
+		}
+
+		first := true
+		for _, fi := range shards {
+			if !fi.IsDir() {
+				continue
+			}
+
+			if !first {
+				select {
+				case <-time.After(b.ListDirDelay):
+				case <-done:
+					return
+				}
+			}
+			first = false
+
+			names, err := b.shardFiles(filepath.Join(dir, fi.Name()))
+			if err != nil {
+				continue
+			}
+
+			for _, name := range names {
+				if name == "" || !match(name) {
+					continue
+				}
+
+				select {
+				case ch <- name:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,65 @@
+package local
+
+import (
+	"path/filepath"
+	"restic"
+	"sort"
+	"testing"
+	"time"
+
+	. "restic/test"
+)
+
+func TestListDirDelay(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	seed := 0
+	for _, prefix := range []string{"0", "5", "a", "f"} {
+		seed = saveInShard(t, be, prefix, 2, seed)
+	}
+	all := collect(be.List(restic.DataFile, nil))
+	sort.Strings(all)
+	Equals(t, 8, len(all))
+
+	// record when each shard directory is read
+	var reads []time.Time
+	data := filepath.Join(be.Path, "data")
+	be.FS = &fakeFS{FS: be.FS, fail: func(op, name string) error {
+		if op == "Open" && filepath.Dir(name) == data {
+			reads = append(reads, time.Now())
+		}
+		return nil
+	}}
+
+	be.ListDirDelay = 20 * time.Millisecond
+	names := collect(be.List(restic.DataFile, nil))
+	sort.Strings(names)
+	Equals(t, all, names)
+
+	Assert(t, len(reads) >= 2, "only %d directories read", len(reads))
+	for i := 1; i < len(reads); i++ {
+		gap := reads[i].Sub(reads[i-1])
+		Assert(t, gap >= be.ListDirDelay, "directories read %v apart", gap)
+	}
+
+	// the listing can be canceled while waiting
+	be.ListDirDelay = time.Hour
+	done := make(chan struct{})
+	ch := be.List(restic.DataFile, done)
+	<-ch
+
+	finished := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(finished)
+	}()
+
+	close(done)
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("List did not stop after done was closed")
+	}
+}
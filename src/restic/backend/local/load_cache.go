@@ -0,0 +1,351 @@
+package local
+
+import (
+	"container/list"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"sync"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// loadCache holds the files stored by LoadCached, ordered by last use. It
+// is loaded from the cache directory on first use. filling holds the files
+// copied to the cache in the background, fills waits for them.
+type loadCache struct {
+	m       sync.Mutex
+	loaded  bool
+	size    int64
+	lru     *list.List
+	entries map[restic.Handle]*list.Element
+
+	filling map[restic.Handle]bool
+	fills   sync.WaitGroup
+}
+
+type cacheEntry struct {
+	h    restic.Handle
+	size int64
+}
+
+func (b *Local) cacheDir() string {
+	if b.CacheDir != "" {
+		return b.CacheDir
+	}
+	return filepath.Join(b.Path, localPaths.Cache)
+}
+
+func (b *Local) cacheFile(h restic.Handle) string {
+	return filepath.Join(b.cacheDir(), string(h.Type), h.Name)
+}
+
+// isCacheable returns true if files of type t are stored by LoadCached. The
+// config and lock files may be modified and are always loaded from the
+// repository.
+func isCacheable(t restic.FileType) bool {
+	return t != restic.ConfigFile && t != restic.LockFile
+}
+
+// loadCacheIndex reads the files in the cache directory, b.cache.m must be
+// held. The least recently modified files are evicted first.
+func (b *Local) loadCacheIndex() {
+	if b.cache.loaded {
+		return
+	}
+
+	b.cache.loaded = true
+	b.cache.lru = list.New()
+	b.cache.entries = make(map[restic.Handle]*list.Element)
+	b.cache.size = 0
+
+	var files []os.FileInfo
+	var handles []restic.Handle
+	for _, t := range fileTypes {
+		if !isCacheable(t) {
+			continue
+		}
+
+		entries, err := readdir(b.FS, filepath.Join(b.cacheDir(), string(t)))
+		if err != nil {
+			continue
+		}
+
+		for _, fi := range entries {
+			if isFile(fi) {
+				files = append(files, fi)
+				handles = append(handles, restic.Handle{Type: t, Name: fi.Name()})
+			}
+		}
+	}
+
+	// insert the most recently modified files at the front
+	idx := make([]int, len(files))
+	for i := range idx {
+		idx[i] = i
+	}
+	sortByModTime(files, idx)
+	for _, i := range idx {
+		b.addCacheEntry(handles[i], files[i].Size())
+	}
+}
+
+// sortByModTime sorts idx by the modification time of files, newest first.
+func sortByModTime(files []os.FileInfo, idx []int) {
+	for i := 1; i < len(idx); i++ {
+		for j := i; j > 0 && files[idx[j]].ModTime().After(files[idx[j-1]].ModTime()); j-- {
+			idx[j], idx[j-1] = idx[j-1], idx[j]
+		}
+	}
+}
+
+// addCacheEntry records a new file in the cache as least recently used,
+// b.cache.m must be held.
+func (b *Local) addCacheEntry(h restic.Handle, size int64) {
+	b.cache.entries[h] = b.cache.lru.PushBack(cacheEntry{h: h, size: size})
+	b.cache.size += size
+}
+
+// evictCache removes the least recently used files until the cache is
+// small enough, b.cache.m must be held.
+func (b *Local) evictCache() {
+	for b.CacheSize > 0 && b.cache.size > b.CacheSize && b.cache.lru.Len() > 0 {
+		e := b.cache.lru.Remove(b.cache.lru.Back()).(cacheEntry)
+		delete(b.cache.entries, e.h)
+		b.cache.size -= e.size
+
+		debug.Log("evicting %v from the cache", e.h)
+		if err := b.FS.Remove(b.cacheFile(e.h)); err != nil {
+			debug.Log("unable to remove cached %v: %v", e.h, err)
+		}
+	}
+}
+
+// LoadCached works like Load, but keeps a copy of each file it reads in the
+// cache directory (CacheDir), from which later calls for the same file are
+// served. Config and lock files are not cached. When the whole file is
+// requested, the copy is written while the caller reads the file and only
+// added to the cache when all data has been read. A range is read from the
+// file like with Load, and the file is copied to the cache by a separate
+// read in the background.
+func (b *Local) LoadCached(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	debug.Log("LoadCached %v, length %v, offset %v", h, length, offset)
+	if err := h.Valid(); err != nil {
+		return nil, err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		return nil, errors.New("offset is negative")
+	}
+
+	if !isCacheable(h.Type) {
+		return b.Load(h, length, offset)
+	}
+
+	if rd, ok := b.loadFromCache(h, length, offset); ok {
+		return rd, nil
+	}
+
+	if length > 0 || offset > 0 {
+		b.fillCache(h)
+		return b.Load(h, length, offset)
+	}
+
+	return b.cachingLoad(h)
+}
+
+// cachingLoad returns a reader for the whole file h which copies the data
+// to the cache.
+func (b *Local) cachingLoad(h restic.Handle) (io.ReadCloser, error) {
+	rd, err := b.Load(h, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &cachingReader{rd: rd, be: b, h: h}
+
+	dir := filepath.Dir(b.cacheFile(h))
+	err = b.FS.MkdirAll(dir, backend.Modes.Dir)
+	if err == nil {
+		cr.tmp, err = b.FS.TempFile(b.cacheDir(), b.tempPrefix())
+	}
+	if err != nil {
+		debug.Log("unable to create cache file for %v: %v", h, err)
+	}
+
+	return cr, nil
+}
+
+// fillCache copies the file h to the cache in the background, unless this
+// is already in progress.
+func (b *Local) fillCache(h restic.Handle) {
+	b.cache.m.Lock()
+	if b.cache.filling[h] {
+		b.cache.m.Unlock()
+		return
+	}
+	if b.cache.filling == nil {
+		b.cache.filling = make(map[restic.Handle]bool)
+	}
+	b.cache.filling[h] = true
+	b.cache.fills.Add(1)
+	b.cache.m.Unlock()
+
+	go func() {
+		defer b.cache.fills.Done()
+		defer func() {
+			b.cache.m.Lock()
+			delete(b.cache.filling, h)
+			b.cache.m.Unlock()
+		}()
+
+		rd, err := b.cachingLoad(h)
+		if err != nil {
+			debug.Log("unable to load %v for the cache: %v", h, err)
+			return
+		}
+
+		_, err = io.Copy(ioutil.Discard, rd)
+		if e := rd.Close(); err == nil {
+			err = e
+		}
+		if err != nil {
+			debug.Log("unable to copy %v to the cache: %v", h, err)
+		}
+	}()
+}
+
+// loadFromCache returns a reader for the cached copy of h.
+func (b *Local) loadFromCache(h restic.Handle, length int, offset int64) (io.ReadCloser, bool) {
+	b.cache.m.Lock()
+	b.loadCacheIndex()
+	e, ok := b.cache.entries[h]
+	if ok {
+		b.cache.lru.MoveToFront(e)
+	}
+	b.cache.m.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	f, err := b.FS.Open(b.cacheFile(h))
+	if err != nil {
+		debug.Log("unable to open cached %v: %v", h, err)
+		return nil, false
+	}
+
+	if offset > 0 {
+		if _, err = f.Seek(offset, 0); err != nil {
+			f.Close()
+			return nil, false
+		}
+	}
+
+	debug.Log("serving %v from the cache", h)
+	if length > 0 {
+		return backend.LimitReadCloser(f, int64(length)), true
+	}
+	return f, true
+}
+
+// cachingReader returns the data read from rd and writes it to tmp. When rd
+// has been read completely, Close moves tmp into the cache.
+type cachingReader struct {
+	rd  io.ReadCloser
+	be  *Local
+	h   restic.Handle
+	tmp File
+
+	written  int64
+	complete bool
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.rd.Read(p)
+	if n > 0 && r.tmp != nil {
+		if _, e := r.tmp.Write(p[:n]); e != nil {
+			debug.Log("unable to write cache file for %v: %v", r.h, e)
+			r.abort()
+		}
+		r.written += int64(n)
+	}
+
+	if err == io.EOF {
+		r.complete = true
+	}
+	return n, err
+}
+
+// abort removes the cache file, the data is not cached.
+func (r *cachingReader) abort() {
+	if r.tmp == nil {
+		return
+	}
+
+	r.tmp.Close()
+	r.be.FS.Remove(r.tmp.Name())
+	r.tmp = nil
+}
+
+// Close closes the file and adds the copy to the cache if the file has been
+// read completely.
+func (r *cachingReader) Close() error {
+	err := r.rd.Close()
+	if r.tmp == nil {
+		return err
+	}
+
+	if !r.complete || err != nil {
+		debug.Log("%v not read completely, not caching it", r.h)
+		r.abort()
+		return err
+	}
+
+	tmpfile := r.tmp.Name()
+	if e := r.tmp.Close(); e != nil {
+		r.be.FS.Remove(tmpfile)
+		return err
+	}
+
+	b := r.be
+	b.cache.m.Lock()
+	defer b.cache.m.Unlock()
+	b.loadCacheIndex()
+
+	if e := b.FS.Rename(tmpfile, b.cacheFile(r.h)); e != nil {
+		debug.Log("unable to add %v to the cache: %v", r.h, e)
+		b.FS.Remove(tmpfile)
+		return err
+	}
+
+	if old, ok := b.cache.entries[r.h]; ok {
+		b.cache.size -= b.cache.lru.Remove(old).(cacheEntry).size
+	}
+	b.addCacheEntry(r.h, r.written)
+	b.cache.lru.MoveToFront(b.cache.entries[r.h])
+	b.evictCache()
+
+	return err
+}
+
+// ClearCache removes all files stored by LoadCached.
+func (b *Local) ClearCache() error {
+	debug.Log("ClearCache")
+	b.cache.m.Lock()
+	defer b.cache.m.Unlock()
+
+	b.cache.loaded = false
+	if err := b.FS.RemoveAll(b.cacheDir()); err != nil {
+		return errors.Wrap(err, "RemoveAll")
+	}
+	return nil
+}
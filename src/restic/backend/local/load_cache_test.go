@@ -0,0 +1,140 @@
+package local
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+func loadCached(t testing.TB, be *Local, h restic.Handle, length int, offset int64) []byte {
+	rd, err := be.LoadCached(h, length, offset)
+	OK(t, err)
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	return buf
+}
+
+func saveData(t testing.TB, be *Local, seed, size int) (restic.Handle, []byte) {
+	data := Random(seed, size)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	return h, data
+}
+
+func TestLoadCached(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h, data := saveData(t, be, 23, 1000)
+	Equals(t, data, loadCached(t, be, h, 0, 0))
+
+	// the second load is served from the cache
+	OK(t, os.Remove(filename(be.Path, h.Type, h.Name)))
+	Equals(t, data, loadCached(t, be, h, 0, 0))
+	Equals(t, data[100:200], loadCached(t, be, h, 100, 100))
+	Equals(t, data[900:], loadCached(t, be, h, 0, 900))
+
+	problems, err := be.FsckStructure(false)
+	OK(t, err)
+	Equals(t, 0, len(problems))
+
+	OK(t, be.ClearCache())
+	_, err = be.LoadCached(h, 0, 0)
+	Assert(t, err != nil, "file loaded after clearing the cache")
+}
+
+func TestLoadCachedPartial(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h, data := saveData(t, be, 23, 1000)
+
+	// a whole file which is not read completely is not cached
+	rd, err := be.LoadCached(h, 0, 0)
+	OK(t, err)
+	buf := make([]byte, 100)
+	_, err = io.ReadFull(rd, buf)
+	OK(t, err)
+	OK(t, rd.Close())
+	Equals(t, data[:100], buf)
+
+	OK(t, os.Remove(filename(be.Path, h.Type, h.Name)))
+	_, err = be.LoadCached(h, 0, 0)
+	Assert(t, err != nil, "partially read file was cached")
+
+	entries, err := ioutil.ReadDir(be.cacheDir())
+	OK(t, err)
+	for _, fi := range entries {
+		Assert(t, fi.IsDir(), "cache file %v left behind", fi.Name())
+	}
+
+	// a range is read from the file, the cache is filled in the background
+	for i, r := range []struct {
+		length int
+		offset int64
+	}{{10, 10}, {0, 400}} {
+		h, data := saveData(t, be, 5+i, 500)
+		end := len(data)
+		if r.length > 0 {
+			end = int(r.offset) + r.length
+		}
+		Equals(t, data[r.offset:end], loadCached(t, be, h, r.length, r.offset))
+		be.cache.fills.Wait()
+
+		OK(t, os.Remove(filename(be.Path, h.Type, h.Name)))
+		Equals(t, data, loadCached(t, be, h, 0, 0))
+	}
+}
+
+func TestLoadCachedEvict(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.CacheSize = 2500
+
+	var handles []restic.Handle
+	for i := 0; i < 3; i++ {
+		h, _ := saveData(t, be, i, 1000)
+		handles = append(handles, h)
+	}
+
+	loadCached(t, be, handles[0], 0, 0)
+	loadCached(t, be, handles[1], 0, 0)
+
+	// using the first file makes the second the least recently used one
+	loadCached(t, be, handles[0], 0, 0)
+	loadCached(t, be, handles[2], 0, 0)
+
+	cached := func(h restic.Handle) bool {
+		_, err := os.Stat(be.cacheFile(h))
+		return err == nil
+	}
+	Assert(t, cached(handles[0]), "recently used file was evicted")
+	Assert(t, !cached(handles[1]), "least recently used file was not evicted")
+	Assert(t, cached(handles[2]), "new file was evicted")
+
+	// the cache is picked up again by a new instance
+	be2, err := Open(be.Config)
+	OK(t, err)
+	be2.CacheSize = 1500
+	h, data := saveData(t, be2, 10, 1000)
+	Equals(t, data, loadCached(t, be2, h, 0, 0))
+	Assert(t, !cached(handles[0]) && !cached(handles[2]), "old files were not evicted")
+	Assert(t, cached(h), "new file was evicted")
+}
+
+func TestLoadCachedLock(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	OK(t, be.Save(h, bytes.NewReader([]byte("foo"))))
+	Equals(t, []byte("foo"), loadCached(t, be, h, 0, 0))
+	_, err := os.Stat(be.cacheFile(h))
+	Assert(t, os.IsNotExist(err), "lock file was cached")
+}
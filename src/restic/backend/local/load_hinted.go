@@ -0,0 +1,67 @@
+package local
+
+import (
+	"io"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+)
+
+// AccessPattern describes how the data returned by LoadHinted is read.
+type AccessPattern int
+
+// These are the access patterns for LoadHinted.
+const (
+	// AccessNormal gives no hint, the kernel uses its default read-ahead.
+	AccessNormal AccessPattern = iota
+
+	// AccessSequential announces that the data is read from start to end,
+	// e.g. when a whole pack is restored, so more is read ahead.
+	AccessSequential
+
+	// AccessRandom announces a small read at a random position, so no
+	// data is read ahead.
+	AccessRandom
+)
+
+// LoadHinted works like Load and passes the access pattern to the kernel
+// for the range read, so that read-ahead is tuned to it. The hint is only
+// given on Linux (with posix_fadvise), errors are ignored.
+func (b *Local) LoadHinted(h restic.Handle, length int, offset int64, pattern AccessPattern) (io.ReadCloser, error) {
+	rd, err := b.Load(h, length, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if pattern == AccessNormal {
+		return rd, nil
+	}
+
+	f, ok := fileOf(rd)
+	if !ok {
+		return rd, nil
+	}
+
+	if err = fadvise(f, offset, int64(length), pattern); err != nil {
+		debug.Log("fadvise %v for %v failed: %v", pattern, h, err)
+	}
+
+	return rd, nil
+}
+
+// fileOf returns the file read by rd, which was returned by Load.
+func fileOf(rd io.ReadCloser) (File, bool) {
+	for {
+		switch r := rd.(type) {
+		case File:
+			return r, true
+		case *backend.LimitedReadCloser:
+			rd = r.ReadCloser
+		case *accessLogReader:
+			rd = r.ReadCloser
+		default:
+			return nil, false
+		}
+	}
+}
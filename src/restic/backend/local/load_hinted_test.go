@@ -0,0 +1,73 @@
+// +build linux
+
+package local
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "restic/test"
+)
+
+type fadviseCall struct {
+	offset, length int64
+	advice         int
+}
+
+func TestLoadHinted(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var calls []fadviseCall
+	defer func(fn func(int, int64, int64, int) error) {
+		fadviseFunc = fn
+	}(fadviseFunc)
+	fadviseFunc = func(fd int, offset, length int64, advice int) error {
+		calls = append(calls, fadviseCall{offset, length, advice})
+		return nil
+	}
+
+	h, data := saveData(t, be, 23, 1000)
+
+	tests := []struct {
+		length  int
+		offset  int64
+		pattern AccessPattern
+		call    *fadviseCall
+	}{
+		{0, 0, AccessSequential, &fadviseCall{0, 0, fadvSequential}},
+		{100, 200, AccessRandom, &fadviseCall{200, 100, fadvRandom}},
+		{0, 0, AccessNormal, nil},
+	}
+
+	for i, test := range tests {
+		calls = nil
+		rd, err := be.LoadHinted(h, test.length, test.offset, test.pattern)
+		OK(t, err)
+		buf, err := ioutil.ReadAll(rd)
+		OK(t, err)
+		OK(t, rd.Close())
+
+		want := data[test.offset:]
+		if test.length > 0 {
+			want = want[:test.length]
+		}
+		Equals(t, want, buf)
+
+		if test.call == nil {
+			Equals(t, 0, len(calls))
+			continue
+		}
+		Assert(t, len(calls) == 1 && calls[0] == *test.call,
+			"test %d: wrong fadvise calls %v, want %v", i, calls, *test.call)
+	}
+
+	// the hint also reaches the file through the access log
+	be.AccessLog = true
+	calls = nil
+	rd, err := be.LoadHinted(h, 0, 0, AccessSequential)
+	OK(t, err)
+	OK(t, rd.Close())
+	Equals(t, []fadviseCall{{0, 0, fadvSequential}}, calls)
+	OK(t, be.Close())
+}
@@ -0,0 +1,54 @@
+package local
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"restic"
+	"time"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// LoadIfChanged returns the content of the file at h if its modification
+// time differs from knownModTime, together with the modification time and
+// true. Otherwise, the file is not read and changed is false. Since
+// content-addressed files never change, they are reported unchanged
+// whenever knownModTime is set. The modification time is determined before
+// the file is read, so a concurrent modification is reported by the next
+// call.
+func (b *Local) LoadIfChanged(h restic.Handle, knownModTime time.Time) (rd io.ReadCloser, modTime time.Time, changed bool, err error) {
+	debug.Log("LoadIfChanged %v, known mtime %v", h, knownModTime)
+	if err := h.Valid(); err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	var fi os.FileInfo
+	if e, ok := b.packed(h); ok {
+		// a packed file is as old as its container
+		fi, err = b.FS.Stat(filepath.Join(b.packedDir(), e.Container))
+	} else {
+		_, fi, err = b.locate(h)
+	}
+	if err != nil {
+		return nil, time.Time{}, false, errors.Wrap(err, "Stat")
+	}
+
+	modTime = fi.ModTime()
+	if !knownModTime.IsZero() && (isContentAddressed(h.Type) || modTime.Equal(knownModTime)) {
+		debug.Log("%v unchanged", h)
+		return nil, knownModTime, false, nil
+	}
+
+	rd, err = b.Load(h, 0, 0)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	return rd, modTime, true, nil
+}
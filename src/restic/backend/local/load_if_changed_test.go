@@ -0,0 +1,76 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"restic"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestLoadIfChanged(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.KeyFile, Name: "latest"}
+	OK(t, be.Save(h, bytes.NewReader([]byte("first"))))
+
+	rd, mtime, changed, err := be.LoadIfChanged(h, time.Time{})
+	OK(t, err)
+	Assert(t, changed, "file without known mtime reported unchanged")
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	Equals(t, []byte("first"), buf)
+
+	// an unchanged file is not opened
+	ops := make(map[string]int)
+	fsys := be.FS
+	be.FS = countOps(fsys, ops)
+	rd, mtime2, changed, err := be.LoadIfChanged(h, mtime)
+	OK(t, err)
+	Assert(t, !changed && rd == nil, "unchanged file reported as changed")
+	Assert(t, mtime.Equal(mtime2), "wrong mtime %v returned, want %v", mtime2, mtime)
+	Equals(t, 0, ops["Open"])
+	be.FS = fsys
+
+	OK(t, be.Remove(h))
+	OK(t, be.Save(h, bytes.NewReader([]byte("second"))))
+	later := mtime.Add(time.Minute)
+	OK(t, os.Chtimes(filename(be.Path, h.Type, h.Name), later, later))
+
+	rd, mtime2, changed, err = be.LoadIfChanged(h, mtime)
+	OK(t, err)
+	Assert(t, changed, "modified file reported unchanged")
+	Assert(t, mtime2.Equal(later), "wrong mtime %v returned, want %v", mtime2, later)
+	buf, err = ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	Equals(t, []byte("second"), buf)
+
+	_, _, _, err = be.LoadIfChanged(restic.Handle{Type: restic.KeyFile, Name: "missing"}, mtime)
+	Assert(t, os.IsNotExist(errors.Cause(err)), "expected not exist error, got %v", err)
+}
+
+func TestLoadIfChangedImmutable(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h, data := saveData(t, be, 23, 1000)
+	rd, mtime, changed, err := be.LoadIfChanged(h, time.Time{})
+	OK(t, err)
+	Assert(t, changed, "file without known mtime reported unchanged")
+	OK(t, rd.Close())
+
+	// content-addressed files are unchanged whatever the mtime is
+	later := mtime.Add(time.Minute)
+	OK(t, os.Chtimes(filename(be.Path, h.Type, h.Name), later, later))
+	rd, _, changed, err = be.LoadIfChanged(h, mtime)
+	OK(t, err)
+	Assert(t, !changed && rd == nil, "content-addressed file reported as changed")
+	Equals(t, data, load(t, be, h, 0, 0))
+}
@@ -0,0 +1,65 @@
+package local
+
+import (
+	"io"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrRangeNotSatisfiable is returned by LoadStrict if the requested range
+// extends past the end of the file.
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// LoadStrict works like Load, but returns ErrRangeNotSatisfiable instead of a
+// reader which yields less data if offset+length is larger than the size of
+// the file. A length of zero requests the data up to the end of the file,
+// then only the offset must not be past the end. Callers use this to detect
+// stale index entries which point past the end of a pack.
+func (b *Local) LoadStrict(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	debug.Log("LoadStrict %v, length %v, offset %v", h, length, offset)
+	if err := h.Valid(); err != nil {
+		return nil, err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || length < 0 {
+		return nil, errors.New("offset or length is negative")
+	}
+
+	if e, ok := b.packed(h); ok {
+		if end := offset + int64(length); end > e.Length {
+			return nil, errors.Wrapf(ErrRangeNotSatisfiable, "%v: range %d-%d, size %d", h, offset, end, e.Length)
+		}
+		return b.loadPacked(e, length, offset)
+	}
+
+	f, size, err := b.openContent(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if end := offset + int64(length); end > size {
+		f.Close()
+		return nil, errors.Wrapf(ErrRangeNotSatisfiable, "%v: range %d-%d, size %d", h, offset, end, size)
+	}
+
+	if offset > 0 {
+		if _, err = f.Seek(offset, 0); err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "Seek")
+		}
+	}
+
+	n := size - offset
+	if length > 0 {
+		n = int64(length)
+	}
+
+	return backend.LimitReadCloser(f, n), nil
+}
@@ -0,0 +1,65 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestLoadStrict(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	for _, footer := range []bool{false, true} {
+		be.Footer = footer
+
+		var tests = []struct {
+			length int
+			offset int64
+			want   []byte
+		}{
+			{0, 0, data},
+			{100, 10, data[10:110]},
+			{0, 900, data[900:]},
+			{100, 900, data[900:]},
+			{0, 1000, []byte{}},
+		}
+
+		for _, test := range tests {
+			rd, err := be.LoadStrict(h, test.length, test.offset)
+			OK(t, err)
+			buf, err := ioutil.ReadAll(rd)
+			OK(t, err)
+			OK(t, rd.Close())
+			Equals(t, test.want, buf)
+		}
+
+		for _, test := range []struct {
+			length int
+			offset int64
+		}{
+			{101, 900},
+			{1, 1000},
+			{0, 1001},
+			{10, 5000},
+		} {
+			rd, err := be.LoadStrict(h, test.length, test.offset)
+			Assert(t, errors.Cause(err) == local.ErrRangeNotSatisfiable,
+				"length %d, offset %d: expected ErrRangeNotSatisfiable, got %v", test.length, test.offset, err)
+			Assert(t, rd == nil, "reader returned with error")
+		}
+	}
+
+	_, err := be.LoadStrict(restic.Handle{Type: restic.LockFile, Name: "missing"}, 0, 0)
+	Assert(t, err != nil && errors.Cause(err) != local.ErrRangeNotSatisfiable,
+		"expected not found error, got %v", err)
+}
@@ -0,0 +1,82 @@
+package local
+
+import (
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// isContentAddressed returns true if files of type t are named after the
+// digest of the configured hash function over their content.
+func isContentAddressed(t restic.FileType) bool {
+	switch t {
+	case restic.DataFile, restic.IndexFile, restic.SnapshotFile:
+		return true
+	}
+	return false
+}
+
+// LoadToFile writes the contents of the file at h to destPath. The data is
+// first written to a temporary file in the same directory as destPath, which
+// is synced and then renamed to destPath, so destPath either holds the
+// complete content or is left untouched. Missing parent directories are
+// created. For content-addressed file types, the hash of the data is checked
+// against the name before the rename.
+func (b *Local) LoadToFile(h restic.Handle, destPath string) (err error) {
+	debug.Log("LoadToFile %v -> %v", h, destPath)
+	rd, err := b.Load(h, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	dir := filepath.Dir(destPath)
+	if err = b.FS.MkdirAll(dir, backend.Modes.Dir); err != nil {
+		return errors.Wrap(err, "MkdirAll")
+	}
+
+	tmpfile, err := b.FS.TempFile(dir, "."+filepath.Base(destPath)+".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "TempFile")
+	}
+
+	defer func() {
+		if err != nil {
+			tmpfile.Close()
+			b.FS.Remove(tmpfile.Name())
+		}
+	}()
+
+	buf := getCopyBuffer(0)
+	defer putCopyBuffer(buf)
+
+	hash := b.newHash()
+	if _, err = io.CopyBuffer(tmpfile, io.TeeReader(rd, hash), buf); err != nil {
+		return errors.Wrap(err, "Write")
+	}
+
+	if isContentAddressed(h.Type) {
+		if id := hex.EncodeToString(hash.Sum(nil)); id != h.Name {
+			return errors.Wrapf(ErrHashMismatch, "%v has hash %v", h, id)
+		}
+	}
+
+	if err = tmpfile.Sync(); err != nil {
+		return errors.Wrap(err, "Sync")
+	}
+
+	if err = tmpfile.Close(); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	if err = b.FS.Chmod(tmpfile.Name(), backend.Modes.File); err != nil {
+		return errors.Wrap(err, "Chmod")
+	}
+
+	return errors.Wrap(b.FS.Rename(tmpfile.Name(), destPath), "Rename")
+}
@@ -0,0 +1,58 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestLoadToFile(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 5000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	dest := filepath.Join(be.Location(), "restore", "sub", "pack")
+	OK(t, be.LoadToFile(h, dest))
+
+	buf, err := ioutil.ReadFile(dest)
+	OK(t, err)
+	Assert(t, bytes.Equal(buf, data), "wrong data written to %v", dest)
+
+	fi, err := os.Stat(dest)
+	OK(t, err)
+	Assert(t, fi.Mode().Perm()&0077 == 0, "file %v is accessible by others: %v", dest, fi.Mode())
+
+	files, err := ioutil.ReadDir(filepath.Dir(dest))
+	OK(t, err)
+	Equals(t, 1, len(files))
+}
+
+func TestLoadToFileHashMismatch(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 5000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash([]byte("foo")).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	dir := filepath.Join(be.Location(), "restore")
+	dest := filepath.Join(dir, "pack")
+	err := be.LoadToFile(h, dest)
+	Assert(t, err != nil, "expected error for corrupt file not found")
+
+	_, err = os.Stat(dest)
+	Assert(t, os.IsNotExist(err), "destination file %v was created", dest)
+
+	files, err := ioutil.ReadDir(dir)
+	OK(t, err)
+	Equals(t, 0, len(files))
+}
@@ -1,26 +1,84 @@
 package local
 
 import (
+	"bytes"
+	"encoding/hex"
+	"hash"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"restic"
+	"time"
 
 	"restic/errors"
 
 	"restic/backend"
 	"restic/debug"
-	"restic/fs"
 )
 
 // Local is a backend in a local directory.
 type Local struct {
 	Config
+
+	// FS is the file system used to access the repository.
+	FS FS
+
+	checksums checksumCache
+	accessLog accessLog
+
+	// dirs holds the subdirectories which are known to exist.
+	dirs dirCache
+
+	cache loadCache
+
+	// shards holds the number of entries in the data subdirectories when
+	// MaxShardEntries is set.
+	shards shardCounts
+
+	// unsynced holds the directories files were renamed into since the
+	// last Close, if SyncOnClose is set.
+	unsynced dirCache
+
+	// small holds the index of the packed files, nil if there are none and
+	// PackSmallBlobs was not called.
+	small *smallPack
+
+	// recoverMode is set for backends returned by OpenRecover.
+	recoverMode bool
+
+	// hasBuckets is set if snapshots in date buckets were found by Open.
+	hasBuckets bool
+
+	// buckets holds the date bucket directories.
+	buckets bucketCache
 }
 
 var _ restic.Backend = &Local{}
 
+// localPaths contains the paths of the directories the local backend uses in
+// addition to backend.Paths, for sidecar files and its own bookkeeping.
+var localPaths = struct {
+	Checksums string
+	Pool      string
+	Meta      string
+	Log       string
+	Cache     string
+	Journal   string
+	Offsets   string
+	Parity    string
+	Packed    string
+}{
+	"checksums",
+	"pool",
+	"meta",
+	"log",
+	"cache",
+	"journal",
+	"offsets",
+	"parity",
+	"packed",
+}
+
 func paths(dir string) []string {
 	return []string{
 		dir,
@@ -35,35 +93,157 @@ func paths(dir string) []string {
 
 // Open opens the local backend as specified by config.
 func Open(cfg Config) (*Local, error) {
+	return open(cfg, defaultFS)
+}
+
+func open(cfg Config, fsys FS) (*Local, error) {
+	return openBackend(cfg, fsys, false)
+}
+
+// openBackend opens the backend at cfg.Path. In recover mode, missing
+// directories other than the repository itself are tolerated, and the
+// checks for a writable file system on a single device are skipped.
+func openBackend(cfg Config, fsys FS, recoverMode bool) (*Local, error) {
+	if cfg.Hash != 0 && !cfg.Hash.Available() {
+		return nil, errors.Errorf("hash function %v is not available", cfg.Hash)
+	}
+
+	if cfg.ParityShards > maxParityShards {
+		return nil, errors.Errorf("ParityShards is larger than %d", maxParityShards)
+	}
+
+	if cfg.OpenFunc != nil {
+		fsys = openFuncFS{FS: fsys, open: cfg.OpenFunc}
+	}
+
+	fsys = eintrFS{FS: fsys}
+	if cfg.OpTimeout > 0 {
+		fsys = timeoutFS{FS: fsys, timeout: cfg.OpTimeout}
+	}
+
+	// waiting for the budget does not count towards the timeout
+	if n := fdBudget(cfg); n > 0 {
+		fsys = newFDBudgetFS(fsys, n)
+	}
+
+	// operate on the resolved path, so that all renames happen within the
+	// same directory tree
+	path, err := resolvePath(cfg.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Open")
+	}
+	cfg.Path = path
+
 	// test if all necessary dirs are there
-	for _, d := range paths(cfg.Path) {
-		if _, err := fs.Stat(d); err != nil {
+	for i, d := range paths(cfg.Path) {
+		if _, err := fsys.Stat(d); err != nil {
+			if recoverMode && i > 0 {
+				debug.Log("recover mode, ignoring missing directory: %v", err)
+				continue
+			}
 			return nil, errors.Wrap(err, "Open")
 		}
 	}
 
-	return &Local{Config: cfg}, nil
+	// nothing is written in recover mode
+	if !recoverMode {
+		if err := checkSameDevice(fsys, cfg.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	if !cfg.ReadOnly && !recoverMode {
+		if err := checkWritable(fsys, cfg.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	be := &Local{Config: cfg, FS: fsys, recoverMode: recoverMode}
+	be.hasBuckets = hasSnapshotBuckets(fsys, cfg.Path)
+
+	if _, err := fsys.Stat(filepath.Join(be.packedDir(), smallIndexFile)); err == nil {
+		if be.small, err = be.loadSmallPack(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Journal && !cfg.ReadOnly && !recoverMode {
+		if _, err := be.RecoverJournal(); err != nil {
+			return nil, err
+		}
+	}
+
+	if protected, _ := be.Protected(); protected {
+		debug.Log("repository at %v is protected against deletion", cfg.Path)
+	}
+
+	return be, nil
 }
 
 // Create creates all the necessary files and directories for a new local
 // backend at dir. Afterwards a new config blob should be created.
 func Create(cfg Config) (*Local, error) {
+	return create(cfg, defaultFS)
+}
+
+func create(cfg Config, fsys FS) (*Local, error) {
 	// test if config file already exists
-	_, err := fs.Lstat(filepath.Join(cfg.Path, backend.Paths.Config))
+	_, err := fsys.Lstat(filepath.Join(cfg.Path, backend.Paths.Config))
 	if err == nil {
 		return nil, errors.New("config file already exists")
 	}
 
-	// create paths for data, refs and temp
+	// create paths for data, refs and temp, remember which directories did
+	// not exist before so that they can be removed again on failure
+	var created []string
 	for _, d := range paths(cfg.Path) {
-		err := fs.MkdirAll(d, backend.Modes.Dir)
+		created = append(created, missingDirs(fsys, d)...)
+		err := fsys.MkdirAll(d, backend.Modes.Dir)
 		if err != nil {
+			removeCreated(fsys, created)
 			return nil, errors.Wrap(err, "MkdirAll")
 		}
 	}
 
 	// open backend
-	return Open(cfg)
+	be, err := open(cfg, fsys)
+	if err != nil {
+		removeCreated(fsys, created)
+		return nil, err
+	}
+
+	return be, nil
+}
+
+// missingDirs returns dir and its parents which do not exist, outermost
+// first.
+func missingDirs(fsys FS, dir string) []string {
+	var dirs []string
+	for {
+		_, err := fsys.Lstat(dir)
+		if !os.IsNotExist(errors.Cause(err)) {
+			break
+		}
+
+		dirs = append([]string{dir}, dirs...)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return dirs
+}
+
+// removeCreated removes the directories created by Create in reverse
+// order. Directories which are not empty are left alone.
+func removeCreated(fsys FS, dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err := fsys.Remove(dirs[i])
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			debug.Log("unable to remove %v: %v", dirs[i], err)
+		}
+	}
 }
 
 // Location returns this backend's location (the directory name).
@@ -101,73 +281,410 @@ func dirname(base string, t restic.FileType, name string) string {
 	return filepath.Join(base, n)
 }
 
-// copyToTempfile saves p into a tempfile in tempdir.
-func copyToTempfile(tempdir string, rd io.Reader) (filename string, err error) {
-	tmpfile, err := ioutil.TempFile(tempdir, "temp-")
+// copyToTempfile saves p into a tempfile in Paths.Temp and returns the name
+// of the tempfile and the number of bytes written.
+func (b *Local) copyToTempfile(rd io.Reader) (filename string, size int64, err error) {
+	opts := tempfileOptions{prefix: b.tempPrefix()}
+	return writeTempfile(b.FS, filepath.Join(b.Path, backend.Paths.Temp), rd, opts)
+}
+
+// tempfileOptions controls how writeTempfile writes the data.
+type tempfileOptions struct {
+	// sparse skips long runs of zeroes instead of writing them, which
+	// creates holes in the file
+	sparse bool
+
+	// dataSync flushes only the data instead of data and metadata
+	dataSync bool
+
+	// bufferSize is the size of the buffer used for copying the data, zero
+	// selects DefaultCopyBufferSize
+	bufferSize int
+
+	// prefix is the prefix of the name of the tempfile
+	prefix string
+}
+
+// writeTempfile saves rd into a tempfile in tempdir with the given options.
+func writeTempfile(fsys FS, tempdir string, rd io.Reader, opts tempfileOptions) (filename string, size int64, err error) {
+	tmpfile, err := fsys.TempFile(tempdir, opts.prefix)
 	if err != nil {
-		return "", errors.Wrap(err, "TempFile")
+		return "", 0, errors.Wrap(err, "TempFile")
 	}
 
-	_, err = io.Copy(tmpfile, rd)
+	defer func() {
+		if err != nil {
+			tmpfile.Close()
+			fsys.Remove(tmpfile.Name())
+		}
+	}()
+
+	buf := getCopyBuffer(opts.bufferSize)
+	defer putCopyBuffer(buf)
+
+	if opts.sparse {
+		w := &sparseWriter{f: tmpfile}
+		size, err = io.CopyBuffer(w, rd, buf)
+		if err == nil {
+			err = w.finish()
+		}
+	} else {
+		size, err = io.CopyBuffer(tmpfile, rd, buf)
+	}
 	if err != nil {
-		return "", errors.Wrap(err, "Write")
+		return "", 0, noSpace(err, "Write")
 	}
 
-	if err = tmpfile.Sync(); err != nil {
-		return "", errors.Wrap(err, "Syncn")
+	if opts.dataSync {
+		err = syncData(tmpfile)
+	} else {
+		err = tmpfile.Sync()
+	}
+	if err != nil {
+		return "", 0, errors.Wrap(err, "Syncn")
 	}
 
 	err = tmpfile.Close()
 	if err != nil {
-		return "", errors.Wrap(err, "Close")
+		return "", 0, errors.Wrap(err, "Close")
 	}
 
-	return tmpfile.Name(), nil
+	return tmpfile.Name(), size, nil
 }
 
+// ErrNameCollidesWithDirectory is returned by Save and Replace if a
+// directory exists where the file should be stored. This does not happen in
+// an intact repository.
+var ErrNameCollidesWithDirectory = errors.New("name collides with a directory")
+
+// ErrEmptyBlob is returned by Save if RejectEmpty is set and no data was
+// read from the reader.
+var ErrEmptyBlob = errors.New("refusing to save empty file")
+
 // Save stores data in the backend at the handle.
 func (b *Local) Save(h restic.Handle, rd io.Reader) (err error) {
 	debug.Log("Save %v", h)
+	if b.AccessLog {
+		cr := &countingReader{rd: rd}
+		rd = cr
+		defer func(start time.Time) {
+			b.logAccess("Save", h, start, cr.n, err)
+		}(time.Now())
+	}
+
+	return b.save(h, rd, saveOptions{})
+}
+
+// saveOptions modify the behavior of save.
+type saveOptions struct {
+	// if maxBytes is positive and the reader yields more data, ErrTooLarge
+	// is returned.
+	maxBytes int64
+
+	// date selects the date bucket for snapshot files, the current time is
+	// used if it is zero.
+	date time.Time
+
+	// if crc is not nil, it has been fed all data written to the tempfile
+	// and the sum is stored in the CRC32C sidecar file.
+	crc hash.Hash32
+
+	// overwrite replaces an existing file regardless of OnExist.
+	overwrite bool
+
+	// if readBack is not nil, it has recorded the data written to the
+	// tempfile, which is read back and compared before committing.
+	readBack *readBack
+
+	// if stored is not nil, the size of the file on disk is stored there.
+	stored *int64
+}
+
+// record sets up opts for recording the data saved, as required by the
+// configuration.
+func (b *Local) record(opts saveOptions) saveOptions {
+	if b.CRC32C {
+		opts.crc = newCRC()
+	}
+	if b.ParanoidVerify {
+		opts.readBack = newReadBack()
+	}
+	return opts
+}
+
+// tee returns a reader which passes the data read from rd to the recorders
+// in opts.
+func (opts saveOptions) tee(rd io.Reader) io.Reader {
+	if opts.crc != nil {
+		rd = io.TeeReader(rd, opts.crc)
+	}
+	if opts.readBack != nil {
+		rd = io.TeeReader(rd, opts.readBack)
+	}
+	return rd
+}
+
+// target returns the file name a new file for h is saved to.
+func (b *Local) target(h restic.Handle, opts saveOptions) string {
+	if h.Type == restic.SnapshotFile && b.SnapshotBuckets {
+		date := opts.date
+		if date.IsZero() {
+			date = time.Now()
+		}
+
+		return filepath.Join(b.Path, backend.Paths.Snapshots, snapshotBucket(date), b.naming().Encode(h.Name))
+	}
+
+	if h.Type == restic.DataFile {
+		return b.dataTarget(h.Name)
+	}
+
+	return b.filename(h.Type, h.Name)
+}
+
+// save stores data in the backend at the handle.
+func (b *Local) save(h restic.Handle, rd io.Reader, opts saveOptions) (err error) {
 	if err := h.Valid(); err != nil {
 		return err
 	}
 
-	tmpfile, err := copyToTempfile(filepath.Join(b.Path, backend.Paths.Temp), rd)
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return err
+	}
+
+	if b.SaveHook != nil {
+		if rd, err = b.SaveHook(h, rd); err != nil {
+			return errors.Wrap(err, "SaveHook")
+		}
+	}
+
+	if opts.maxBytes > 0 {
+		// read one more byte to detect overlong input
+		rd = io.LimitReader(rd, opts.maxBytes+1)
+	}
+
+	var hash hash.Hash
+	if b.VerifyHash && isContentAddressed(h.Type) {
+		if err := b.checkHashName(h); err != nil {
+			return err
+		}
+
+		hash = b.newHash()
+		rd = io.TeeReader(rd, hash)
+	}
+
+	opts = b.record(opts)
+	rd = opts.tee(rd)
+
+	if b.small != nil && b.small.threshold > 0 && isContentAddressed(h.Type) {
+		buf, small, err := b.readSmall(rd)
+		if err != nil {
+			return err
+		}
+		if small {
+			return b.savePacked(h, buf, hash, opts)
+		}
+		rd = io.MultiReader(bytes.NewReader(buf), rd)
+	}
+
+	tmpOpts := tempfileOptions{
+		sparse:     b.Sparse && sparseSupported,
+		dataSync:   b.DataSync,
+		bufferSize: b.CopyBufferSize,
+		prefix:     b.tempPrefix(),
+	}
+	tmpfile, size, err := writeTempfile(b.FS, filepath.Join(b.Path, backend.Paths.Temp), rd, tmpOpts)
 	debug.Log("saved %v to %v", h, tmpfile)
 	if err != nil {
 		return err
 	}
 
-	filename := filename(b.Path, h.Type, h.Name)
+	return b.commit(h, tmpfile, size, hash, opts)
+}
+
+// commit checks the tempfile containing size bytes written for h and moves
+// it into place. If hash is not nil, it must have been fed all data written
+// to the tempfile, and the result is compared to the name. The tempfile is
+// removed if an error occurs.
+func (b *Local) commit(h restic.Handle, tmpfile string, size int64, hash hash.Hash, opts saveOptions) (err error) {
+	defer func() {
+		if err != nil {
+			b.FS.Remove(tmpfile)
+		}
+	}()
 
-	// test if new path already exists
-	if _, err := fs.Stat(filename); err == nil {
-		return errors.Errorf("Rename(): file %v already exists", filename)
+	if err := b.checkRecoverMode(); err != nil {
+		return err
 	}
 
-	// create directories if necessary, ignore errors
-	if h.Type == restic.DataFile {
-		err = fs.MkdirAll(filepath.Dir(filename), backend.Modes.Dir)
-		if err != nil {
-			return errors.Wrap(err, "MkdirAll")
+	if opts.maxBytes > 0 && size > opts.maxBytes {
+		return errors.Wrapf(ErrTooLarge, "%v is larger than %d bytes", h, opts.maxBytes)
+	}
+
+	if b.RejectEmpty && size == 0 {
+		return errors.Wrapf(ErrEmptyBlob, "%v", h)
+	}
+
+	if hash != nil {
+		if id := hex.EncodeToString(hash.Sum(nil)); id != h.Name {
+			return errors.Wrapf(ErrHashMismatch, "%v has hash %v", h, id)
+		}
+	}
+
+	if opts.readBack != nil {
+		if err = b.checkReadBack(tmpfile, size, opts.readBack); err != nil {
+			return err
+		}
+	}
+
+	if b.Footer {
+		if err = b.appendFooter(h, tmpfile, size); err != nil {
+			return err
 		}
 	}
 
-	err = fs.Rename(tmpfile, filename)
+	if opts.stored != nil {
+		*opts.stored = size
+		if b.Footer {
+			*opts.stored += FooterSize
+		}
+	}
+
+	filename := b.target(h, opts)
+
+	// test if new path already exists, unless the caller knows it does not
+	if !b.SkipExistCheck {
+		if fn, fi, err := b.locate(h); err == nil {
+			if fi.IsDir() {
+				return errors.Wrap(ErrNameCollidesWithDirectory, fn)
+			}
+
+			policy := b.OnExist
+			if opts.overwrite {
+				policy = OnExistOverwrite
+			}
+
+			switch policy {
+			case OnExistSkip:
+				debug.Log("%v already exists, skipping", h)
+				return b.FS.Remove(tmpfile)
+			case OnExistOverwrite:
+				debug.Log("%v already exists, overwriting %v", h, fn)
+				filename = fn
+			default:
+				return errors.Errorf("Rename(): file %v already exists", fn)
+			}
+		}
+	}
+
+	// a packed file is replaced by the file written now
+	_, replacePacked := b.packed(h)
+	if replacePacked && !opts.overwrite && b.OnExist != OnExistOverwrite {
+		if b.OnExist == OnExistSkip {
+			debug.Log("%v already exists packed, skipping", h)
+			return b.FS.Remove(tmpfile)
+		}
+		return errors.Errorf("Save(): file %v already exists", h)
+	}
+
+	// create directories if necessary
+	var dir string
+	if filepath.Dir(filename) != dirname(b.Path, h.Type, "") {
+		dir = filepath.Dir(filename)
+		if err = b.createDir(dir); err != nil {
+			return err
+		}
+	}
+
+	// the sidecars are written before the file is moved into place, so that
+	// Save does not fail for a file which is already stored
+	if b.ParityShards > 0 && h.Type == restic.DataFile {
+		if err = b.writeParity(h, tmpfile); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				b.removeParity(h)
+			}
+		}()
+	}
+
+	if opts.crc != nil {
+		if err = b.writeCRC(h, opts.crc.Sum32()); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				b.removeCRC(h)
+			}
+		}()
+	}
+
+	if b.Pool && isContentAddressed(h.Type) && b.linkFromPool(h, filename) {
+		return b.FS.Remove(tmpfile)
+	}
+
+	var entry string
+	if b.Journal {
+		if entry, err = b.writeJournal(h, tmpfile, filename); err != nil {
+			return err
+		}
+	}
+
+	err = b.renameInto(tmpfile, filename, dir)
 	debug.Log("save %v: rename %v -> %v: %v",
 		h, filepath.Base(tmpfile), filepath.Base(filename), err)
 
 	if err != nil {
+		if entry != "" {
+			b.clearJournal(entry)
+		}
 		return errors.Wrap(err, "Rename")
 	}
 
-	// set mode to read-only
-	fi, err := fs.Stat(filename)
-	if err != nil {
-		return errors.Wrap(err, "Stat")
+	if !b.NoReadOnly {
+		// set mode to read-only
+		fi, err := b.FS.Stat(filename)
+		if err != nil {
+			return errors.Wrap(err, "Stat")
+		}
+
+		if err = setNewFileMode(b.FS, filename, fi); err != nil {
+			return err
+		}
+	}
+
+	if entry != "" {
+		b.clearJournal(entry)
+	}
+
+	if replacePacked {
+		if err = b.removePacked(h); err != nil {
+			return err
+		}
 	}
 
-	return setNewFileMode(filename, fi)
+	if b.SyncOnClose {
+		b.unsynced.add(filepath.Dir(filename))
+	}
+
+	if h.Type == restic.SnapshotFile && dir != "" {
+		b.buckets.add(dir)
+	}
+
+	if b.MaxShardEntries > 0 && h.Type == restic.DataFile {
+		b.shards.inc(filepath.Dir(filename))
+	}
+
+	if b.Pool && isContentAddressed(h.Type) {
+		b.addToPool(h, filename)
+	}
+
+	return nil
 }
 
 // Load returns a reader that yields the contents of the file at h at the
@@ -175,6 +692,21 @@ func (b *Local) Save(h restic.Handle, rd io.Reader) (err error) {
 // returned. rd must be closed after use.
 func (b *Local) Load(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
 	debug.Log("Load %v, length %v, offset %v", h, length, offset)
+	if !b.AccessLog {
+		return b.load(h, length, offset)
+	}
+
+	start := time.Now()
+	rd, err := b.load(h, length, offset)
+	if err != nil {
+		b.logAccess("Load", h, start, 0, err)
+		return nil, err
+	}
+
+	return &accessLogReader{ReadCloser: rd, be: b, h: h, start: start}, nil
+}
+
+func (b *Local) load(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
 	if err := h.Valid(); err != nil {
 		return nil, err
 	}
@@ -183,11 +715,44 @@ func (b *Local) Load(h restic.Handle, length int, offset int64) (io.ReadCloser,
 		return nil, errors.New("offset is negative")
 	}
 
-	f, err := os.Open(filename(b.Path, h.Type, h.Name))
+	if err := b.checkPermitted(h.Type); err != nil {
+		return nil, err
+	}
+
+	if e, ok := b.packed(h); ok {
+		if b.DetectTruncation && length > 0 {
+			if err := checkTruncated(h, e.Length, length, offset); err != nil {
+				return nil, err
+			}
+		}
+		return b.loadPacked(e, length, offset)
+	}
+
+	if b.Footer {
+		return b.loadContent(h, length, offset)
+	}
+
+	f, err := b.openFile(h)
+	if os.IsNotExist(errors.Cause(err)) {
+		return b.loadParts(h, length, offset, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if b.DetectTruncation && length > 0 {
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "Stat")
+		}
+
+		if err = checkTruncated(h, fi.Size(), length, offset); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
 	if offset > 0 {
 		_, err = f.Seek(offset, 0)
 		if err != nil {
@@ -204,13 +769,49 @@ func (b *Local) Load(h restic.Handle, length int, offset int64) (io.ReadCloser,
 }
 
 // Stat returns information about a blob.
-func (b *Local) Stat(h restic.Handle) (restic.FileInfo, error) {
+func (b *Local) Stat(h restic.Handle) (info restic.FileInfo, err error) {
 	debug.Log("Stat %v", h)
+	if b.AccessLog {
+		defer func(start time.Time) {
+			b.logAccess("Stat", h, start, 0, err)
+		}(time.Now())
+	}
+
 	if err := h.Valid(); err != nil {
 		return restic.FileInfo{}, err
 	}
 
-	fi, err := fs.Stat(filename(b.Path, h.Type, h.Name))
+	if err := b.checkPermitted(h.Type); err != nil {
+		return restic.FileInfo{}, err
+	}
+
+	if e, ok := b.packed(h); ok {
+		return restic.FileInfo{Size: e.Length}, nil
+	}
+
+	if b.Footer {
+		f, size, err := b.openContent(h)
+		if os.IsNotExist(errors.Cause(err)) {
+			size, err := b.partsSize(h, err)
+			if err != nil {
+				return restic.FileInfo{}, errors.Wrap(err, "Stat")
+			}
+			return restic.FileInfo{Size: size}, nil
+		}
+		if err != nil {
+			return restic.FileInfo{}, errors.Wrap(err, "Stat")
+		}
+		return restic.FileInfo{Size: size}, errors.Wrap(f.Close(), "Close")
+	}
+
+	fi, err := b.statFile(h)
+	if os.IsNotExist(errors.Cause(err)) {
+		size, err := b.partsSize(h, err)
+		if err != nil {
+			return restic.FileInfo{}, errors.Wrap(err, "Stat")
+		}
+		return restic.FileInfo{Size: size}, nil
+	}
 	if err != nil {
 		return restic.FileInfo{}, errors.Wrap(err, "Stat")
 	}
@@ -219,9 +820,26 @@ func (b *Local) Stat(h restic.Handle) (restic.FileInfo, error) {
 }
 
 // Test returns true if a blob of the given type and name exists in the backend.
-func (b *Local) Test(h restic.Handle) (bool, error) {
+func (b *Local) Test(h restic.Handle) (found bool, err error) {
 	debug.Log("Test %v", h)
-	_, err := fs.Stat(filename(b.Path, h.Type, h.Name))
+	if b.AccessLog {
+		defer func(start time.Time) {
+			b.logAccess("Test", h, start, 0, err)
+		}(time.Now())
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return false, err
+	}
+
+	if _, ok := b.packed(h); ok {
+		return true, nil
+	}
+
+	_, err = b.statFile(h)
+	if os.IsNotExist(errors.Cause(err)) {
+		_, err = b.partsSize(h, err)
+	}
 	if err != nil {
 		if os.IsNotExist(errors.Cause(err)) {
 			return false, nil
@@ -233,25 +851,71 @@ func (b *Local) Test(h restic.Handle) (bool, error) {
 }
 
 // Remove removes the blob with the given name and type.
-func (b *Local) Remove(h restic.Handle) error {
+func (b *Local) Remove(h restic.Handle) (err error) {
 	debug.Log("Remove %v", h)
-	fn := filename(b.Path, h.Type, h.Name)
+	if b.AccessLog {
+		defer func(start time.Time) {
+			b.logAccess("Remove", h, start, 0, err)
+		}(time.Now())
+	}
 
-	// reset read-only flag
-	err := fs.Chmod(fn, 0666)
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return err
+	}
+
+	if _, ok := b.packed(h); ok {
+		return b.removePacked(h)
+	}
+
+	fn, _, err := b.locate(h)
 	if err != nil {
-		return errors.Wrap(err, "Chmod")
+		fn = b.filename(h.Type, h.Name)
 	}
 
-	return fs.Remove(fn)
+	if err := b.checkRetention(h, fn); err != nil {
+		return err
+	}
+
+	// reset read-only flag
+	if !b.NoReadOnly {
+		err = b.FS.Chmod(fn, 0666)
+		if err != nil {
+			return errors.Wrap(err, "Chmod")
+		}
+	}
+
+	if err = b.FS.Remove(fn); err != nil {
+		return err
+	}
+
+	if b.ConfirmRemove {
+		if err = b.confirmRemoved(fn); err != nil {
+			return err
+		}
+	}
+
+	if b.CRC32C {
+		b.removeCRC(h)
+	}
+	b.removeMeta(h)
+	if h.Type == restic.DataFile {
+		b.removeOffsets(h)
+		b.removeParity(h)
+	}
+
+	return nil
 }
 
 func isFile(fi os.FileInfo) bool {
 	return fi.Mode()&(os.ModeType|os.ModeCharDevice) == 0
 }
 
-func readdir(d string) (fileInfos []os.FileInfo, err error) {
-	f, e := fs.Open(d)
+func readdir(fsys FS, d string) (fileInfos []os.FileInfo, err error) {
+	f, e := fsys.Open(d)
 	if e != nil {
 		return nil, errors.Wrap(e, "Open")
 	}
@@ -267,8 +931,8 @@ func readdir(d string) (fileInfos []os.FileInfo, err error) {
 }
 
 // listDir returns a list of all files in d.
-func listDir(d string) (filenames []string, err error) {
-	fileInfos, err := readdir(d)
+func listDir(fsys FS, d string) (filenames []string, err error) {
+	fileInfos, err := readdir(fsys, d)
 	if err != nil {
 		return nil, err
 	}
@@ -282,9 +946,34 @@ func listDir(d string) (filenames []string, err error) {
 	return filenames, nil
 }
 
-// listDirs returns a list of all files in directories within d.
-func listDirs(dir string) (filenames []string, err error) {
-	fileInfos, err := readdir(dir)
+// listShard returns the files in the data subdirectory d and in its
+// sub-shards.
+func listShard(fsys FS, d string) (filenames []string, err error) {
+	fileInfos, err := readdir(fsys, d)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range fileInfos {
+		switch {
+		case isFile(fi):
+			filenames = append(filenames, fi.Name())
+		case fi.IsDir():
+			files, err := listDir(fsys, filepath.Join(d, fi.Name()))
+			if err != nil {
+				continue
+			}
+			filenames = append(filenames, files...)
+		}
+	}
+
+	return filenames, nil
+}
+
+// listDirs returns a list of all files in directories within d, including
+// those in the sub-shards of the directories.
+func listDirs(fsys FS, dir string) (filenames []string, err error) {
+	fileInfos, err := readdir(fsys, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -294,7 +983,7 @@ func listDirs(dir string) (filenames []string, err error) {
 			continue
 		}
 
-		files, err := listDir(filepath.Join(dir, fi.Name()))
+		files, err := listShard(fsys, filepath.Join(dir, fi.Name()))
 		if err != nil {
 			continue
 		}
@@ -309,23 +998,43 @@ func listDirs(dir string) (filenames []string, err error) {
 // goroutine is started for this. If the channel done is closed, sending
 // stops.
 func (b *Local) List(t restic.FileType, done <-chan struct{}) <-chan string {
-	debug.Log("List %v", t)
+	return b.ListFilter(t, func(string) bool { return true }, done)
+}
+
+// ListFilter works like List, but only yields the names for which match
+// returns true. match is called in the goroutine producing the names.
+func (b *Local) ListFilter(t restic.FileType, match func(name string) bool, done <-chan struct{}) <-chan string {
+	debug.Log("ListFilter %v", t)
+	if b.checkPermitted(t) != nil {
+		ch := make(chan string)
+		close(ch)
+		return ch
+	}
+
+	if t == restic.DataFile && b.ListDirDelay > 0 {
+		return b.listThrottled(match, done)
+	}
+
 	lister := listDir
-	if t == restic.DataFile {
+	switch t {
+	case restic.DataFile:
 		lister = listDirs
+	case restic.SnapshotFile:
+		lister = b.listSnapshots
 	}
 
 	ch := make(chan string)
-	items, err := lister(filepath.Join(dirname(b.Path, t, "")))
+	items, err := lister(b.FS, filepath.Join(dirname(b.Path, t, "")))
 	if err != nil {
 		close(ch)
 		return ch
 	}
+	items = append(b.decodeNames(items), b.listPacked(t)...)
 
 	go func() {
 		defer close(ch)
 		for _, m := range items {
-			if m == "" {
+			if m == "" || !match(m) {
 				continue
 			}
 
@@ -343,13 +1052,44 @@ func (b *Local) List(t restic.FileType, done <-chan struct{}) <-chan string {
 // Delete removes the repository and all files.
 func (b *Local) Delete() error {
 	debug.Log("Delete()")
-	return fs.RemoveAll(b.Path)
+	if err := b.checkDelete(); err != nil {
+		return err
+	}
+
+	b.dirs.reset()
+	return b.FS.RemoveAll(b.Path)
+}
+
+// checkDelete returns an error if the repository must not be deleted.
+func (b *Local) checkDelete() error {
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	protected, err := b.Protected()
+	if err != nil {
+		return err
+	}
+	if protected {
+		return errors.Wrap(ErrProtected, b.Path)
+	}
+
+	return nil
 }
 
-// Close closes all open files.
+// Close closes all open files and writes the checksum cache.
 func (b *Local) Close() error {
 	debug.Log("Close()")
-	// this does not need to do anything, all open files are closed within the
-	// same function.
-	return nil
+	// all open files are closed within the same function, only the cache
+	// and the access log need to be written.
+	b.flushAccessLog()
+	err := b.FlushChecksumCache()
+
+	if b.SyncOnClose {
+		if e := b.syncSession(); err == nil {
+			err = e
+		}
+	}
+
+	return err
 }
@@ -4,10 +4,44 @@ package local
 
 import (
 	"os"
-	"restic/fs"
+	"syscall"
 )
 
 // set file to readonly
-func setNewFileMode(f string, fi os.FileInfo) error {
-	return fs.Chmod(f, fi.Mode()&os.FileMode(^uint32(0222)))
+func setNewFileMode(fsys FS, f string, fi os.FileInfo) error {
+	return fsys.Chmod(f, fi.Mode()&os.FileMode(^uint32(0222)))
 }
+
+// linkCount returns the number of hard links to the file.
+func linkCount(fi os.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Nlink), true
+}
+
+// openFileLimit returns the maximum number of open files for the process.
+func openFileLimit() (uint64, bool) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, false
+	}
+	return uint64(rlim.Cur), true
+}
+
+// sparseSupported is true if seeking past the end of a file creates a hole.
+const sparseSupported = true
+
+// dirSyncSupported is true if directories can be opened and synced to
+// persist renames.
+const dirSyncSupported = true
+
+// processAlive returns true if a process with the given ID exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// readOnlyFilesSupported is true since Save makes new files read-only.
+const readOnlyFilesSupported = true
@@ -7,6 +7,32 @@ import (
 // We don't modify read-only on windows,
 // since it will make us unable to delete the file,
 // and this isn't common practice on this platform.
-func setNewFileMode(f string, fi os.FileInfo) error {
+func setNewFileMode(fsys FS, f string, fi os.FileInfo) error {
 	return nil
 }
+
+// linkCount is not available on windows.
+func linkCount(fi os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// openFileLimit is not available on windows.
+func openFileLimit() (uint64, bool) {
+	return 0, false
+}
+
+// sparseSupported is false since files are not marked sparse on windows.
+const sparseSupported = false
+
+// dirSyncSupported is false since directories cannot be synced on windows.
+const dirSyncSupported = false
+
+// processAlive is not available on windows, every process is assumed to be
+// running.
+func processAlive(pid int) bool {
+	return true
+}
+
+// readOnlyFilesSupported is false since new files are not made read-only on
+// windows.
+const readOnlyFilesSupported = false
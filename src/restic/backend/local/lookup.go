@@ -0,0 +1,88 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/backend"
+	"restic/errors"
+)
+
+// candidates returns the file names at which the file for h may be stored,
+// the canonical location first. In MigrationMode, data files are also looked
+// up directly in the data directory, where they reside before a reshard.
+// Snapshot files may also be stored in date buckets, and data files in the
+// sub-shard of their shard if MaxShardEntries is set.
+func (b *Local) candidates(h restic.Handle) []string {
+	fn := b.filename(h.Type, h.Name)
+	names := []string{fn}
+
+	if b.hasSubShard(h.Type, h.Name) {
+		names = append(names, b.subShardFilename(h.Name))
+	}
+
+	if h.Type == restic.DataFile && b.MigrationMode {
+		names = append(names, filepath.Join(b.Path, backend.Paths.Data, filepath.Base(fn)))
+	}
+
+	if h.Type == restic.SnapshotFile && b.bucketed() {
+		for _, dir := range b.snapshotBucketDirs() {
+			names = append(names, filepath.Join(dir, filepath.Base(fn)))
+		}
+	}
+
+	return names
+}
+
+// openFile opens the file for h. If it is not found at the canonical
+// location, the other candidates are tried. The error for the canonical
+// location is returned if the file is not found anywhere.
+func (b *Local) openFile(h restic.Handle) (File, error) {
+	var firstErr error
+	for _, fn := range b.candidates(h) {
+		f, err := b.FS.Open(fn)
+		if err == nil {
+			return f, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		if !os.IsNotExist(errors.Cause(err)) {
+			return nil, err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// locate returns the name of and information about the file for h, trying
+// all candidate locations like openFile.
+func (b *Local) locate(h restic.Handle) (string, os.FileInfo, error) {
+	var firstErr error
+	for _, fn := range b.candidates(h) {
+		fi, err := b.FS.Stat(fn)
+		if err == nil {
+			return fn, fi, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		if !os.IsNotExist(errors.Cause(err)) {
+			return "", nil, err
+		}
+	}
+
+	return "", nil, firstErr
+}
+
+// statFile returns information about the file for h, trying all candidate
+// locations like openFile.
+func (b *Local) statFile(h restic.Handle) (os.FileInfo, error) {
+	_, fi, err := b.locate(h)
+	return fi, err
+}
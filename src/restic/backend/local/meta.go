@@ -0,0 +1,107 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// metaFile returns the path of the sidecar file holding the metadata for h.
+func (b *Local) metaFile(h restic.Handle) string {
+	return filepath.Join(b.Path, localPaths.Meta, string(h.Type), h.Name+".json")
+}
+
+// SetMeta attaches the key-value pairs in meta to the file at h, replacing
+// the metadata set before. The metadata is stored in a sidecar file below
+// meta/ which is replaced atomically, and removed together with the
+// file.
+func (b *Local) SetMeta(h restic.Handle, meta map[string]string) error {
+	debug.Log("SetMeta %v", h)
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	if err := h.Valid(); err != nil {
+		return err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return err
+	}
+
+	if _, err := b.statFile(h); err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	buf, err := json.Marshal(meta)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	fn := b.metaFile(h)
+	if err := b.FS.MkdirAll(filepath.Dir(fn), backend.Modes.Dir); err != nil {
+		return errors.Wrap(err, "MkdirAll")
+	}
+
+	tmpfile, _, err := b.copyToTempfile(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	if err = b.FS.Rename(tmpfile, fn); err != nil {
+		b.FS.Remove(tmpfile)
+		return errors.Wrap(err, "Rename")
+	}
+
+	return nil
+}
+
+// GetMeta returns the metadata attached to the file at h with SetMeta. If
+// none has been set, nil is returned.
+func (b *Local) GetMeta(h restic.Handle) (map[string]string, error) {
+	debug.Log("GetMeta %v", h)
+	if err := h.Valid(); err != nil {
+		return nil, err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return nil, err
+	}
+
+	f, err := b.FS.Open(b.metaFile(h))
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Open")
+	}
+
+	buf, err := ioutil.ReadAll(f)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Read")
+	}
+
+	var meta map[string]string
+	if err = json.Unmarshal(buf, &meta); err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+
+	return meta, nil
+}
+
+// removeMeta removes the metadata for h, errors are ignored.
+func (b *Local) removeMeta(h restic.Handle) {
+	if err := b.FS.Remove(b.metaFile(h)); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		debug.Log("unable to remove metadata for %v: %v", h, err)
+	}
+}
@@ -0,0 +1,57 @@
+package local_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestMeta(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 100)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	// metadata can only be attached to existing files
+	Assert(t, be.SetMeta(h, map[string]string{"host": "foo"}) != nil,
+		"SetMeta succeeded for a missing file")
+
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	meta, err := be.GetMeta(h)
+	OK(t, err)
+	Assert(t, meta == nil, "unexpected metadata %v", meta)
+
+	OK(t, be.SetMeta(h, map[string]string{"host": "foo", "tag": "bar"}))
+	meta, err = be.GetMeta(h)
+	OK(t, err)
+	Equals(t, map[string]string{"host": "foo", "tag": "bar"}, meta)
+
+	// overwrite
+	OK(t, be.SetMeta(h, map[string]string{"host": "baz"}))
+	meta, err = be.GetMeta(h)
+	OK(t, err)
+	Equals(t, map[string]string{"host": "baz"}, meta)
+
+	// the sidecar files are not listed
+	var names []string
+	for name := range be.List(restic.DataFile, nil) {
+		names = append(names, name)
+	}
+	Equals(t, []string{h.Name}, names)
+
+	// metadata is removed with the file
+	OK(t, be.Remove(h))
+	meta, err = be.GetMeta(h)
+	OK(t, err)
+	Assert(t, meta == nil, "metadata %v not removed", meta)
+
+	_, err = os.Stat(filepath.Join(be.Path, "meta", string(h.Type), h.Name+".json"))
+	Assert(t, os.IsNotExist(err), "sidecar file not removed")
+}
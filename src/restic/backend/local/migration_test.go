@@ -0,0 +1,44 @@
+package local_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestMigrationMode(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	// store the file in the old, flat location
+	OK(t, ioutil.WriteFile(filepath.Join(be.Location(), "data", h.Name), data, 0400))
+
+	_, err := be.Load(h, 0, 0)
+	Assert(t, err != nil, "file in flat location found without migration mode")
+
+	be.MigrationMode = true
+
+	buf, err := backend.LoadAll(be, h)
+	OK(t, err)
+	Equals(t, data, buf)
+
+	fi, err := be.Stat(h)
+	OK(t, err)
+	Equals(t, int64(len(data)), fi.Size)
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, ok, "file in flat location not found")
+
+	ok, err = be.Test(restic.Handle{Type: restic.DataFile, Name: restic.Hash(nil).String()})
+	OK(t, err)
+	Assert(t, !ok, "non-existing file found")
+}
@@ -0,0 +1,128 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// misplacedFile is a data file which is not in the shard for its name.
+type misplacedFile struct {
+	path   string // current path
+	target string // path in the correct shard
+}
+
+// byPath sorts misplaced files by their current path.
+type byPath []misplacedFile
+
+func (s byPath) Len() int           { return len(s) }
+func (s byPath) Less(i, j int) bool { return s[i].path < s[j].path }
+func (s byPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// misplacedFiles returns the data files stored directly in the data
+// directory or in the shard of a different name, sorted by path.
+func (b *Local) misplacedFiles() ([]misplacedFile, error) {
+	dir := dirname(b.Path, restic.DataFile, "")
+	entries, err := readdir(b.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []misplacedFile
+	check := func(fn string) {
+		name := b.naming().Decode(filepath.Base(fn))
+		if name == "" {
+			return
+		}
+
+		if target := b.filename(restic.DataFile, name); target != fn {
+			files = append(files, misplacedFile{path: fn, target: target})
+		}
+	}
+
+	for _, fi := range entries {
+		fn := filepath.Join(dir, fi.Name())
+		if isFile(fi) {
+			check(fn)
+			continue
+		}
+
+		if !fi.IsDir() {
+			continue
+		}
+
+		names, err := listDir(b.FS, fn)
+		if err != nil {
+			debug.Log("unable to read %v: %v", fn, err)
+			continue
+		}
+
+		for _, name := range names {
+			check(filepath.Join(fn, name))
+		}
+	}
+
+	sort.Sort(byPath(files))
+	return files, nil
+}
+
+// FindMisplaced returns the paths, relative to the repository, of the data
+// files which are not stored in the shard directory for their name, e.g.
+// files directly in the data directory. List does not return these files.
+func (b *Local) FindMisplaced() ([]string, error) {
+	debug.Log("FindMisplaced")
+	files, err := b.misplacedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, f := range files {
+		rel, err := filepath.Rel(b.Path, f.path)
+		if err != nil {
+			return nil, errors.Wrap(err, "Rel")
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths, nil
+}
+
+// MoveMisplaced moves the files returned by FindMisplaced into their shard
+// directory and returns the number of files moved. Files for which the
+// shard already holds a file of the same name are left where they are.
+func (b *Local) MoveMisplaced() (int, error) {
+	debug.Log("MoveMisplaced")
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	files, err := b.misplacedFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, f := range files {
+		if _, err := b.FS.Lstat(f.target); err == nil {
+			debug.Log("%v already exists, leaving %v", f.target, f.path)
+			continue
+		} else if !os.IsNotExist(errors.Cause(err)) {
+			return moved, errors.Wrap(err, "Lstat")
+		}
+
+		if err := b.createDir(filepath.Dir(f.target)); err != nil {
+			return moved, err
+		}
+
+		if err := b.FS.Rename(f.path, f.target); err != nil {
+			return moved, errors.Wrap(err, "Rename")
+		}
+		moved++
+	}
+
+	return moved, nil
+}
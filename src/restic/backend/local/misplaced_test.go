@@ -0,0 +1,54 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	. "restic/test"
+)
+
+func TestFindMisplaced(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h1, data1 := saveData(t, be, 1, 100)
+	h2, data2 := saveData(t, be, 2, 100)
+	saveData(t, be, 3, 100)
+
+	files, err := be.FindMisplaced()
+	OK(t, err)
+	Equals(t, 0, len(files))
+
+	// move one file to the data directory and another one to a wrong shard
+	datadir := filepath.Join(be.Path, backend.Paths.Data)
+	OK(t, os.Rename(filename(be.Path, h1.Type, h1.Name), filepath.Join(datadir, h1.Name)))
+	wrong := "00"
+	if h2.Name[:2] == wrong {
+		wrong = "01"
+	}
+	OK(t, os.MkdirAll(filepath.Join(datadir, wrong), 0700))
+	OK(t, os.Rename(filename(be.Path, h2.Type, h2.Name), filepath.Join(datadir, wrong, h2.Name)))
+
+	files, err = be.FindMisplaced()
+	OK(t, err)
+	Equals(t, []string{"data/" + wrong + "/" + h2.Name, "data/" + h1.Name}, files)
+
+	names := 0
+	for range be.List(restic.DataFile, nil) {
+		names++
+	}
+	Equals(t, 2, names)
+
+	moved, err := be.MoveMisplaced()
+	OK(t, err)
+	Equals(t, 2, moved)
+
+	files, err = be.FindMisplaced()
+	OK(t, err)
+	Equals(t, 0, len(files))
+	Equals(t, data1, load(t, be, h1, 0, 0))
+	Equals(t, data2, load(t, be, h2, 0, 0))
+}
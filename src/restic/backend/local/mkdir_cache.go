@@ -0,0 +1,83 @@
+package local
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"restic/errors"
+)
+
+// dirCache holds the directories which are known to exist, so that Save
+// does not need to create them again for each file.
+type dirCache struct {
+	m     sync.Mutex
+	known map[string]struct{}
+}
+
+func (c *dirCache) has(dir string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	_, ok := c.known[dir]
+	return ok
+}
+
+func (c *dirCache) add(dir string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.known == nil {
+		c.known = make(map[string]struct{})
+	}
+	c.known[dir] = struct{}{}
+}
+
+// all returns the known directories, sorted by name.
+func (c *dirCache) all() []string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	dirs := make([]string, 0, len(c.known))
+	for dir := range c.known {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// reset forgets all directories, it must be called when directories are
+// removed.
+func (c *dirCache) reset() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.known = nil
+}
+
+// createDir creates the directory dir for a new file unless it is known to
+// exist.
+func (b *Local) createDir(dir string) error {
+	if b.dirs.has(dir) {
+		return nil
+	}
+
+	if err := b.mkdirAll(dir); err != nil {
+		return err
+	}
+
+	b.dirs.add(dir)
+	return nil
+}
+
+// renameInto moves tmpfile to filename in dir. If dir was removed by another
+// process although it is known to exist, it is created again.
+func (b *Local) renameInto(tmpfile, filename, dir string) error {
+	err := b.FS.Rename(tmpfile, filename)
+	if err == nil || dir == "" || !os.IsNotExist(errors.Cause(err)) || !b.dirs.has(dir) {
+		return err
+	}
+
+	b.dirs.reset()
+	if err := b.createDir(dir); err != nil {
+		return err
+	}
+
+	return b.FS.Rename(tmpfile, filename)
+}
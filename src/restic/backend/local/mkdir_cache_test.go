@@ -0,0 +1,116 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+	"time"
+
+	. "restic/test"
+)
+
+// saveInShard saves n new data files whose names start with prefix, using
+// the seeds from seed on. The next unused seed is returned.
+func saveInShard(t testing.TB, be *Local, prefix string, n int, seed int) int {
+	i := seed
+	for ; n > 0; i++ {
+		data := Random(i, 100)
+		id := restic.Hash(data).String()
+		if id[:len(prefix)] != prefix {
+			continue
+		}
+
+		OK(t, be.Save(restic.Handle{Type: restic.DataFile, Name: id}, bytes.NewReader(data)))
+		n--
+	}
+	return i
+}
+
+func TestMkdirCache(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	ops := make(map[string]int)
+	be.FS = countOps(be.FS, ops)
+
+	seed := saveInShard(t, be, "a0", 5, 0)
+	Equals(t, 1, ops["MkdirAll"])
+
+	// the shard directory is known now
+	seed = saveInShard(t, be, "a0", 3, seed)
+	Equals(t, 1, ops["MkdirAll"])
+
+	// removed directories are created again
+	OK(t, be.DeleteTypes([]restic.FileType{restic.DataFile}, true))
+	mkdirs := ops["MkdirAll"]
+	seed = saveInShard(t, be, "a0", 1, seed)
+	Equals(t, mkdirs+1, ops["MkdirAll"])
+
+	// also when another process removed the directory
+	OK(t, os.RemoveAll(filepath.Join(be.Path, "data")))
+	OK(t, os.Mkdir(filepath.Join(be.Path, "data"), 0700))
+	saveInShard(t, be, "a0", 1, seed)
+
+	var names []string
+	for name := range be.List(restic.DataFile, nil) {
+		names = append(names, name)
+	}
+	Equals(t, 1, len(names))
+}
+
+func BenchmarkSaveManySmall(b *testing.B) {
+	be, cleanup := TestBackend(b)
+	defer cleanup()
+
+	ops := make(map[string]int)
+	be.FS = countOps(be.FS, ops)
+
+	data := Random(23, 512)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data[0], data[1], data[2] = byte(i), byte(i>>8), byte(i>>16)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(b, be.Save(h, bytes.NewReader(data)))
+	}
+
+	b.Logf("%.2f MkdirAll calls per Save", float64(ops["MkdirAll"])/float64(b.N))
+}
+
+func TestSnapshotBucketCache(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.SnapshotBuckets = true
+
+	var handles []restic.Handle
+	for i := 0; i < 12; i++ {
+		h := restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash([]byte{byte(i)}).String()}
+		date := time.Date(2016+i/4, time.Month(1+i%4), 1, 0, 0, 0, 0, time.Local)
+		OK(t, be.SaveAt(h, bytes.NewReader([]byte{byte(i)}), date))
+		handles = append(handles, h)
+	}
+
+	ops := make(map[string]int)
+	be.FS = countOps(be.FS, ops)
+
+	// the buckets are read once, not for each lookup
+	for i := 0; i < 3; i++ {
+		for _, h := range handles {
+			ok, err := be.Test(h)
+			OK(t, err)
+			Assert(t, ok, "%v not found", h)
+		}
+	}
+	Equals(t, 0, ops["Open"])
+
+	// a bucket created by Save is found without reading the buckets again
+	h := restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash([]byte("new")).String()}
+	OK(t, be.SaveAt(h, bytes.NewReader([]byte("new")), time.Date(2020, 6, 1, 0, 0, 0, 0, time.Local)))
+	opens := ops["Open"]
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, ok, "%v not found", h)
+	Equals(t, opens, ops["Open"])
+}
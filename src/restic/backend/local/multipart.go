@@ -0,0 +1,152 @@
+package local
+
+import (
+	"io"
+	"os"
+	"restic"
+	"strconv"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// fileParts returns the names and sizes of the parts name.0, name.1, ... of
+// the file for h, which is stored in several files, e.g. because it exceeds
+// the size limit of the file system. names is empty if there are no parts.
+func (b *Local) fileParts(h restic.Handle) (names []string, sizes []int64, err error) {
+	fn := b.filename(h.Type, h.Name)
+	for i := 0; ; i++ {
+		part := fn + "." + strconv.Itoa(i)
+		fi, err := b.FS.Stat(part)
+		if os.IsNotExist(errors.Cause(err)) {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Stat")
+		}
+
+		names = append(names, part)
+		sizes = append(sizes, fi.Size())
+	}
+
+	return names, sizes, nil
+}
+
+// loadParts returns a reader for the file h stored in parts like Load, or
+// notFound if there are no parts.
+func (b *Local) loadParts(h restic.Handle, length int, offset int64, notFound error) (io.ReadCloser, error) {
+	names, sizes, err := b.fileParts(h)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, notFound
+	}
+
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	debug.Log("%v is stored in %d parts, %d bytes", h, len(names), total)
+
+	if b.DetectTruncation && length > 0 {
+		if err := checkTruncated(h, total, length, offset); err != nil {
+			return nil, err
+		}
+	}
+
+	left := total - offset
+	if left < 0 {
+		left = 0
+	}
+	if length > 0 && int64(length) < left {
+		left = int64(length)
+	}
+
+	rd := &partReader{fsys: b.FS, parts: names, left: left, skip: offset}
+	for rd.idx < len(sizes)-1 && rd.skip >= sizes[rd.idx] {
+		rd.skip -= sizes[rd.idx]
+		rd.idx++
+	}
+
+	return rd, nil
+}
+
+// partsSize returns the total size of the parts of h, or notFound if there
+// are none.
+func (b *Local) partsSize(h restic.Handle, notFound error) (int64, error) {
+	names, sizes, err := b.fileParts(h)
+	if err != nil {
+		return 0, err
+	}
+	if len(names) == 0 {
+		return 0, notFound
+	}
+
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	return total, nil
+}
+
+// partReader reads left bytes from the concatenation of the parts, starting
+// skip bytes into the part idx. Each part is opened when it is reached.
+type partReader struct {
+	fsys  FS
+	parts []string
+	idx   int
+	skip  int64
+	left  int64
+	cur   File
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	for r.left > 0 {
+		if r.cur == nil {
+			if r.idx >= len(r.parts) {
+				return 0, io.ErrUnexpectedEOF
+			}
+
+			f, err := r.fsys.Open(r.parts[r.idx])
+			if err != nil {
+				return 0, errors.Wrap(err, "Open")
+			}
+			r.cur = f
+
+			if r.skip > 0 {
+				if _, err = f.Seek(r.skip, 0); err != nil {
+					return 0, errors.Wrap(err, "Seek")
+				}
+				r.skip = 0
+			}
+		}
+
+		if int64(len(p)) > r.left {
+			p = p[:r.left]
+		}
+
+		n, err := r.cur.Read(p)
+		r.left -= int64(n)
+		if err == io.EOF {
+			err = r.cur.Close()
+			r.cur = nil
+			r.idx++
+			if n == 0 && err == nil {
+				continue
+			}
+		}
+		return n, err
+	}
+
+	return 0, io.EOF
+}
+
+func (r *partReader) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
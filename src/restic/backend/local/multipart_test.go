@@ -0,0 +1,55 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"restic"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestLoadParts(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 3000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	fn := filename(be.Path, h.Type, h.Name)
+	OK(t, os.MkdirAll(dirname(be.Path, h.Type, h.Name), 0700))
+	OK(t, ioutil.WriteFile(fn+".0", data[:1000], 0600))
+	OK(t, ioutil.WriteFile(fn+".1", data[1000:1500], 0600))
+	OK(t, ioutil.WriteFile(fn+".2", data[1500:], 0600))
+
+	fi, err := be.Stat(h)
+	OK(t, err)
+	Equals(t, int64(len(data)), fi.Size)
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, ok, "split file not found by Test")
+
+	Equals(t, data, load(t, be, h, 0, 0))
+
+	// ranges within a part, spanning two parts and up to the end
+	Equals(t, data[100:200], load(t, be, h, 100, 100))
+	Equals(t, data[900:1100], load(t, be, h, 200, 900))
+	Equals(t, data[1000:1500], load(t, be, h, 500, 1000))
+	Equals(t, data[1400:], load(t, be, h, 0, 1400))
+	Equals(t, data[2999:], load(t, be, h, 10, 2999))
+	Equals(t, []byte{}, load(t, be, h, 0, 3000))
+
+	// files which are not split use the usual path
+	h2, data2 := saveData(t, be, 5, 100)
+	Equals(t, data2, load(t, be, h2, 0, 0))
+
+	missing := restic.Handle{Type: restic.DataFile, Name: restic.Hash(nil).String()}
+	_, err = be.Load(missing, 0, 0)
+	Assert(t, os.IsNotExist(errors.Cause(err)), "expected not-exist error, got %v", err)
+	_, err = be.Stat(missing)
+	Assert(t, os.IsNotExist(errors.Cause(err)), "expected not-exist error, got %v", err)
+	ok, err = be.Test(missing)
+	OK(t, err)
+	Assert(t, !ok, "missing file found by Test")
+}
@@ -0,0 +1,64 @@
+package local
+
+import (
+	"path/filepath"
+	"restic"
+)
+
+// Naming translates between the names of files in the repository and the
+// names used on disk, so that repositories written by tools with a
+// different naming convention for the same hashes can be accessed. Only the
+// file name is translated, the directory structure stays the same, data
+// files are stored in subdirectories by the first two characters of the
+// name, not of the disk name.
+type Naming interface {
+	// Encode returns the disk name for name.
+	Encode(name string) string
+
+	// Decode returns the name for the disk name, or the empty string if
+	// the disk name was not created by Encode. Such files are ignored.
+	Decode(diskName string) string
+}
+
+type identityNaming struct{}
+
+func (identityNaming) Encode(name string) string     { return name }
+func (identityNaming) Decode(diskName string) string { return diskName }
+
+// IdentityNaming stores files under their names. It is used if Naming is
+// nil.
+var IdentityNaming Naming = identityNaming{}
+
+// naming returns the Naming in use.
+func (b *Local) naming() Naming {
+	if b.Naming == nil {
+		return IdentityNaming
+	}
+	return b.Naming
+}
+
+// filename returns the path of the file of type t with the given name,
+// translated by the Naming in use.
+func (b *Local) filename(t restic.FileType, name string) string {
+	if t == restic.ConfigFile {
+		return filename(b.Path, t, name)
+	}
+
+	return filepath.Join(dirname(b.Path, t, name), b.naming().Encode(name))
+}
+
+// decodeNames translates disk names returned by listDir to names in place,
+// names which cannot be decoded are dropped.
+func (b *Local) decodeNames(diskNames []string) []string {
+	if b.Naming == nil {
+		return diskNames
+	}
+
+	names := diskNames[:0]
+	for _, diskName := range diskNames {
+		if name := b.Naming.Decode(diskName); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,85 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+	"strings"
+	"testing"
+
+	. "restic/test"
+)
+
+// prefixNaming stores files with upper-case names and a prefix.
+type prefixNaming struct{}
+
+func (prefixNaming) Encode(name string) string {
+	return "sha256:" + strings.ToUpper(name)
+}
+
+func (prefixNaming) Decode(diskName string) string {
+	if !strings.HasPrefix(diskName, "sha256:") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(diskName, "sha256:"))
+}
+
+func TestNaming(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.Naming = prefixNaming{}
+
+	var names []string
+	for i := 0; i < 5; i++ {
+		data := Random(i, 100)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		names = append(names, h.Name)
+
+		// the file is stored under the encoded name in the usual shard
+		_, err := os.Stat(filepath.Join(be.Path, "data", h.Name[:2], "sha256:"+strings.ToUpper(h.Name)))
+		OK(t, err)
+
+		ok, err := be.Test(h)
+		OK(t, err)
+		Assert(t, ok, "file %v not found", h)
+		Equals(t, data, load(t, be, h, 0, 0))
+	}
+	sort.Strings(names)
+
+	lock := restic.Handle{Type: restic.LockFile, Name: "abcd"}
+	OK(t, be.Save(lock, bytes.NewReader([]byte("lock"))))
+
+	// files not written with the naming are ignored
+	OK(t, ioutil.WriteFile(filepath.Join(be.Path, "locks", "other"), []byte("x"), 0600))
+
+	var listed []string
+	for name := range be.List(restic.DataFile, nil) {
+		listed = append(listed, name)
+	}
+	sort.Strings(listed)
+	Equals(t, names, listed)
+
+	Equals(t, []string{"abcd"}, collect(be.List(restic.LockFile, nil)))
+	Equals(t, names[1:], collect(be.ListFrom(restic.DataFile, names[0], nil)))
+
+	l, err := be.Snapshot()
+	OK(t, err)
+	Equals(t, names, l.Names(restic.DataFile))
+
+	res, err := be.TestMany([]restic.Handle{lock, {Type: restic.LockFile, Name: "other"}})
+	OK(t, err)
+	Assert(t, res[lock], "lock not found by TestMany")
+
+	// the config file is not renamed
+	cfg := restic.Handle{Type: restic.ConfigFile}
+	OK(t, be.Save(cfg, bytes.NewReader([]byte("config"))))
+	_, err = os.Stat(filepath.Join(be.Path, "config"))
+	OK(t, err)
+
+	OK(t, be.Remove(lock))
+	Equals(t, []string{}, collect(be.List(restic.LockFile, nil)))
+}
@@ -0,0 +1,86 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+// countOps returns a fakeFS which counts the calls of each operation.
+func countOps(fsys FS, ops map[string]int) *fakeFS {
+	return &fakeFS{FS: fsys, fail: func(op, name string) error {
+		ops[op]++
+		return nil
+	}}
+}
+
+func TestNoReadOnly(t *testing.T) {
+	for _, noReadOnly := range []bool{false, true} {
+		be, cleanup := TestBackend(t)
+		be.NoReadOnly = noReadOnly
+
+		ops := make(map[string]int)
+		be.FS = countOps(be.FS, ops)
+
+		data := Random(23, 100)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+
+		fi, err := os.Stat(filename(be.Path, h.Type, h.Name))
+		OK(t, err)
+		writable := fi.Mode()&0200 != 0
+
+		if noReadOnly {
+			Equals(t, 0, ops["Chmod"])
+			Assert(t, writable, "file is read-only: %v", fi.Mode())
+		} else {
+			Equals(t, 1, ops["Chmod"])
+			Assert(t, !writable, "file is writable: %v", fi.Mode())
+		}
+
+		ops["Chmod"] = 0
+		OK(t, be.Remove(h))
+		if noReadOnly {
+			Equals(t, 0, ops["Chmod"])
+		} else {
+			Equals(t, 1, ops["Chmod"])
+		}
+
+		cleanup()
+	}
+}
+
+func benchmarkSaveSmall(b *testing.B, noReadOnly bool) {
+	be, cleanup := TestBackend(b)
+	defer cleanup()
+	be.NoReadOnly = noReadOnly
+
+	ops := make(map[string]int)
+	be.FS = countOps(be.FS, ops)
+
+	data := Random(23, 512)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data[0], data[1], data[2] = byte(i), byte(i>>8), byte(i>>16)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(b, be.Save(h, bytes.NewReader(data)))
+	}
+
+	syscalls := 0
+	for _, n := range ops {
+		syscalls += n
+	}
+	b.Logf("%.1f file system operations per Save", float64(syscalls)/float64(b.N))
+}
+
+func BenchmarkSaveSmall(b *testing.B) {
+	benchmarkSaveSmall(b, false)
+}
+
+func BenchmarkSaveSmallNoReadOnly(b *testing.B) {
+	benchmarkSaveSmall(b, true)
+}
@@ -0,0 +1,51 @@
+package local
+
+import (
+	"os"
+	"syscall"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrNoSpace is returned by Save when the file system has no space or no
+// inodes left, regardless of whether creating a directory or writing the
+// data failed.
+var ErrNoSpace = errors.New("no space left on device")
+
+// isENOSPC returns true if err reports that the file system is full.
+func isENOSPC(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case *os.PathError:
+		return e.Err == syscall.ENOSPC
+	case *os.SyscallError:
+		return e.Err == syscall.ENOSPC
+	default:
+		return e == syscall.ENOSPC
+	}
+}
+
+// noSpace returns ErrNoSpace annotated with err if err reports that the file
+// system is full, and err wrapped with msg otherwise.
+func noSpace(err error, msg string) error {
+	if isENOSPC(err) {
+		return errors.Wrapf(ErrNoSpace, "%v: %v", msg, err)
+	}
+	return errors.Wrap(err, msg)
+}
+
+// mkdirAll creates dir for a new file. A concurrent Remove may free space
+// at any time, so the creation is retried once if the file system is full.
+func (b *Local) mkdirAll(dir string) error {
+	err := b.FS.MkdirAll(dir, backend.Modes.Dir)
+	if isENOSPC(err) {
+		debug.Log("no space left to create %v, retrying", dir)
+		err = b.FS.MkdirAll(dir, backend.Modes.Dir)
+	}
+
+	if err != nil {
+		return noSpace(err, "MkdirAll")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"syscall"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestMkdirAllNoSpace(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	fsys := be.FS
+	calls := 0
+	be.FS = &fakeFS{FS: fsys, fail: func(op, name string) error {
+		if op == "MkdirAll" {
+			calls++
+			return syscall.ENOSPC
+		}
+		return nil
+	}}
+
+	err := be.Save(h, bytes.NewReader(data))
+	Assert(t, errors.Cause(err) == ErrNoSpace, "expected ErrNoSpace, got %v", err)
+	Equals(t, 2, calls)
+
+	// a retry which succeeds saves the file
+	calls = 0
+	be.FS = &fakeFS{FS: fsys, fail: func(op, name string) error {
+		if op == "MkdirAll" {
+			calls++
+			if calls == 1 {
+				return syscall.ENOSPC
+			}
+		}
+		return nil
+	}}
+
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	Equals(t, 2, calls)
+	Equals(t, data, load(t, be, h, 0, 0))
+
+	// other errors are not retried
+	calls = 0
+	h2 := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data[:100]).String()}
+	be.FS = &fakeFS{FS: fsys, fail: func(op, name string) error {
+		if op == "MkdirAll" {
+			calls++
+			return syscall.EACCES
+		}
+		return nil
+	}}
+
+	err = be.Save(h2, bytes.NewReader(data[:100]))
+	Assert(t, err != nil && errors.Cause(err) != ErrNoSpace, "expected EACCES, got %v", err)
+	Equals(t, 1, calls)
+}
@@ -0,0 +1,45 @@
+package local_test
+
+import (
+	"restic"
+	"strings"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestOnExist(t *testing.T) {
+	var tests = []struct {
+		policy local.OnExistPolicy
+		ok     bool
+		data   string
+	}{
+		{local.OnExistError, false, "old"},
+		{local.OnExistSkip, true, "old"},
+		{local.OnExistOverwrite, true, "new"},
+	}
+
+	for _, test := range tests {
+		be, cleanup := local.TestBackend(t)
+		be.OnExist = test.policy
+
+		h := restic.Handle{Type: restic.LockFile, Name: "lock"}
+		OK(t, be.Save(h, strings.NewReader("old")))
+
+		err := be.Save(h, strings.NewReader("new"))
+		if test.ok {
+			OK(t, err)
+		} else {
+			Assert(t, err != nil, "policy %v: no error for existing file", test.policy)
+		}
+
+		buf, err := backend.LoadAll(be, h)
+		OK(t, err)
+		Equals(t, test.data, string(buf))
+		Equals(t, 0, tempfiles(t, be))
+
+		cleanup()
+	}
+}
@@ -0,0 +1,47 @@
+package local
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"restic/errors"
+)
+
+// OpenFunc opens a file like os.OpenFile. It can be set in Config to wrap
+// the files the backend opens, e.g. to trace or throttle them.
+type OpenFunc func(name string, flag int, perm os.FileMode) (File, error)
+
+// maxTempFileTries is the number of names openFuncFS tries for a tempfile
+// before giving up.
+const maxTempFileTries = 10000
+
+// openFuncFS opens all files with open, the other operations are passed to
+// the underlying FS.
+type openFuncFS struct {
+	FS
+	open OpenFunc
+}
+
+func (f openFuncFS) Open(name string) (File, error) {
+	return f.open(name, os.O_RDONLY, 0)
+}
+
+func (f openFuncFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return f.open(name, flag, perm)
+}
+
+// TempFile works like ioutil.TempFile, but creates the file with open.
+func (f openFuncFS) TempFile(dir, prefix string) (File, error) {
+	for i := 0; i < maxTempFileTries; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		file, err := f.open(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(errors.Cause(err)) {
+			continue
+		}
+		return file, err
+	}
+
+	return nil, errors.Errorf("unable to create tempfile in %v", dir)
+}
@@ -0,0 +1,92 @@
+package local
+
+import (
+	"os"
+	"restic"
+	"sync"
+	"testing"
+
+	"restic/fs"
+	. "restic/test"
+)
+
+// fdTracker counts the files opened by an OpenFunc which have not been
+// closed yet.
+type fdTracker struct {
+	m       sync.Mutex
+	opened  int
+	created int
+	open    map[string]int
+}
+
+func (tr *fdTracker) openFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.m.Lock()
+	defer tr.m.Unlock()
+	tr.opened++
+	if flag&os.O_CREATE != 0 {
+		tr.created++
+	}
+	tr.open[name]++
+	return &trackedFile{File: f, tr: tr}, nil
+}
+
+type trackedFile struct {
+	File
+	tr     *fdTracker
+	closed bool
+}
+
+func (f *trackedFile) Close() error {
+	f.tr.m.Lock()
+	if !f.closed {
+		f.closed = true
+		f.tr.open[f.Name()]--
+		if f.tr.open[f.Name()] == 0 {
+			delete(f.tr.open, f.Name())
+		}
+	}
+	f.tr.m.Unlock()
+	return f.File.Close()
+}
+
+func TestOpenFunc(t *testing.T) {
+	repo, cleanup := TestBackend(t)
+	defer cleanup()
+
+	tr := &fdTracker{open: make(map[string]int)}
+	be, err := Open(Config{Path: repo.Path, OpenFunc: tr.openFile})
+	OK(t, err)
+
+	var handles []restic.Handle
+	for i := 0; i < 5; i++ {
+		h, _ := saveData(t, be, i, 1000)
+		handles = append(handles, h)
+	}
+
+	w, err := be.SaveWriter(restic.Handle{Type: restic.LockFile, Name: "lock"})
+	OK(t, err)
+	_, err = w.Write([]byte("lock"))
+	OK(t, err)
+	OK(t, w.Close())
+
+	for name := range be.List(restic.DataFile, nil) {
+		h := restic.Handle{Type: restic.DataFile, Name: name}
+		Equals(t, 100, len(load(t, be, h, 100, 10)))
+
+		_, err := be.Stat(h)
+		OK(t, err)
+	}
+
+	for _, h := range handles {
+		OK(t, be.Remove(h))
+	}
+
+	Assert(t, tr.opened > 0, "no files opened with OpenFunc")
+	Assert(t, tr.created >= len(handles), "tempfiles not created with OpenFunc")
+	Equals(t, map[string]int{}, tr.open)
+}
@@ -0,0 +1,123 @@
+package local
+
+import (
+	"io/ioutil"
+	"restic"
+	"sync"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// packHeaderWorkers is the number of files read in parallel by
+// ForEachPackHeader.
+const packHeaderWorkers = 8
+
+// loadTail returns the last n bytes of the file at h. If the file is smaller
+// than n bytes, the whole file is returned.
+func (b *Local) loadTail(h restic.Handle, n int) ([]byte, error) {
+	fi, err := b.Stat(h)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := fi.Size - int64(n)
+	if offset < 0 {
+		offset = 0
+	}
+
+	rd, err := b.Load(h, n, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadAll(rd)
+	if err != nil {
+		rd.Close()
+		return nil, errors.Wrap(err, "ReadAll")
+	}
+
+	return buf, errors.Wrap(rd.Close(), "Close")
+}
+
+// ForEachPackHeader loads the last headerLen bytes of each data file and
+// calls fn with the handle and the data. Files are read in parallel, so fn
+// must be safe for concurrent use. Files for which skip returns true are not
+// read, which allows resuming an interrupted run. skip may be nil. The first
+// error returned by fn or encountered while reading stops the iteration and
+// is returned. If done is closed, no more files are read and an error is
+// returned.
+func (b *Local) ForEachPackHeader(headerLen int, skip func(name string) bool,
+	fn func(h restic.Handle, header []byte) error, done <-chan struct{}) error {
+
+	debug.Log("ForEachPackHeader, headerLen %v", headerLen)
+
+	stop := make(chan struct{})
+	var once sync.Once
+	var firstErr error
+	setErr := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(stop)
+		})
+	}
+
+	names := make(chan string)
+	go func() {
+		defer close(names)
+
+		listDone := make(chan struct{})
+		defer close(listDone)
+
+		canceled := func() {
+			setErr(errors.New("ForEachPackHeader canceled"))
+		}
+
+		for name := range b.List(restic.DataFile, listDone) {
+			if skip != nil && skip(name) {
+				continue
+			}
+
+			// check done first, select picks randomly among ready cases
+			select {
+			case <-done:
+				canceled()
+				return
+			default:
+			}
+
+			select {
+			case names <- name:
+			case <-stop:
+				return
+			case <-done:
+				canceled()
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < packHeaderWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				h := restic.Handle{Type: restic.DataFile, Name: name}
+				buf, err := b.loadTail(h, headerLen)
+				if err == nil {
+					err = fn(h, buf)
+				}
+
+				if err != nil {
+					debug.Log("error processing %v: %v", h, err)
+					setErr(err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,123 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"sync"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestForEachPackHeader(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	handles := saveRandom(t, be, restic.DataFile, 30)
+	small := restic.Handle{Type: restic.DataFile, Name: restic.Hash([]byte("foo")).String()}
+	OK(t, be.Save(small, bytes.NewReader([]byte("foo"))))
+	handles = append(handles, small)
+
+	var m sync.Mutex
+	headers := make(map[restic.Handle][]byte)
+	OK(t, be.ForEachPackHeader(50, nil, func(h restic.Handle, header []byte) error {
+		m.Lock()
+		defer m.Unlock()
+		headers[h] = header
+		return nil
+	}, nil))
+
+	Equals(t, len(handles), len(headers))
+	for _, h := range handles {
+		buf, err := backend.LoadAll(be, h)
+		OK(t, err)
+		if len(buf) > 50 {
+			buf = buf[len(buf)-50:]
+		}
+		Assert(t, bytes.Equal(buf, headers[h]), "wrong header for %v", h)
+	}
+
+	// skip the files already processed
+	processed := 0
+	OK(t, be.ForEachPackHeader(50, func(name string) bool {
+		return name != small.Name
+	}, func(h restic.Handle, header []byte) error {
+		m.Lock()
+		defer m.Unlock()
+		processed++
+		Equals(t, small, h)
+		return nil
+	}, nil))
+	Equals(t, 1, processed)
+
+	// nothing is read once done is closed
+	done := make(chan struct{})
+	close(done)
+	processed = 0
+	err := be.ForEachPackHeader(50, nil, func(h restic.Handle, header []byte) error {
+		m.Lock()
+		defer m.Unlock()
+		processed++
+		return nil
+	}, done)
+	Assert(t, err != nil, "canceled ForEachPackHeader returned no error")
+	Equals(t, 0, processed)
+}
+
+func TestForEachPackHeaderError(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	saveRandom(t, be, restic.DataFile, 30)
+
+	testErr := errors.New("test error")
+	var m sync.Mutex
+	calls := 0
+	err := be.ForEachPackHeader(50, nil, func(h restic.Handle, header []byte) error {
+		m.Lock()
+		defer m.Unlock()
+		calls++
+		return testErr
+	}, nil)
+	Equals(t, testErr, err)
+	Assert(t, calls < 30, "iteration did not stop after error, %d calls", calls)
+}
+
+func BenchmarkForEachPackHeader(b *testing.B) {
+	be, cleanup := local.TestBackend(b)
+	defer cleanup()
+
+	for i := 0; i < 20; i++ {
+		data := Random(i, 1<<20)
+		OK(b, be.Save(restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}, bytes.NewReader(data)))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		OK(b, be.ForEachPackHeader(1024, nil, func(h restic.Handle, header []byte) error {
+			return nil
+		}, nil))
+	}
+}
+
+func BenchmarkForEachPackHeaderFullRead(b *testing.B) {
+	be, cleanup := local.TestBackend(b)
+	defer cleanup()
+
+	for i := 0; i < 20; i++ {
+		data := Random(i, 1<<20)
+		OK(b, be.Save(restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}, bytes.NewReader(data)))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for name := range be.List(restic.DataFile, nil) {
+			buf, err := backend.LoadAll(be, restic.Handle{Type: restic.DataFile, Name: name})
+			OK(b, err)
+			_ = buf[len(buf)-1024:]
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrNoOffsetTable is returned by LoadBlobFromPack if the pack was not
+// saved with SavePack.
+var ErrNoOffsetTable = errors.New("pack has no offset table")
+
+// ErrBlobNotInPack is returned by LoadBlobFromPack if the offset table
+// does not contain the blob.
+var ErrBlobNotInPack = errors.New("blob not found in pack")
+
+// PackBlob describes the location of a blob within a pack.
+type PackBlob struct {
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// offsetsFile returns the path of the sidecar file holding the offset table
+// for the pack h.
+func (b *Local) offsetsFile(h restic.Handle) string {
+	return filepath.Join(b.Path, localPaths.Offsets, h.Name+".json")
+}
+
+// SavePack works like Save for a data file, and additionally stores the
+// location of the blobs within the data read from rd in a sidecar file below
+// offsets/. This allows LoadBlobFromPack to find a blob without the
+// repository index, e.g. for recovering from a lost index. If a blob is not
+// within the data, the pack is removed again and an error is returned.
+func (b *Local) SavePack(h restic.Handle, rd io.Reader, blobs []PackBlob) error {
+	debug.Log("SavePack %v, %d blobs", h, len(blobs))
+	if h.Type != restic.DataFile {
+		return errors.Errorf("%v is not a data file", h)
+	}
+
+	cr := &countingReader{rd: rd}
+	if err := b.Save(h, cr); err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		if blob.Offset < 0 || blob.Length < 0 || blob.Offset+blob.Length > cr.n {
+			b.Remove(h)
+			return errors.Errorf("blob %v at %d, length %d is not within the %d bytes of %v",
+				blob.ID, blob.Offset, blob.Length, cr.n, h)
+		}
+	}
+
+	buf, err := json.Marshal(blobs)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	fn := b.offsetsFile(h)
+	if err = b.createDir(filepath.Dir(fn)); err != nil {
+		return err
+	}
+
+	tmpfile, _, err := b.copyToTempfile(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	if err = b.FS.Rename(tmpfile, fn); err != nil {
+		b.FS.Remove(tmpfile)
+		return errors.Wrap(err, "Rename")
+	}
+
+	return nil
+}
+
+// LoadBlobFromPack returns a reader for the blob with the given ID in the
+// pack h, which must have been saved with SavePack. ErrNoOffsetTable is
+// returned for other packs, and ErrBlobNotInPack if the pack does not
+// contain the blob.
+func (b *Local) LoadBlobFromPack(h restic.Handle, blobID string) (io.ReadCloser, error) {
+	debug.Log("LoadBlobFromPack %v, blob %v", h, blobID)
+	if h.Type != restic.DataFile {
+		return nil, errors.Errorf("%v is not a data file", h)
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return nil, err
+	}
+
+	f, err := b.FS.Open(b.offsetsFile(h))
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, errors.Wrapf(ErrNoOffsetTable, "%v", h)
+		}
+		return nil, errors.Wrap(err, "Open")
+	}
+
+	var blobs []PackBlob
+	err = json.NewDecoder(f).Decode(&blobs)
+	f.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "Decode")
+	}
+
+	for _, blob := range blobs {
+		if blob.ID != blobID {
+			continue
+		}
+
+		if blob.Length == 0 {
+			return ioutil.NopCloser(bytes.NewReader(nil)), nil
+		}
+		return b.Load(h, int(blob.Length), blob.Offset)
+	}
+
+	return nil, errors.Wrapf(ErrBlobNotInPack, "blob %v in %v", blobID, h)
+}
+
+// removeOffsets removes the offset table of the pack h, if any.
+func (b *Local) removeOffsets(h restic.Handle) {
+	if err := b.FS.Remove(b.offsetsFile(h)); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		debug.Log("unable to remove offset table for %v: %v", h, err)
+	}
+}
@@ -0,0 +1,68 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestLoadBlobFromPack(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	var pack []byte
+	var blobs []local.PackBlob
+	var contents [][]byte
+	for i, size := range []int{100, 2000, 1, 500} {
+		data := Random(i, size)
+		blobs = append(blobs, local.PackBlob{
+			ID:     restic.Hash(data).String(),
+			Offset: int64(len(pack)),
+			Length: int64(len(data)),
+		})
+		contents = append(contents, data)
+		pack = append(pack, data...)
+	}
+	pack = append(pack, []byte("header")...)
+
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(pack).String()}
+	OK(t, be.SavePack(h, bytes.NewReader(pack), blobs))
+	Equals(t, pack, loadAll(t, be, h))
+
+	for i, blob := range blobs {
+		rd, err := be.LoadBlobFromPack(h, blob.ID)
+		OK(t, err)
+		buf, err := ioutil.ReadAll(rd)
+		OK(t, err)
+		OK(t, rd.Close())
+		Equals(t, contents[i], buf)
+	}
+
+	_, err := be.LoadBlobFromPack(h, restic.Hash([]byte("foo")).String())
+	Assert(t, errors.Cause(err) == local.ErrBlobNotInPack, "expected ErrBlobNotInPack, got %v", err)
+
+	// the offset table is removed with the pack
+	OK(t, be.Remove(h))
+	OK(t, be.Save(h, bytes.NewReader(pack)))
+	_, err = be.LoadBlobFromPack(h, blobs[0].ID)
+	Assert(t, errors.Cause(err) == local.ErrNoOffsetTable, "expected ErrNoOffsetTable, got %v", err)
+}
+
+func TestSavePackInvalidOffsets(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 100)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	err := be.SavePack(h, bytes.NewReader(data), []local.PackBlob{{ID: "x", Offset: 50, Length: 51}})
+	Assert(t, err != nil, "blob beyond the end of the pack accepted")
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "pack with invalid offsets was kept")
+}
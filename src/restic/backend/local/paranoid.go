@@ -0,0 +1,88 @@
+package local
+
+import (
+	"bytes"
+	"hash"
+	"io"
+	"io/ioutil"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrReadBackMismatch is returned by Save in ParanoidVerify mode when the
+// data read back from the tempfile differs from the data written.
+var ErrReadBackMismatch = errors.New("data read back from tempfile does not match")
+
+const (
+	// files up to readBackFullSize are read back completely
+	readBackFullSize = 1 << 20
+
+	// for larger files, only the first readBackHeadSize bytes are compared
+	readBackHeadSize = 4096
+)
+
+// readBack records the data written to a tempfile, so that it can be
+// compared to the data read back.
+type readBack struct {
+	head []byte
+	crc  hash.Hash32
+}
+
+func newReadBack() *readBack {
+	return &readBack{crc: newCRC()}
+}
+
+func (r *readBack) Write(p []byte) (int, error) {
+	r.crc.Write(p)
+	if n := readBackHeadSize - len(r.head); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		r.head = append(r.head, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// checkReadBack reopens the tempfile, which must have been synced and
+// closed, and checks that it contains the data recorded in r.
+func (b *Local) checkReadBack(tmpfile string, size int64, r *readBack) error {
+	f, err := b.FS.Open(tmpfile)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	if fi.Size() != size {
+		return errors.Wrapf(ErrReadBackMismatch, "size is %d, want %d", fi.Size(), size)
+	}
+
+	if size <= readBackFullSize {
+		crc := newCRC()
+		if _, err = io.Copy(crc, f); err != nil {
+			return errors.Wrap(err, "Read")
+		}
+
+		if crc.Sum32() != r.crc.Sum32() {
+			return errors.Wrap(ErrReadBackMismatch, "content differs")
+		}
+		return nil
+	}
+
+	head, err := ioutil.ReadAll(io.LimitReader(f, readBackHeadSize))
+	if err != nil {
+		return errors.Wrap(err, "Read")
+	}
+
+	if !bytes.Equal(head, r.head) {
+		return errors.Wrap(ErrReadBackMismatch, "first bytes differ")
+	}
+
+	debug.Log("read back %d bytes of %v", len(head), tmpfile)
+	return nil
+}
@@ -0,0 +1,80 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/errors"
+	. "restic/test"
+)
+
+// readBackFS returns a fakeFS which counts the tempfiles opened for reading
+// and calls modify with the name before each one is opened.
+func readBackFS(fsys FS, tempdir string, opens *int, modify func(string)) *fakeFS {
+	return &fakeFS{FS: fsys, fail: func(op, name string) error {
+		if op == "Open" && filepath.Dir(name) == tempdir {
+			*opens++
+			if modify != nil {
+				modify(name)
+			}
+		}
+		return nil
+	}}
+}
+
+func TestParanoidVerify(t *testing.T) {
+	for _, size := range []int{1000, readBackFullSize + 1000} {
+		be, cleanup := TestBackend(t)
+		be.ParanoidVerify = true
+		tempdir := filepath.Join(be.Path, backend.Paths.Temp)
+		fsys := be.FS
+
+		data := Random(23, size)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+		opens := 0
+		be.FS = readBackFS(fsys, tempdir, &opens, nil)
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		Equals(t, 1, opens)
+
+		// simulate a file system which loses the write
+		opens = 0
+		be.FS = readBackFS(fsys, tempdir, &opens, func(name string) {
+			buf, err := ioutil.ReadFile(name)
+			OK(t, err)
+			buf[0] ^= 0xff
+			OK(t, ioutil.WriteFile(name, buf, 0600))
+		})
+
+		h2 := restic.Handle{Type: restic.SnapshotFile, Name: h.Name}
+		err := be.Save(h2, bytes.NewReader(data))
+		Assert(t, errors.Cause(err) == ErrReadBackMismatch, "expected ErrReadBackMismatch, got %v", err)
+		Equals(t, 1, opens)
+
+		ok, err := be.Test(h2)
+		OK(t, err)
+		Assert(t, !ok, "file was saved although the read back failed")
+
+		entries, err := ioutil.ReadDir(tempdir)
+		OK(t, err)
+		Equals(t, 0, len(entries))
+
+		cleanup()
+	}
+}
+
+func TestParanoidVerifyDisabled(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	opens := 0
+	be.FS = readBackFS(be.FS, filepath.Join(be.Path, backend.Paths.Temp), &opens, nil)
+
+	data := Random(23, 1000)
+	OK(t, be.Save(restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}, bytes.NewReader(data)))
+	Equals(t, 0, opens)
+}
@@ -0,0 +1,275 @@
+package local
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrNoParity is returned by Repair if no parity was stored for the file.
+var ErrNoParity = errors.New("no parity stored for file")
+
+// ErrUnrecoverable is returned by Repair if more parts of the file are
+// damaged than the parity can restore.
+var ErrUnrecoverable = errors.New("file cannot be restored from parity")
+
+// parityDataShards is the number of parts a data file is split into for
+// computing the parity. Each parity shard has the size of one part, so the
+// parity takes ParityShards/parityDataShards of the size of the file.
+const parityDataShards = 32
+
+// maxParityShards is the largest value for ParityShards, data and parity
+// shards together must not exceed the size of the field.
+const maxParityShards = 256 - parityDataShards
+
+// parityMagic marks the start of a parity sidecar file.
+var parityMagic = []byte("rsp1")
+
+// parityHeader is stored at the start of the parity sidecar, followed by the
+// CRC32C checksums of the data and parity shards, the CRC32C checksum of the
+// preceding bytes and the parity shards.
+type parityHeader struct {
+	Magic        [4]byte
+	DataShards   uint32
+	ParityShards uint32
+	ShardSize    uint32
+	Size         uint64
+}
+
+// parity is the content of a parity sidecar file.
+type parity struct {
+	parityHeader
+	checksums []uint32
+	shards    [][]byte
+}
+
+// parityFile returns the path of the sidecar file holding the parity for
+// the data file h. The sidecars are sharded like the data files.
+func (b *Local) parityFile(h restic.Handle) string {
+	dir := filepath.Join(b.Path, localPaths.Parity)
+	if len(h.Name) > 2 {
+		dir = filepath.Join(dir, h.Name[:2])
+	}
+	return filepath.Join(dir, h.Name+".par")
+}
+
+// splitShards splits buf into k shards of shardSize bytes, the last ones
+// are padded with zeroes.
+func splitShards(buf []byte, k, shardSize int) [][]byte {
+	padded := make([]byte, k*shardSize)
+	copy(padded, buf)
+
+	shards := make([][]byte, k)
+	for i := range shards {
+		shards[i] = padded[i*shardSize : (i+1)*shardSize]
+	}
+	return shards
+}
+
+// writeParity computes the parity for the content of the data file h in fn
+// and saves it in the sidecar file, which is replaced atomically.
+func (b *Local) writeParity(h restic.Handle, fn string) error {
+	buf, err := b.readRaw(fn)
+	if err != nil {
+		return err
+	}
+
+	k, m := parityDataShards, b.ParityShards
+	shardSize := (len(buf) + k - 1) / k
+	data := splitShards(buf, k, shardSize)
+	par := make([][]byte, m)
+	for i := range par {
+		par[i] = make([]byte, shardSize)
+	}
+	rsEncode(data, par)
+
+	hdr := parityHeader{
+		DataShards:   uint32(k),
+		ParityShards: uint32(m),
+		ShardSize:    uint32(shardSize),
+		Size:         uint64(len(buf)),
+	}
+	copy(hdr.Magic[:], parityMagic)
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, hdr)
+	for _, s := range append(data, par...) {
+		binary.Write(&out, binary.LittleEndian, crc32.Checksum(s, castagnoli))
+	}
+	binary.Write(&out, binary.LittleEndian, crc32.Checksum(out.Bytes(), castagnoli))
+	for _, s := range par {
+		out.Write(s)
+	}
+
+	sidecar := b.parityFile(h)
+	if err = b.createDir(filepath.Dir(sidecar)); err != nil {
+		return err
+	}
+
+	tmpfile, _, err := b.copyToTempfile(&out)
+	if err != nil {
+		return err
+	}
+
+	if err = b.FS.Rename(tmpfile, sidecar); err != nil {
+		b.FS.Remove(tmpfile)
+		return errors.Wrap(err, "Rename")
+	}
+
+	debug.Log("saved %d parity shards of %d bytes for %v", m, shardSize, h)
+	return nil
+}
+
+// readRaw returns the content of the file fn as stored on disk.
+func (b *Local) readRaw(fn string) ([]byte, error) {
+	f, err := b.FS.Open(fn)
+	if err != nil {
+		return nil, errors.Wrap(err, "Open")
+	}
+
+	buf, err := ioutil.ReadAll(f)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Read")
+	}
+
+	return buf, nil
+}
+
+// readParity returns the parity stored for h. Parity shards which do not
+// match their checksum are nil.
+func (b *Local) readParity(h restic.Handle) (*parity, error) {
+	buf, err := b.readRaw(b.parityFile(h))
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, errors.Wrapf(ErrNoParity, "%v", h)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rd := bytes.NewReader(buf)
+	p := &parity{}
+	if err = binary.Read(rd, binary.LittleEndian, &p.parityHeader); err != nil || !bytes.Equal(p.Magic[:], parityMagic) ||
+		p.DataShards == 0 || p.DataShards+p.ParityShards > 256 {
+		return nil, errors.Wrapf(ErrUnrecoverable, "invalid parity header for %v", h)
+	}
+
+	p.checksums = make([]uint32, p.DataShards+p.ParityShards)
+	var sum uint32
+	if binary.Read(rd, binary.LittleEndian, p.checksums) != nil || binary.Read(rd, binary.LittleEndian, &sum) != nil {
+		return nil, errors.Wrapf(ErrUnrecoverable, "parity for %v is truncated", h)
+	}
+	if n := len(buf) - rd.Len() - 4; crc32.Checksum(buf[:n], castagnoli) != sum {
+		return nil, errors.Wrapf(ErrUnrecoverable, "parity checksums for %v are damaged", h)
+	}
+
+	p.shards = make([][]byte, p.ParityShards)
+	for i := range p.shards {
+		s := make([]byte, p.ShardSize)
+		if _, err := io.ReadFull(rd, s); err != nil {
+			continue
+		}
+		if crc32.Checksum(s, castagnoli) == p.checksums[int(p.DataShards)+i] {
+			p.shards[i] = s
+		}
+	}
+
+	return p, nil
+}
+
+// removeParity removes the parity sidecar for h, if any.
+func (b *Local) removeParity(h restic.Handle) {
+	if err := b.FS.Remove(b.parityFile(h)); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		debug.Log("unable to remove parity for %v: %v", h, err)
+	}
+}
+
+// Repair restores the data file h from its parity if its content does not
+// match its name. The file is split into parityDataShards parts, and the
+// parts which do not match the checksums recorded by Save are rebuilt from
+// the parity. This succeeds as long as no more than ParityShards parts of
+// the file and its parity are damaged together, regardless of how many bytes
+// within a part are affected, otherwise ErrUnrecoverable is returned. A
+// file which is intact is left alone. If no parity was saved, ErrNoParity is
+// returned.
+func (b *Local) Repair(h restic.Handle) error {
+	debug.Log("Repair %v", h)
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	if h.Type != restic.DataFile {
+		return errors.Errorf("%v is not a data file", h)
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return err
+	}
+
+	fn, _, err := b.locate(h)
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	if id, err := b.hashFile(fn); err == nil && id == h.Name {
+		debug.Log("%v is intact", h)
+		return nil
+	}
+
+	p, err := b.readParity(h)
+	if err != nil {
+		return err
+	}
+
+	buf, err := b.readRaw(fn)
+	if err != nil {
+		return err
+	}
+
+	k, shardSize := int(p.DataShards), int(p.ShardSize)
+	shards := append(splitShards(buf, k, shardSize), p.shards...)
+	damaged := 0
+	for i := 0; i < k; i++ {
+		if crc32.Checksum(shards[i], castagnoli) != p.checksums[i] {
+			shards[i] = nil
+		}
+	}
+	for _, s := range shards {
+		if s == nil {
+			damaged++
+		}
+	}
+
+	debug.Log("%v: %d of %d shards damaged", h, damaged, len(shards))
+	if damaged > int(p.ParityShards) {
+		return errors.Wrapf(ErrUnrecoverable, "%v has %d damaged parts, parity restores %d", h, damaged, p.ParityShards)
+	}
+
+	if err = rsReconstruct(shards, k, shardSize); err != nil {
+		return errors.Wrapf(ErrUnrecoverable, "%v: %v", h, err)
+	}
+
+	var restored []byte
+	for _, s := range shards[:k] {
+		restored = append(restored, s...)
+	}
+	restored = restored[:p.Size]
+
+	if b.Footer && len(restored) >= FooterSize {
+		if _, length, ok := DecodeFooter(restored[len(restored)-FooterSize:], int64(len(restored))); ok {
+			restored = restored[:length]
+		}
+	}
+
+	return b.RepairFrom(h, bytes.NewReader(restored), h.Name)
+}
@@ -0,0 +1,147 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"syscall"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// corruptAt flips the bytes at the given offsets of the file fn.
+func corruptAt(t testing.TB, fn string, offsets ...int) {
+	OK(t, os.Chmod(fn, 0600))
+	buf, err := ioutil.ReadFile(fn)
+	OK(t, err)
+	for _, off := range offsets {
+		buf[off] ^= 0xff
+	}
+	OK(t, ioutil.WriteFile(fn, buf, 0600))
+}
+
+func TestParityRepair(t *testing.T) {
+	for _, footer := range []bool{false, true} {
+		be, cleanup := TestBackend(t)
+		be.ParityShards = 2
+		be.Footer = footer
+
+		// 1024 bytes are split into parts of 32 bytes, or 33 with a footer
+		h, data := saveData(t, be, 23, 1024)
+		fn := filename(be.Path, h.Type, h.Name)
+		_, err := os.Stat(be.parityFile(h))
+		OK(t, err)
+
+		// intact files are left alone
+		OK(t, be.Repair(h))
+
+		// two damaged parts can be restored, regardless of the number of
+		// bytes affected within each part
+		corruptAt(t, fn, 0, 200, 201, 202, 210)
+		Assert(t, be.Verify(h) != nil, "corruption not detected")
+		OK(t, be.Repair(h))
+		OK(t, be.Verify(h))
+		Equals(t, data, load(t, be, h, 0, 0))
+
+		// the parity was written again for the restored file, a third
+		// damaged part is too many
+		corruptAt(t, fn, 0, 200, 500)
+		err = be.Repair(h)
+		Assert(t, errors.Cause(err) == ErrUnrecoverable, "expected ErrUnrecoverable, got %v", err)
+
+		OK(t, be.Remove(h))
+		_, err = os.Stat(be.parityFile(h))
+		Assert(t, os.IsNotExist(err), "parity not removed with the file")
+
+		cleanup()
+	}
+}
+
+func TestParityMissing(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h, _ := saveData(t, be, 23, 1000)
+	corruptAt(t, filename(be.Path, h.Type, h.Name), 10)
+
+	err := be.Repair(h)
+	Assert(t, errors.Cause(err) == ErrNoParity, "expected ErrNoParity, got %v", err)
+
+	lock := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	OK(t, be.Save(lock, bytes.NewReader([]byte("lock"))))
+	Assert(t, be.Repair(lock) != nil, "Repair accepted a lock file")
+}
+
+func TestReedSolomon(t *testing.T) {
+	const k, m, size = 8, 3, 50
+
+	data := make([][]byte, k)
+	for i := range data {
+		data[i] = Random(i, size)
+	}
+	par := make([][]byte, m)
+	for i := range par {
+		par[i] = make([]byte, size)
+	}
+	rsEncode(data, par)
+
+	// every combination of m missing shards is restored
+	n := k + m
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			for c := b + 1; c < n; c++ {
+				shards := append(append([][]byte{}, data...), par...)
+				shards[a], shards[b], shards[c] = nil, nil, nil
+				OK(t, rsReconstruct(shards, k, size))
+				for i := range data {
+					Equals(t, data[i], shards[i])
+				}
+			}
+		}
+	}
+}
+
+func TestParitySidecarErrors(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.ParityShards = 2
+	be.CRC32C = true
+
+	// the sidecars are sharded like the data files
+	h, _ := saveData(t, be, 23, 1024)
+	Equals(t, filepath.Join(be.Path, "parity", h.Name[:2], h.Name+".par"), be.parityFile(h))
+	_, err := os.Stat(be.parityFile(h))
+	OK(t, err)
+
+	// a failed sidecar leaves no file behind, and Save can be retried
+	data := Random(5, 1024)
+	h2 := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	fsys := be.FS
+	be.FS = &fakeFS{FS: fsys, fail: func(op, name string) error {
+		if op == "MkdirAll" && strings.Contains(name, "crc32c") {
+			return syscall.EIO
+		}
+		return nil
+	}}
+	Assert(t, be.Save(h2, bytes.NewReader(data)) != nil, "Save succeeded")
+	ok, err := be.Test(h2)
+	OK(t, err)
+	Assert(t, !ok, "file stored although Save failed")
+	_, err = os.Stat(be.parityFile(h2))
+	Assert(t, os.IsNotExist(err), "parity left behind")
+
+	be.FS = fsys
+	OK(t, be.Save(h2, bytes.NewReader(data)))
+	OK(t, be.Scrub(h2))
+
+	// ParityShards is checked by Open
+	cfg := be.Config
+	cfg.ParityShards = maxParityShards + 1
+	_, err = Open(cfg)
+	Assert(t, err != nil, "Open accepted ParityShards %d", cfg.ParityShards)
+}
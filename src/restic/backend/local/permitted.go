@@ -0,0 +1,27 @@
+package local
+
+import (
+	"restic"
+
+	"restic/errors"
+)
+
+// ErrTypeNotPermitted is returned when the backend is asked to access a
+// file type which is not in PermittedTypes.
+var ErrTypeNotPermitted = errors.New("file type is not permitted")
+
+// checkPermitted returns ErrTypeNotPermitted if files of type t must not be
+// accessed.
+func (b *Local) checkPermitted(t restic.FileType) error {
+	if len(b.PermittedTypes) == 0 {
+		return nil
+	}
+
+	for _, permitted := range b.PermittedTypes {
+		if t == permitted {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(ErrTypeNotPermitted, "type %v", t)
+}
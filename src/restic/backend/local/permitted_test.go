@@ -0,0 +1,87 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestPermittedTypes(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data, dataContent := saveData(t, be, 23, 100)
+	key := restic.Handle{Type: restic.KeyFile, Name: "key"}
+	OK(t, be.Save(key, bytes.NewReader([]byte("key"))))
+
+	be.PermittedTypes = []restic.FileType{restic.DataFile, restic.IndexFile}
+
+	ops := make(map[string]int)
+	fsys := be.FS
+	be.FS = countOps(fsys, ops)
+
+	isNotPermitted := func(err error) {
+		Assert(t, errors.Cause(err) == ErrTypeNotPermitted, "expected ErrTypeNotPermitted, got %v", err)
+	}
+
+	isNotPermitted(be.Save(restic.Handle{Type: restic.KeyFile, Name: "other"}, bytes.NewReader([]byte("x"))))
+	_, err := be.Load(key, 0, 0)
+	isNotPermitted(err)
+	_, err = be.Stat(key)
+	isNotPermitted(err)
+	_, err = be.Test(key)
+	isNotPermitted(err)
+	isNotPermitted(be.Remove(key))
+	for name := range be.List(restic.KeyFile, nil) {
+		t.Errorf("key %v listed", name)
+	}
+	for name := range be.ListFrom(restic.KeyFile, "", nil) {
+		t.Errorf("key %v listed", name)
+	}
+	_, err = be.LoadStrict(key, 0, 0)
+	isNotPermitted(err)
+	_, err = be.LoadCached(key, 0, 0)
+	isNotPermitted(err)
+	_, _, _, err = be.LoadIfChanged(key, time.Time{})
+	isNotPermitted(err)
+	_, err = be.TestMany([]restic.Handle{data, key})
+	isNotPermitted(err)
+	_, _, err = be.ListPage(restic.KeyFile, "", 10)
+	isNotPermitted(err)
+	_, err = be.SaveComputed(restic.SnapshotFile, bytes.NewReader([]byte("x")))
+	isNotPermitted(err)
+	isNotPermitted(be.DeleteTypes([]restic.FileType{restic.KeyFile}, false))
+	isNotPermitted(be.SetMeta(key, nil))
+	_, err = be.GetMeta(key)
+	isNotPermitted(err)
+	isNotPermitted(be.Scrub(key))
+	isNotPermitted(be.Swap(key, restic.Handle{Type: restic.KeyFile, Name: "other"}))
+	_, err = be.SaveWriter(key)
+	isNotPermitted(err)
+	Equals(t, 0, len(ops))
+	be.FS = fsys
+
+	// permitted types are accessed as usual
+	Equals(t, dataContent, load(t, be, data, 0, 0))
+	fi, err := be.Stat(data)
+	OK(t, err)
+	Equals(t, int64(len(dataContent)), fi.Size)
+	ok, err := be.Test(data)
+	OK(t, err)
+	Assert(t, ok, "data file not found")
+	var names []string
+	for name := range be.List(restic.DataFile, nil) {
+		names = append(names, name)
+	}
+	Equals(t, []string{data.Name}, names)
+	OK(t, be.Remove(data))
+	saveData(t, be, 5, 100)
+
+	// an empty list permits all types
+	be.PermittedTypes = nil
+	Equals(t, []byte("key"), load(t, be, key, 0, 0))
+}
@@ -0,0 +1,144 @@
+package local
+
+import (
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// poolFile returns the path of the pool entry for h. Entries are keyed only
+// by the name, which is the hash of the content.
+func (b *Local) poolFile(h restic.Handle) string {
+	return filepath.Join(b.Path, localPaths.Pool, h.Name[:2], h.Name)
+}
+
+// linkFromPool creates target as a hard link to the pool entry for h. It
+// returns false if there is no such entry or the link cannot be created, in
+// which case the data must be written.
+func (b *Local) linkFromPool(h restic.Handle, target string) bool {
+	src := b.poolFile(h)
+	if _, err := b.FS.Lstat(src); err != nil {
+		return false
+	}
+
+	if err := b.FS.MkdirAll(filepath.Dir(target), backend.Modes.Dir); err != nil {
+		debug.Log("unable to create dir for %v: %v", h, err)
+		return false
+	}
+
+	if err := b.FS.Link(src, target); err != nil {
+		debug.Log("unable to link %v from pool: %v", h, err)
+		return false
+	}
+
+	debug.Log("linked %v from pool", h)
+	return true
+}
+
+// addToPool records the file fn stored for h in the pool. Errors are
+// ignored, a missing pool entry only means that the data has to be written
+// again should it be needed later.
+func (b *Local) addToPool(h restic.Handle, fn string) {
+	dst := b.poolFile(h)
+	if err := b.FS.MkdirAll(filepath.Dir(dst), backend.Modes.Dir); err != nil {
+		debug.Log("unable to create pool dir for %v: %v", h, err)
+		return
+	}
+
+	if err := b.FS.Link(fn, dst); err != nil && !os.IsExist(errors.Cause(err)) {
+		debug.Log("unable to add %v to pool: %v", h, err)
+	}
+}
+
+// SaveComputed stores the data read from rd as a content-addressed file of
+// type t, the name is computed from the content with the configured hash
+// function. The handle of the new file is returned.
+func (b *Local) SaveComputed(t restic.FileType, rd io.Reader) (restic.Handle, error) {
+	debug.Log("SaveComputed %v", t)
+	if err := b.checkRecoverMode(); err != nil {
+		return restic.Handle{}, err
+	}
+
+	if !isContentAddressed(t) {
+		return restic.Handle{}, errors.Errorf("files of type %v are not content-addressed", t)
+	}
+
+	if err := b.checkPermitted(t); err != nil {
+		return restic.Handle{}, err
+	}
+
+	hash := b.newHash()
+	rd = io.TeeReader(rd, hash)
+
+	opts := b.record(saveOptions{})
+	rd = opts.tee(rd)
+
+	tmpfile, size, err := b.copyToTempfile(rd)
+	if err != nil {
+		return restic.Handle{}, err
+	}
+
+	h := restic.Handle{Type: t, Name: hex.EncodeToString(hash.Sum(nil))}
+
+	// the name matches the content by construction, so no hash is passed
+	if err = b.commit(h, tmpfile, size, nil, opts); err != nil {
+		return restic.Handle{}, err
+	}
+
+	return h, nil
+}
+
+// PrunePool removes all pool entries which are not linked from the
+// repository anymore and returns the number of entries removed.
+func (b *Local) PrunePool() (removed int, err error) {
+	debug.Log("PrunePool")
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	dir := filepath.Join(b.Path, localPaths.Pool)
+	shards, err := readdirnames(b.FS, dir)
+	if os.IsNotExist(errors.Cause(err)) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, shard := range shards {
+		names, err := readdirnames(b.FS, filepath.Join(dir, shard))
+		if err != nil {
+			return removed, err
+		}
+
+		for _, name := range names {
+			fn := filepath.Join(dir, shard, name)
+			fi, err := b.FS.Lstat(fn)
+			if err != nil {
+				return removed, errors.Wrap(err, "Lstat")
+			}
+
+			n, ok := linkCount(fi)
+			if !ok {
+				return removed, errors.New("link count not available on this platform")
+			}
+
+			if n > 1 {
+				continue
+			}
+
+			if err = b.FS.Remove(fn); err != nil {
+				return removed, errors.Wrap(err, "Remove")
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
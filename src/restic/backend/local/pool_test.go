@@ -0,0 +1,124 @@
+package local
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"restic"
+	"runtime"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// failReader returns an error on the first read.
+type failReader struct{}
+
+func (failReader) Read([]byte) (int, error) {
+	return 0, errors.New("data was read")
+}
+
+func TestPool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("link count is not available on windows")
+	}
+
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.Pool = true
+
+	data := Random(23, 1000)
+	h, err := be.SaveComputed(restic.DataFile, bytes.NewReader(data))
+	OK(t, err)
+	Equals(t, restic.Hash(data).String(), h.Name)
+
+	_, err = os.Stat(be.poolFile(h))
+	OK(t, err)
+
+	// the pool entry is in use
+	removed, err := be.PrunePool()
+	OK(t, err)
+	Equals(t, 0, removed)
+
+	// after removing the file, saving it again links it from the pool
+	OK(t, be.Remove(h))
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	fi, err := os.Stat(filename(be.Path, h.Type, h.Name))
+	OK(t, err)
+	pfi, err := os.Stat(be.poolFile(h))
+	OK(t, err)
+	Assert(t, os.SameFile(fi, pfi), "%v was not linked from the pool", h)
+
+	rd, err := be.Load(h, 0, 0)
+	OK(t, err)
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	Assert(t, bytes.Equal(buf, data), "wrong data returned")
+
+	// files without a pool entry are written as usual, SaveComputed also
+	// uses the pool
+	other := Random(5, 500)
+	h2 := restic.Handle{Type: restic.DataFile, Name: restic.Hash(other).String()}
+	OK(t, be.Save(h2, bytes.NewReader(other)))
+	OK(t, be.Remove(h2))
+	h3, err := be.SaveComputed(restic.DataFile, bytes.NewReader(other))
+	OK(t, err)
+	Equals(t, h2, h3)
+
+	// entries for removed files are pruned
+	OK(t, be.Remove(h))
+	removed, err = be.PrunePool()
+	OK(t, err)
+	Equals(t, 1, removed)
+
+	_, err = os.Stat(be.poolFile(h))
+	Assert(t, os.IsNotExist(err), "pool entry for %v not removed", h)
+	_, err = os.Stat(be.poolFile(h2))
+	OK(t, err)
+}
+
+func TestPoolChecks(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.Pool = true
+
+	h, data := saveData(t, be, 23, 1000)
+	empty := restic.Handle{Type: restic.DataFile, Name: restic.Hash(nil).String()}
+	OK(t, be.Save(empty, bytes.NewReader(nil)))
+	for _, h := range []restic.Handle{h, empty} {
+		OK(t, be.Remove(h))
+	}
+
+	// the pool entries exist, but the data is still checked
+	be.SaveHook = func(restic.Handle, io.Reader) (io.Reader, error) {
+		return nil, errors.New("rejected")
+	}
+	Assert(t, be.Save(h, bytes.NewReader(data)) != nil, "SaveHook was not called")
+	be.SaveHook = nil
+
+	Assert(t, be.Save(h, failReader{}) != nil, "data was not read")
+
+	err := be.SaveStream(h, bytes.NewReader(data), 100)
+	Assert(t, errors.Cause(err) == ErrTooLarge, "expected ErrTooLarge, got %v", err)
+
+	be.RejectEmpty = true
+	err = be.Save(empty, bytes.NewReader(nil))
+	Assert(t, errors.Cause(err) == ErrEmptyBlob, "expected ErrEmptyBlob, got %v", err)
+
+	for _, h := range []restic.Handle{h, empty} {
+		ok, err := be.Test(h)
+		OK(t, err)
+		Assert(t, !ok, "%v was saved", h)
+	}
+}
+
+func TestSaveComputedMutable(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	_, err := be.SaveComputed(restic.LockFile, bytes.NewReader([]byte("foo")))
+	Assert(t, err != nil, "SaveComputed accepted a lock file")
+}
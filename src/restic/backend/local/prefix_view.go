@@ -0,0 +1,88 @@
+package local
+
+import (
+	"io"
+	"os"
+	"restic"
+	"strings"
+
+	"restic/errors"
+)
+
+// ErrReadOnlyView is returned when a view returned by PrefixView or a
+// backend returned by OpenFS is asked to modify the repository.
+var ErrReadOnlyView = errors.New("view is read-only")
+
+// prefixView is a read-only view of a Local backend, returned by PrefixView.
+type prefixView struct {
+	be     *Local
+	prefix string
+}
+
+// PrefixView returns a read-only backend which only contains the
+// content-addressed files whose names start with prefix, all other files
+// appear to be missing. Splitting the hash space by prefix allows several
+// workers to process a repository in parallel. Closing the view does not
+// close b.
+func (b *Local) PrefixView(prefix string) restic.Backend {
+	return &prefixView{be: b, prefix: prefix}
+}
+
+func (v *prefixView) visible(h restic.Handle) bool {
+	return isContentAddressed(h.Type) && strings.HasPrefix(h.Name, v.prefix)
+}
+
+// notFound returns the error for a file outside of the view.
+func (v *prefixView) notFound(h restic.Handle) error {
+	return errors.Wrapf(&os.PathError{Op: "Open", Path: v.be.filename(h.Type, h.Name), Err: os.ErrNotExist},
+		"%v not in view", h)
+}
+
+func (v *prefixView) Location() string {
+	return v.be.Location()
+}
+
+func (v *prefixView) Test(h restic.Handle) (bool, error) {
+	if !v.visible(h) {
+		return false, nil
+	}
+	return v.be.Test(h)
+}
+
+func (v *prefixView) Remove(h restic.Handle) error {
+	return errors.Wrapf(ErrReadOnlyView, "Remove %v", h)
+}
+
+func (v *prefixView) Close() error {
+	return nil
+}
+
+func (v *prefixView) Save(h restic.Handle, rd io.Reader) error {
+	return errors.Wrapf(ErrReadOnlyView, "Save %v", h)
+}
+
+func (v *prefixView) Load(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	if !v.visible(h) {
+		return nil, v.notFound(h)
+	}
+	return v.be.Load(h, length, offset)
+}
+
+func (v *prefixView) Stat(h restic.Handle) (restic.FileInfo, error) {
+	if !v.visible(h) {
+		return restic.FileInfo{}, v.notFound(h)
+	}
+	return v.be.Stat(h)
+}
+
+func (v *prefixView) List(t restic.FileType, done <-chan struct{}) <-chan string {
+	if !isContentAddressed(t) {
+		ch := make(chan string)
+		close(ch)
+		return ch
+	}
+
+	return v.be.ListFilter(t, func(name string) bool {
+		return strings.HasPrefix(name, v.prefix)
+	}, done)
+}
@@ -0,0 +1,77 @@
+package local_test
+
+import (
+	"os"
+	"restic"
+	"sort"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestPrefixView(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	handles := saveRandom(t, be, restic.DataFile, 40)
+	handles = append(handles, saveRandom(t, be, restic.SnapshotFile, 10)...)
+	OK(t, be.Save(restic.Handle{Type: restic.LockFile, Name: "lock"}, strings.NewReader("lock")))
+
+	prefix := handles[0].Name[:1]
+	view := be.PrefixView(prefix)
+
+	var want []string
+	for _, h := range handles {
+		ok, err := view.Test(h)
+		OK(t, err)
+		_, statErr := view.Stat(h)
+
+		if !strings.HasPrefix(h.Name, prefix) {
+			Assert(t, !ok, "%v visible in view", h)
+			Assert(t, os.IsNotExist(errors.Cause(statErr)), "expected not-exist error for %v, got %v", h, statErr)
+			_, err = view.Load(h, 0, 0)
+			Assert(t, err != nil, "%v could be loaded from view", h)
+			continue
+		}
+
+		Assert(t, ok, "%v not visible in view", h)
+		OK(t, statErr)
+
+		rd, err := view.Load(h, 0, 0)
+		OK(t, err)
+		OK(t, rd.Close())
+
+		if h.Type == restic.DataFile {
+			want = append(want, h.Name)
+		}
+	}
+	sort.Strings(want)
+
+	var names []string
+	for name := range view.List(restic.DataFile, nil) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	Equals(t, want, names)
+
+	for range view.List(restic.LockFile, nil) {
+		t.Errorf("lock file listed in view")
+	}
+
+	ok, err := view.Test(restic.Handle{Type: restic.LockFile, Name: "lock"})
+	OK(t, err)
+	Assert(t, !ok, "lock file visible in view")
+
+	err = view.Save(handles[0], strings.NewReader("foo"))
+	Assert(t, errors.Cause(err) == local.ErrReadOnlyView, "expected ErrReadOnlyView, got %v", err)
+	err = view.Remove(handles[0])
+	Assert(t, errors.Cause(err) == local.ErrReadOnlyView, "expected ErrReadOnlyView, got %v", err)
+
+	OK(t, view.Close())
+	ok, err = be.Test(handles[0])
+	OK(t, err)
+	Assert(t, ok, "file removed through view")
+}
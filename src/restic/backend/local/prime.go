@@ -0,0 +1,90 @@
+package local
+
+import (
+	"path/filepath"
+	"restic"
+	"sync"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// primeWorkers is the number of data subdirectories read in parallel by
+// Prime.
+const primeWorkers = 16
+
+// Prime reads the directories for the given types, so that the metadata of
+// the files is in the operating system's cache for subsequent calls to List,
+// Stat and Test. For data, all subdirectories are read in parallel. This does
+// not change the result of any operation, but on a slow file system (e.g. a
+// cold NFS mount) it moves the latency of fetching the metadata into a single
+// step, instead of spreading it over many calls. For each directory read, a
+// Stat with Dirs set to one is reported to p, which may be nil. If done is
+// closed, Prime stops early and returns an error.
+func (b *Local) Prime(types []restic.FileType, p *restic.Progress, done <-chan struct{}) error {
+	debug.Log("Prime %v", types)
+
+	p.Start()
+	defer p.Done()
+
+	for _, t := range types {
+		if t == restic.ConfigFile {
+			continue
+		}
+
+		if err := b.checkPermitted(t); err != nil {
+			return err
+		}
+
+		dir := dirname(b.Path, t, "")
+		fileInfos, err := readdir(b.FS, dir)
+		if err != nil {
+			return err
+		}
+		p.Report(restic.Stat{Dirs: 1})
+
+		if t != restic.DataFile {
+			continue
+		}
+
+		dirs := make(chan string)
+		go func() {
+			defer close(dirs)
+			for _, fi := range fileInfos {
+				if !fi.IsDir() {
+					continue
+				}
+
+				select {
+				case dirs <- filepath.Join(dir, fi.Name()):
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < primeWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for d := range dirs {
+					if _, err := readdir(b.FS, d); err != nil {
+						debug.Log("unable to read %v: %v", d, err)
+						continue
+					}
+					p.Report(restic.Stat{Dirs: 1})
+				}
+			}()
+		}
+		wg.Wait()
+
+		select {
+		case <-done:
+			return errors.New("Prime canceled")
+		default:
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"sync"
+	"testing"
+	"time"
+
+	. "restic/test"
+)
+
+func TestPrime(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	shards := make(map[string]struct{})
+	for i := 0; i < 20; i++ {
+		data := Random(i, 100)
+		id := restic.Hash(data)
+		OK(t, be.Save(restic.Handle{Type: restic.DataFile, Name: id.String()}, bytes.NewReader(data)))
+		shards[id.String()[:2]] = struct{}{}
+	}
+
+	var m sync.Mutex
+	opened := make(map[string]int)
+	be.FS = &fakeFS{FS: be.FS, fail: func(op, name string) error {
+		if op == "Open" {
+			m.Lock()
+			opened[name]++
+			m.Unlock()
+		}
+		return nil
+	}}
+
+	var stat restic.Stat
+	p := restic.NewProgress()
+	p.OnUpdate = func(s restic.Stat, d time.Duration, ticker bool) {}
+	p.OnDone = func(s restic.Stat, d time.Duration, ticker bool) {
+		stat = s
+	}
+
+	OK(t, be.Prime([]restic.FileType{restic.DataFile, restic.SnapshotFile, restic.ConfigFile}, p, nil))
+
+	Equals(t, uint64(len(shards)+2), stat.Dirs)
+	Equals(t, len(shards)+2, len(opened))
+	Equals(t, 1, opened[dirname(be.Path, restic.SnapshotFile, "")])
+
+	done := make(chan struct{})
+	close(done)
+	Assert(t, be.Prime([]restic.FileType{restic.DataFile}, nil, done) != nil,
+		"canceled Prime did not return an error")
+}
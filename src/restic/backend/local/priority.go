@@ -0,0 +1,141 @@
+package local
+
+import (
+	"container/heap"
+	"io"
+	"restic"
+	"sync"
+)
+
+// Priority is the priority of operations run through a PriorityBackend.
+type Priority int
+
+// These are predefined priorities, any other value can be used as well.
+const (
+	PriorityLow    Priority = -10
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 10
+)
+
+// waiter is an operation waiting for a slot.
+type waiter struct {
+	prio  Priority
+	seq   uint64
+	ready chan struct{}
+}
+
+// waiters is a heap of waiting operations, ordered by priority and then by
+// arrival.
+type waiters []*waiter
+
+func (w waiters) Len() int { return len(w) }
+func (w waiters) Less(i, j int) bool {
+	if w[i].prio != w[j].prio {
+		return w[i].prio > w[j].prio
+	}
+	return w[i].seq < w[j].seq
+}
+func (w waiters) Swap(i, j int)       { w[i], w[j] = w[j], w[i] }
+func (w *waiters) Push(x interface{}) { *w = append(*w, x.(*waiter)) }
+func (w *waiters) Pop() interface{} {
+	old := *w
+	x := old[len(old)-1]
+	*w = old[:len(old)-1]
+	return x
+}
+
+// scheduler hands out a fixed number of slots. When all slots are in use,
+// a released slot is given to the waiting operation with the highest
+// priority, operations with the same priority are served in order.
+type scheduler struct {
+	m       sync.Mutex
+	free    int
+	seq     uint64
+	waiting waiters
+}
+
+func (s *scheduler) acquire(prio Priority) {
+	s.m.Lock()
+	if s.free > 0 && len(s.waiting) == 0 {
+		s.free--
+		s.m.Unlock()
+		return
+	}
+
+	w := &waiter{prio: prio, seq: s.seq, ready: make(chan struct{})}
+	s.seq++
+	heap.Push(&s.waiting, w)
+	s.m.Unlock()
+
+	<-w.ready
+}
+
+func (s *scheduler) release() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if len(s.waiting) == 0 {
+		s.free++
+		return
+	}
+
+	w := heap.Pop(&s.waiting).(*waiter)
+	close(w.ready)
+}
+
+// PriorityBackend limits the number of concurrent Load and Save calls to the
+// wrapped backend. When the limit is reached, waiting calls with a higher
+// priority are run first, so that e.g. an interactive restore stays
+// responsive while a prune runs in the background. Calls with the same
+// priority are run in order. Other methods are passed through. A reader
+// returned by Load holds its slot until it is closed.
+type PriorityBackend struct {
+	restic.Backend
+	s    *scheduler
+	prio Priority
+}
+
+// NewPriorityBackend returns a PriorityBackend which runs at most slots Load
+// and Save calls concurrently with PriorityNormal.
+func NewPriorityBackend(be restic.Backend, slots int) *PriorityBackend {
+	return &PriorityBackend{Backend: be, s: &scheduler{free: slots}}
+}
+
+// WithPriority returns a backend which shares the slots with b, but runs the
+// operations with priority prio.
+func (b *PriorityBackend) WithPriority(prio Priority) *PriorityBackend {
+	return &PriorityBackend{Backend: b.Backend, s: b.s, prio: prio}
+}
+
+// Save stores the data in the backend under the given handle.
+func (b *PriorityBackend) Save(h restic.Handle, rd io.Reader) error {
+	b.s.acquire(b.prio)
+	defer b.s.release()
+	return b.Backend.Save(h, rd)
+}
+
+// priorityReader releases the slot when it is closed.
+type priorityReader struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (rd *priorityReader) Close() error {
+	err := rd.ReadCloser.Close()
+	rd.once.Do(rd.release)
+	return err
+}
+
+// Load returns a reader that yields the contents of the file at h at the
+// given offset. The slot is held until the reader is closed.
+func (b *PriorityBackend) Load(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	b.s.acquire(b.prio)
+	rd, err := b.Backend.Load(h, length, offset)
+	if err != nil {
+		b.s.release()
+		return nil, err
+	}
+
+	return &priorityReader{ReadCloser: rd, release: b.s.release}, nil
+}
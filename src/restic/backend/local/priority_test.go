@@ -0,0 +1,90 @@
+package local
+
+import (
+	"bytes"
+	"io"
+	"restic"
+	"sync"
+	"testing"
+	"time"
+
+	. "restic/test"
+)
+
+// orderReader appends its name to order on the first read.
+type orderReader struct {
+	io.Reader
+	name  string
+	once  sync.Once
+	m     *sync.Mutex
+	order *[]string
+}
+
+func (rd *orderReader) Read(p []byte) (int, error) {
+	rd.once.Do(func() {
+		rd.m.Lock()
+		*rd.order = append(*rd.order, rd.name)
+		rd.m.Unlock()
+	})
+	return rd.Reader.Read(p)
+}
+
+// waitQueued waits until n operations are waiting for a slot.
+func waitQueued(t testing.TB, s *scheduler, n int) {
+	for i := 0; i < 1000; i++ {
+		s.m.Lock()
+		queued := len(s.waiting)
+		s.m.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d operations not queued", n)
+}
+
+func TestPriorityBackend(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	pb := NewPriorityBackend(be, 1)
+	low := pb.WithPriority(PriorityLow)
+	high := pb.WithPriority(PriorityHigh)
+
+	// occupy the only slot
+	data := Random(23, 100)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, pb.Save(h, bytes.NewReader(data)))
+	rd, err := pb.Load(h, 0, 0)
+	OK(t, err)
+
+	var m sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+
+	save := func(b *PriorityBackend, name string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rd := &orderReader{Reader: bytes.NewReader([]byte(name)), name: name, m: &m, order: &order}
+			OK(t, b.Save(restic.Handle{Type: restic.LockFile, Name: name}, rd))
+		}()
+	}
+
+	save(low, "low1")
+	waitQueued(t, pb.s, 1)
+	save(low, "low2")
+	waitQueued(t, pb.s, 2)
+	save(high, "high1")
+	waitQueued(t, pb.s, 3)
+	save(high, "high2")
+	waitQueued(t, pb.s, 4)
+	save(pb, "normal")
+	waitQueued(t, pb.s, 5)
+
+	OK(t, rd.Close())
+	wg.Wait()
+
+	Equals(t, []string{"high1", "high2", "normal", "low1", "low2"}, order)
+	Equals(t, 1, pb.s.free)
+}
@@ -0,0 +1,68 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+	"restic/fs"
+)
+
+// ProbeConfig checks whether dir looks like a local repository without
+// opening it. The config file is read, and for each of the expected
+// subdirectories it is checked whether it exists. Nothing is modified. An
+// error is only returned if dir itself cannot be accessed or the config file
+// exists but cannot be read, missing parts of the repository are reported in
+// the returned RepoInfo.
+func ProbeConfig(dir string) (restic.RepoInfo, error) {
+	debug.Log("ProbeConfig %v", dir)
+	var info restic.RepoInfo
+
+	fi, err := fs.Stat(dir)
+	if err != nil {
+		return info, errors.Wrap(err, "Stat")
+	}
+
+	if !fi.IsDir() {
+		return info, errors.Errorf("%v is not a directory", dir)
+	}
+
+	for _, d := range paths(dir)[1:] {
+		fi, err := fs.Stat(d)
+		if err != nil || !fi.IsDir() {
+			rel, _ := filepath.Rel(dir, d)
+			info.MissingDirs = append(info.MissingDirs, rel)
+		}
+	}
+
+	cfgfile := filepath.Join(dir, backend.Paths.Config)
+	fi, err = fs.Stat(cfgfile)
+	if os.IsNotExist(errors.Cause(err)) {
+		return info, nil
+	}
+	if err != nil {
+		return info, errors.Wrap(err, "Stat")
+	}
+
+	f, err := fs.Open(cfgfile)
+	if err != nil {
+		return info, errors.Wrap(err, "Open")
+	}
+	defer f.Close()
+
+	buf, err := ioutil.ReadAll(f)
+	if err != nil {
+		return info, errors.Wrap(err, "ReadAll")
+	}
+
+	info.HasConfig = true
+	info.Config = buf
+	info.ConfigSize = fi.Size()
+	info.ConfigModTime = fi.ModTime()
+
+	return info, nil
+}
@@ -0,0 +1,53 @@
+package local_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestProbeConfig(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	info, err := local.ProbeConfig(be.Location())
+	OK(t, err)
+	Assert(t, !info.HasConfig, "config reported for repository without config")
+	Equals(t, 0, len(info.MissingDirs))
+
+	OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, strings.NewReader("config data")))
+	OK(t, os.Remove(filepath.Join(be.Location(), "locks")))
+	OK(t, os.Remove(filepath.Join(be.Location(), "tmp")))
+
+	info, err = local.ProbeConfig(be.Location())
+	OK(t, err)
+	Assert(t, info.HasConfig, "config not found")
+	Equals(t, "config data", string(info.Config))
+	Equals(t, int64(11), info.ConfigSize)
+	Assert(t, !info.ConfigModTime.IsZero(), "config mtime not set")
+	Equals(t, []string{"locks", "tmp"}, info.MissingDirs)
+}
+
+func TestProbeConfigEmptyDir(t *testing.T) {
+	tempdir, err := ioutil.TempDir(TestTempDir, "restic-local-test-")
+	OK(t, err)
+	defer RemoveAll(t, tempdir)
+
+	info, err := local.ProbeConfig(tempdir)
+	OK(t, err)
+	Assert(t, !info.HasConfig, "config reported for empty directory")
+	Equals(t, 6, len(info.MissingDirs))
+
+	files, err := ioutil.ReadDir(tempdir)
+	OK(t, err)
+	Equals(t, 0, len(files))
+
+	_, err = local.ProbeConfig(filepath.Join(tempdir, "missing"))
+	Assert(t, err != nil, "no error for missing directory")
+}
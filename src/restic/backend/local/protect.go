@@ -0,0 +1,68 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrProtected is returned by Delete when the repository is protected.
+var ErrProtected = errors.New("repository is protected against deletion")
+
+// protectedFile is the name of the marker file written by Protect.
+const protectedFile = "protected"
+
+func (b *Local) protectedMarker() string {
+	return filepath.Join(b.Path, protectedFile)
+}
+
+// Protect writes a marker file which makes Delete refuse to remove the
+// repository until Unprotect is called.
+func (b *Local) Protect() error {
+	debug.Log("Protect")
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	tmpfile, _, err := b.copyToTempfile(bytes.NewReader(nil))
+	if err != nil {
+		return err
+	}
+
+	if err = b.FS.Rename(tmpfile, b.protectedMarker()); err != nil {
+		b.FS.Remove(tmpfile)
+		return errors.Wrap(err, "Rename")
+	}
+
+	return nil
+}
+
+// Unprotect removes the marker file written by Protect. It does nothing if
+// the repository is not protected.
+func (b *Local) Unprotect() error {
+	debug.Log("Unprotect")
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	err := b.FS.Remove(b.protectedMarker())
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return errors.Wrap(err, "Remove")
+	}
+	return nil
+}
+
+// Protected returns true if the repository is protected against deletion.
+func (b *Local) Protected() (bool, error) {
+	_, err := b.FS.Lstat(b.protectedMarker())
+	if os.IsNotExist(errors.Cause(err)) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "Lstat")
+	}
+	return true, nil
+}
@@ -0,0 +1,51 @@
+package local_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestProtect(t *testing.T) {
+	dir, err := ioutil.TempDir(TestTempDir, "restic-test-local-")
+	OK(t, err)
+	defer func() {
+		if _, err := os.Stat(dir); err == nil {
+			RemoveAll(t, dir)
+		}
+	}()
+
+	be, err := local.Create(local.Config{Path: dir})
+	OK(t, err)
+
+	protected, err := be.Protected()
+	OK(t, err)
+	Assert(t, !protected, "new repository is protected")
+
+	OK(t, be.Protect())
+	protected, err = be.Protected()
+	OK(t, err)
+	Assert(t, protected, "repository is not protected")
+
+	// the state is found when the repository is opened again
+	be2, err := local.Open(local.Config{Path: be.Location()})
+	OK(t, err)
+	protected, err = be2.Protected()
+	OK(t, err)
+	Assert(t, protected, "reopened repository is not protected")
+
+	err = be.Delete()
+	Assert(t, errors.Cause(err) == local.ErrProtected, "expected ErrProtected, got %v", err)
+	_, err = os.Stat(be.Location())
+	OK(t, err)
+
+	OK(t, be.Unprotect())
+	OK(t, be.Unprotect())
+	OK(t, be.Delete())
+	_, err = os.Stat(be.Location())
+	Assert(t, os.IsNotExist(err), "repository was not deleted")
+}
@@ -0,0 +1,98 @@
+package local
+
+import (
+	"io"
+	"restic"
+	"sync"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// DefaultReadBufferSize is the initial size of the buffers used by
+// LoadBuffer if ReadBufferSize is not set.
+const DefaultReadBufferSize = 1 << 20
+
+// maxPooledReadBuffer is the capacity above which buffers are not put back
+// into the pool, so that a few large files do not pin a lot of memory.
+const maxPooledReadBuffer = 16 << 20
+
+// readBuffers holds the buffers returned by Buffer.Release.
+var readBuffers sync.Pool
+
+// Buffer holds data loaded by LoadBuffer.
+type Buffer struct {
+	// Data is the content read, it is only valid until Release is called.
+	Data []byte
+
+	buf []byte
+}
+
+// Release returns the buffer to the pool, Data must not be used anymore.
+// Calling Release more than once has no effect.
+func (b *Buffer) Release() {
+	if b.buf == nil {
+		return
+	}
+
+	if cap(b.buf) <= maxPooledReadBuffer {
+		readBuffers.Put(b.buf[:0])
+	}
+	b.Data, b.buf = nil, nil
+}
+
+// getReadBuffer returns a buffer with room for n bytes from the pool.
+func getReadBuffer(n, size int) []byte {
+	if size <= 0 {
+		size = DefaultReadBufferSize
+	}
+
+	buf, _ := readBuffers.Get().([]byte)
+	if cap(buf) < n {
+		if n < size {
+			n = size
+		}
+		buf = make([]byte, 0, n)
+	}
+	return buf
+}
+
+// LoadBuffer works like Load, but reads the data into a buffer taken from a
+// pool instead of returning a reader. The buffer must be released after use,
+// it is then reused by later calls. This avoids allocating a new buffer for
+// each file when many files are read, e.g. during restore.
+func (b *Local) LoadBuffer(h restic.Handle, length int, offset int64) (*Buffer, error) {
+	debug.Log("LoadBuffer %v, length %v, offset %v", h, length, offset)
+
+	fi, err := b.Stat(h)
+	if err != nil {
+		return nil, err
+	}
+
+	n := fi.Size - offset
+	if n < 0 {
+		n = 0
+	}
+	if length > 0 && int64(length) < n {
+		n = int64(length)
+	}
+
+	rd, err := b.Load(h, length, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &Buffer{buf: getReadBuffer(int(n), b.ReadBufferSize)}
+	_, err = io.ReadFull(rd, buf.buf[:n])
+	if e := rd.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		buf.Release()
+		return nil, errors.Wrap(err, "ReadFull")
+	}
+
+	buf.Data = buf.buf[:n]
+	return buf, nil
+}
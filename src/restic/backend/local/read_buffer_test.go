@@ -0,0 +1,78 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"restic"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestLoadBuffer(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h, data := saveData(t, be, 23, 5000)
+
+	buf, err := be.LoadBuffer(h, 0, 0)
+	OK(t, err)
+	Equals(t, data, buf.Data)
+	buf.Release()
+	buf.Release()
+
+	buf, err = be.LoadBuffer(h, 100, 200)
+	OK(t, err)
+	Equals(t, data[200:300], buf.Data)
+	buf.Release()
+
+	buf, err = be.LoadBuffer(h, 0, 4000)
+	OK(t, err)
+	Equals(t, data[4000:], buf.Data)
+	buf.Release()
+
+	// buffers grow for files larger than the initial size
+	be.ReadBufferSize = 1000
+	buf, err = be.LoadBuffer(h, 0, 0)
+	OK(t, err)
+	Equals(t, data, buf.Data)
+	buf.Release()
+
+	_, err = be.LoadBuffer(restic.Handle{Type: restic.DataFile, Name: restic.Hash(nil).String()}, 0, 0)
+	Assert(t, os.IsNotExist(errors.Cause(err)), "expected not exist error, got %v", err)
+}
+
+func BenchmarkLoad(b *testing.B) {
+	be, cleanup := TestBackend(b)
+	defer cleanup()
+
+	h, data := saveData(b, be, 23, 512*1024)
+
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			rd, err := be.Load(h, 0, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err = ioutil.ReadAll(rd); err != nil {
+				b.Fatal(err)
+			}
+			rd.Close()
+		}
+	})
+
+	b.Run("LoadBuffer", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			buf, err := be.LoadBuffer(h, 0, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf.Release()
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrReadOnlyFilesystem is returned by Open when the repository is on a
+// file system mounted read-only.
+var ErrReadOnlyFilesystem = errors.New("repository is on a read-only file system")
+
+// isEROFS returns true if err reports a read-only file system.
+func isEROFS(err error) bool {
+	if e, ok := errors.Cause(err).(*os.PathError); ok {
+		return e.Err == syscall.EROFS
+	}
+	return errors.Cause(err) == syscall.EROFS
+}
+
+// checkWritable creates and removes a file in the temp dir below base and
+// returns ErrReadOnlyFilesystem if the file system is mounted read-only.
+// This is common after the kernel remounted a file system because of an
+// error, and would otherwise only be noticed on the first Save. Other errors
+// are ignored here.
+func checkWritable(fsys FS, base string) error {
+	f, err := fsys.TempFile(filepath.Join(base, backend.Paths.Temp), "probe-")
+	if err != nil {
+		if isEROFS(err) {
+			return errors.Wrap(ErrReadOnlyFilesystem, base)
+		}
+		debug.Log("unable to create probe file: %v", err)
+		return nil
+	}
+
+	f.Close()
+	if err = fsys.Remove(f.Name()); err != nil {
+		debug.Log("unable to remove probe file %v: %v", f.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package local
+
+import (
+	"syscall"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestOpenReadOnlyFilesystem(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	fsys := &fakeFS{FS: defaultFS, fail: failOp("TempFile", syscall.EROFS)}
+
+	_, err := open(Config{Path: be.Path}, fsys)
+	Assert(t, errors.Cause(err) == ErrReadOnlyFilesystem,
+		"expected ErrReadOnlyFilesystem, got %v", err)
+
+	// intentional read-only access is allowed
+	be2, err := open(Config{Path: be.Path, ReadOnly: true}, fsys)
+	OK(t, err)
+	OK(t, be2.Close())
+
+	// other errors are not reported at Open
+	fsys.fail = failOp("TempFile", syscall.EACCES)
+	be2, err = open(Config{Path: be.Path}, fsys)
+	OK(t, err)
+	OK(t, be2.Close())
+}
@@ -0,0 +1,33 @@
+package local
+
+import (
+	"restic/errors"
+)
+
+// ErrRecoverMode is returned when a backend opened by OpenRecover is asked
+// to modify the repository.
+var ErrRecoverMode = errors.New("backend is opened in recover mode")
+
+// OpenRecover opens a damaged repository for recovery. In contrast to Open,
+// it succeeds as long as the repository directory exists, regardless of a
+// missing or corrupt config file and missing subdirectories, so that the
+// surviving files can be read. Saving and removing files, and all other
+// methods which modify the repository, return ErrRecoverMode.
+func OpenRecover(cfg Config) (*Local, error) {
+	return openBackend(cfg, defaultFS, true)
+}
+
+// IsRecoverMode returns true if the backend was opened by OpenRecover and
+// the repository may be incomplete.
+func (b *Local) IsRecoverMode() bool {
+	return b.recoverMode
+}
+
+// checkRecoverMode returns ErrRecoverMode if the backend must not modify the
+// repository.
+func (b *Local) checkRecoverMode() error {
+	if b.recoverMode {
+		return errors.Wrap(ErrRecoverMode, b.Path)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package local_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestOpenRecover(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	Assert(t, !be.IsRecoverMode(), "backend is in recover mode")
+
+	OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, bytes.NewReader([]byte("config"))))
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	// lose the config and some directories
+	OK(t, os.Remove(filepath.Join(be.Path, backend.Paths.Config)))
+	OK(t, os.RemoveAll(filepath.Join(be.Path, backend.Paths.Locks)))
+	OK(t, os.RemoveAll(filepath.Join(be.Path, backend.Paths.Keys)))
+
+	_, err := local.Open(be.Config)
+	Assert(t, err != nil, "damaged repository was opened")
+
+	rb, err := local.OpenRecover(be.Config)
+	OK(t, err)
+	Assert(t, rb.IsRecoverMode(), "backend is not in recover mode")
+
+	Equals(t, data, loadAll(t, rb, h))
+	ok, err := rb.Test(restic.Handle{Type: restic.ConfigFile})
+	OK(t, err)
+	Assert(t, !ok, "config found")
+
+	names := 0
+	for range rb.List(restic.DataFile, nil) {
+		names++
+	}
+	Equals(t, 1, names)
+
+	// modifications are refused
+	other := Random(5, 100)
+	err = rb.Save(restic.Handle{Type: restic.DataFile, Name: restic.Hash(other).String()}, bytes.NewReader(other))
+	Assert(t, errors.Cause(err) == local.ErrRecoverMode, "expected ErrRecoverMode, got %v", err)
+	err = rb.Remove(h)
+	Assert(t, errors.Cause(err) == local.ErrRecoverMode, "expected ErrRecoverMode, got %v", err)
+	err = rb.Delete()
+	Assert(t, errors.Cause(err) == local.ErrRecoverMode, "expected ErrRecoverMode, got %v", err)
+	Equals(t, data, loadAll(t, rb, h))
+
+	// the repository itself must exist
+	_, err = local.OpenRecover(local.Config{Path: filepath.Join(be.Path, "missing")})
+	Assert(t, err != nil, "missing repository was opened")
+}
+
+func TestRecoverModeRefusesWrites(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	be.Pool = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	OK(t, be.Remove(h))
+
+	cfg := be.Config
+	rb, err := local.OpenRecover(cfg)
+	OK(t, err)
+
+	isRecoverMode := func(err error) {
+		Assert(t, errors.Cause(err) == local.ErrRecoverMode, "expected ErrRecoverMode, got %v", err)
+	}
+
+	// the content is still in the pool, but must not be linked
+	isRecoverMode(rb.Save(h, bytes.NewReader(data)))
+	ok, err := rb.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "pooled file was saved in recover mode")
+
+	key := restic.Handle{Type: restic.KeyFile, Name: "key"}
+	OK(t, be.Save(key, bytes.NewReader([]byte("key"))))
+
+	isRecoverMode(rb.SetMeta(key, map[string]string{"foo": "bar"}))
+	_, err = rb.PrunePool()
+	isRecoverMode(err)
+	isRecoverMode(rb.Protect())
+	isRecoverMode(rb.Unprotect())
+	_, err = rb.FsckStructure(true)
+	isRecoverMode(err)
+	_, err = rb.Reserve(100)
+	isRecoverMode(err)
+
+	// checking without repairing works
+	_, err = rb.FsckStructure(false)
+	OK(t, err)
+}
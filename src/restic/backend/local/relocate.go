@@ -0,0 +1,171 @@
+package local
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrLocked is returned by Relocate when the repository has lock files.
+var ErrLocked = errors.New("repository is locked")
+
+// Relocate moves the repository at oldPath to newPath and returns the backend
+// opened at the new location. If both are on the same file system, the
+// directory is renamed atomically. Otherwise, the repository is copied to a
+// temporary directory next to newPath, every file is verified, the directory
+// is renamed to newPath and the old repository is removed. Relocate refuses
+// to run if newPath exists or the repository is locked.
+func Relocate(oldPath, newPath string) (*Local, error) {
+	return relocate(oldPath, newPath, defaultFS)
+}
+
+func relocate(oldPath, newPath string, fsys FS) (*Local, error) {
+	debug.Log("Relocate %v -> %v", oldPath, newPath)
+	if _, err := fsys.Lstat(newPath); err == nil {
+		return nil, errors.Errorf("destination %v already exists", newPath)
+	} else if !os.IsNotExist(errors.Cause(err)) {
+		return nil, errors.Wrap(err, "Lstat")
+	}
+
+	locks, err := readdirnames(fsys, filepath.Join(oldPath, backend.Paths.Locks))
+	if err != nil {
+		return nil, err
+	}
+	if len(locks) > 0 {
+		return nil, errors.Wrapf(ErrLocked, "%d lock files in %v", len(locks), oldPath)
+	}
+
+	err = fsys.Rename(oldPath, newPath)
+	if err != nil && !isEXDEV(err) {
+		return nil, errors.Wrap(err, "Rename")
+	}
+
+	if err != nil {
+		debug.Log("%v and %v are on different file systems, copying", oldPath, newPath)
+		if err = copyRepository(fsys, oldPath, newPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return open(Config{Path: newPath}, fsys)
+}
+
+// isEXDEV returns true if err reports a rename across file systems.
+func isEXDEV(err error) bool {
+	if e, ok := errors.Cause(err).(*os.LinkError); ok {
+		return e.Err == syscall.EXDEV
+	}
+	if e, ok := errors.Cause(err).(*os.PathError); ok {
+		return e.Err == syscall.EXDEV
+	}
+	return false
+}
+
+// copyRepository copies the repository at src to dst via a temporary
+// directory, verifies the copy and removes src.
+func copyRepository(fsys FS, src, dst string) error {
+	tmp := dst + ".relocate"
+	if err := fsys.RemoveAll(tmp); err != nil {
+		return errors.Wrap(err, "RemoveAll")
+	}
+
+	if err := copyTree(fsys, src, tmp); err != nil {
+		fsys.RemoveAll(tmp)
+		return err
+	}
+
+	if err := fsys.Rename(tmp, dst); err != nil {
+		fsys.RemoveAll(tmp)
+		return errors.Wrap(err, "Rename")
+	}
+
+	return errors.Wrap(fsys.RemoveAll(src), "RemoveAll")
+}
+
+// copyTree recursively copies the directory src to dst, which must not
+// exist.
+func copyTree(fsys FS, src, dst string) error {
+	if err := fsys.MkdirAll(dst, backend.Modes.Dir); err != nil {
+		return errors.Wrap(err, "MkdirAll")
+	}
+
+	entries, err := readdir(fsys, src)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range entries {
+		s, d := filepath.Join(src, fi.Name()), filepath.Join(dst, fi.Name())
+		switch {
+		case fi.IsDir():
+			err = copyTree(fsys, s, d)
+		case isFile(fi):
+			err = copyFile(fsys, s, d, fi.Mode())
+		default:
+			err = errors.Errorf("unable to copy %v: not a regular file", s)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the file src to dst and checks that the copy reads back
+// correctly.
+func copyFile(fsys FS, src, dst string, mode os.FileMode) error {
+	in, err := fsys.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+	defer in.Close()
+
+	out, err := fsys.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, backend.Modes.File)
+	if err != nil {
+		return errors.Wrap(err, "OpenFile")
+	}
+
+	hash := sha256.New()
+	if _, err = io.Copy(out, io.TeeReader(in, hash)); err != nil {
+		out.Close()
+		return errors.Wrap(err, "Write")
+	}
+
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return errors.Wrap(err, "Sync")
+	}
+
+	if err = out.Close(); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	f, err := fsys.Open(dst)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+
+	check := sha256.New()
+	_, err = io.Copy(check, f)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return errors.Wrap(err, "Read")
+	}
+
+	if !bytes.Equal(hash.Sum(nil), check.Sum(nil)) {
+		return errors.Errorf("copy of %v does not match the original", src)
+	}
+
+	return errors.Wrap(fsys.Chmod(dst, mode), "Chmod")
+}
@@ -0,0 +1,88 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"syscall"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func testRelocate(t *testing.T, fsys FS) {
+	dir, err := ioutil.TempDir(TestTempDir, "restic-test-relocate-")
+	OK(t, err)
+	defer RemoveAll(t, dir)
+
+	oldPath := filepath.Join(dir, "old")
+	newPath := filepath.Join(dir, "new")
+
+	be, err := Create(Config{Path: oldPath})
+	OK(t, err)
+
+	var handles []restic.Handle
+	for i := 0; i < 10; i++ {
+		data := Random(i, 200)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		handles = append(handles, h)
+	}
+	OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, bytes.NewReader([]byte("config"))))
+
+	// the repository is locked
+	lock := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	OK(t, be.Save(lock, bytes.NewReader([]byte("lock"))))
+	_, err = relocate(oldPath, newPath, fsys)
+	Assert(t, errors.Cause(err) == ErrLocked, "expected ErrLocked, got %v", err)
+	OK(t, be.Remove(lock))
+
+	// the destination exists
+	OK(t, os.Mkdir(newPath, 0700))
+	_, err = relocate(oldPath, newPath, fsys)
+	Assert(t, err != nil, "existing destination was accepted")
+	OK(t, os.Remove(newPath))
+
+	be2, err := relocate(oldPath, newPath, fsys)
+	OK(t, err)
+	Equals(t, newPath, be2.Location())
+
+	_, err = os.Stat(oldPath)
+	Assert(t, os.IsNotExist(err), "old repository still exists")
+
+	for _, h := range handles {
+		OK(t, be2.Verify(h))
+	}
+
+	ok, err := be2.Test(restic.Handle{Type: restic.ConfigFile})
+	OK(t, err)
+	Assert(t, ok, "config not relocated")
+}
+
+func TestRelocate(t *testing.T) {
+	testRelocate(t, defaultFS)
+}
+
+func TestRelocateCrossDevice(t *testing.T) {
+	// only the rename of the repository itself fails, the copy is moved
+	// into place afterwards
+	copies, renames := 0, 0
+	fsys := &fakeFS{FS: defaultFS, fail: func(op, name string) error {
+		switch {
+		case op == "Rename" && filepath.Base(name) == "new":
+			renames++
+			if renames == 1 {
+				return syscall.EXDEV
+			}
+		case op == "OpenFile":
+			copies++
+		}
+		return nil
+	}}
+
+	testRelocate(t, fsys)
+	Assert(t, copies > 0, "repository was not copied")
+}
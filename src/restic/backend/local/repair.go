@@ -0,0 +1,48 @@
+package local
+
+import (
+	"encoding/hex"
+	"io"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// RepairFrom replaces the file at dst with the data read from src, e.g. a
+// known-good copy from a mirror. The data is written to a tempfile and its
+// hash is compared to expectedHash first, the file at dst is only replaced
+// (atomically) if they match. Otherwise, ErrHashMismatch is returned and dst
+// is left untouched. In contrast to Save, dst may exist.
+func (b *Local) RepairFrom(dst restic.Handle, src io.Reader, expectedHash string) error {
+	debug.Log("RepairFrom %v", dst)
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	if err := dst.Valid(); err != nil {
+		return err
+	}
+
+	if err := b.checkPermitted(dst.Type); err != nil {
+		return err
+	}
+
+	hash := b.newHash()
+	rd := io.TeeReader(src, hash)
+
+	opts := b.record(saveOptions{overwrite: true})
+	rd = opts.tee(rd)
+
+	tmpfile, size, err := b.copyToTempfile(rd)
+	if err != nil {
+		return err
+	}
+
+	if id := hex.EncodeToString(hash.Sum(nil)); id != expectedHash {
+		b.FS.Remove(tmpfile)
+		return errors.Wrapf(ErrHashMismatch, "data for %v has hash %v, want %v", dst, id, expectedHash)
+	}
+
+	return b.commit(dst, tmpfile, size, nil, opts)
+}
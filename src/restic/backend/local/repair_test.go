@@ -0,0 +1,50 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func loadAll(t testing.TB, be restic.Backend, h restic.Handle) []byte {
+	rd, err := be.Load(h, 0, 0)
+	OK(t, err)
+	buf, err := ioutil.ReadAll(rd)
+	OK(t, err)
+	OK(t, rd.Close())
+	return buf
+}
+
+func TestRepairFrom(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	// simulate a corrupted file
+	corrupted := append([]byte(nil), data...)
+	corrupted[500] ^= 0xff
+	OK(t, be.Save(h, bytes.NewReader(corrupted)))
+	Assert(t, errors.Cause(be.Verify(h)) == local.ErrHashMismatch, "file is not corrupted")
+
+	// a bad source is refused and the file is left as it is
+	other := Random(5, 1000)
+	err := be.RepairFrom(h, bytes.NewReader(other), h.Name)
+	Assert(t, errors.Cause(err) == local.ErrHashMismatch, "expected ErrHashMismatch, got %v", err)
+	Equals(t, corrupted, loadAll(t, be, h))
+
+	OK(t, be.RepairFrom(h, bytes.NewReader(data), h.Name))
+	Equals(t, data, loadAll(t, be, h))
+	OK(t, be.Verify(h))
+
+	// missing files can be repaired as well
+	OK(t, be.Remove(h))
+	OK(t, be.RepairFrom(h, bytes.NewReader(data), h.Name))
+	Equals(t, data, loadAll(t, be, h))
+}
@@ -0,0 +1,17 @@
+package local
+
+import (
+	"io"
+	"restic"
+
+	"restic/debug"
+)
+
+// Replace stores the data from rd at the handle, atomically replacing the
+// file if it already exists. The data is written to a tempfile first, which
+// is then renamed over the old file, so readers either see the old or the new
+// content. Apart from replacing the file, Replace works like Save.
+func (b *Local) Replace(h restic.Handle, rd io.Reader) error {
+	debug.Log("Replace %v", h)
+	return b.save(h, rd, saveOptions{overwrite: true})
+}
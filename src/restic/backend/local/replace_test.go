@@ -0,0 +1,30 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestReplaceSidecars(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.CRC32C = true
+	be.ParityShards = 1
+	be.Footer = true
+
+	key := restic.Handle{Type: restic.KeyFile, Name: "key"}
+	OK(t, be.Save(key, bytes.NewReader([]byte("old key"))))
+	OK(t, be.Replace(key, bytes.NewReader([]byte("new key"))))
+	Equals(t, []byte("new key"), load(t, be, key, 0, 0))
+	OK(t, be.Scrub(key))
+
+	// the parity and footer are written for the replaced file
+	h, data := saveData(t, be, 23, 1024)
+	OK(t, be.Replace(h, bytes.NewReader(data)))
+	corruptAt(t, filename(be.Path, h.Type, h.Name), 100)
+	OK(t, be.Repair(h))
+	Equals(t, data, load(t, be, h, 0, 0))
+}
@@ -0,0 +1,108 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrNoSnapshotCommand is returned by SnapshotRepo, ListRepoSnapshots and
+// RollbackRepo if the command for the operation is not configured.
+var ErrNoSnapshotCommand = errors.New("no snapshot command configured")
+
+// SnapshotCommands hold the commands which create, list and roll back
+// snapshots of the repository directory on copy-on-write file systems like
+// btrfs or ZFS. Each command is a program followed by its arguments, in
+// which "{path}" is replaced by the repository path and "{label}" by the
+// label of the snapshot. The List command must print one label per line.
+// For example, with the repository in a btrfs subvolume:
+//
+//	Create: btrfs subvolume snapshot -r {path} {path}.snapshots/{label}
+type SnapshotCommands struct {
+	Create   []string
+	List     []string
+	Rollback []string
+}
+
+// runSnapshotCommand runs the command cmd for label and returns its output.
+func (b *Local) runSnapshotCommand(op string, cmd []string, label string) ([]byte, error) {
+	if len(cmd) == 0 {
+		return nil, errors.Wrap(ErrNoSnapshotCommand, op)
+	}
+
+	r := strings.NewReplacer("{path}", b.Path, "{label}", label)
+	args := make([]string, len(cmd))
+	for i, arg := range cmd {
+		args[i] = r.Replace(arg)
+	}
+
+	debug.Log("%v: running %v", op, args)
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return nil, errors.Wrapf(err, "%v: %v", op, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// checkLabel returns an error if label cannot be used as a snapshot label.
+func checkLabel(label string) error {
+	if label == "" || strings.ContainsAny(label, "/\\\n") {
+		return errors.Errorf("invalid snapshot label %q", label)
+	}
+	return nil
+}
+
+// SnapshotRepo captures the whole repository directory in a file system
+// snapshot with the given label, by running SnapshotCommands.Create.
+func (b *Local) SnapshotRepo(label string) error {
+	if err := checkLabel(label); err != nil {
+		return err
+	}
+
+	_, err := b.runSnapshotCommand("SnapshotRepo", b.SnapshotCommands.Create, label)
+	return err
+}
+
+// ListRepoSnapshots returns the labels of the file system snapshots of the
+// repository printed by SnapshotCommands.List.
+func (b *Local) ListRepoSnapshots() ([]string, error) {
+	out, err := b.runSnapshotCommand("ListRepoSnapshots", b.SnapshotCommands.List, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if label := strings.TrimSpace(sc.Text()); label != "" {
+			labels = append(labels, label)
+		}
+	}
+
+	return labels, errors.Wrap(sc.Err(), "Scan")
+}
+
+// RollbackRepo restores the repository directory to the file system
+// snapshot with the given label, by running SnapshotCommands.Rollback. The
+// backend must not be used by other processes while this happens, and it
+// should be opened again afterwards.
+func (b *Local) RollbackRepo(label string) error {
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	if err := checkLabel(label); err != nil {
+		return err
+	}
+
+	_, err := b.runSnapshotCommand("RollbackRepo", b.SnapshotCommands.Rollback, label)
+	return err
+}
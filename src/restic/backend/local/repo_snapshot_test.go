@@ -0,0 +1,69 @@
+// +build !windows
+
+package local_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestSnapshotRepo(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	tempdir, err := ioutil.TempDir(TestTempDir, "restic-snapshot-cmd-")
+	OK(t, err)
+	defer RemoveAll(t, tempdir)
+
+	// the mock commands record their arguments in a log file
+	log := filepath.Join(tempdir, "log")
+	record := func(op string) []string {
+		return []string{"sh", "-c", `echo "$0 $1 $2" >> "$3"`, op, "{path}", "{label}", log}
+	}
+
+	be.SnapshotCommands = local.SnapshotCommands{
+		Create:   record("create"),
+		List:     []string{"sh", "-c", `printf "first\n\nsecond\n"`},
+		Rollback: record("rollback"),
+	}
+
+	OK(t, be.SnapshotRepo("first"))
+	OK(t, be.SnapshotRepo("second"))
+
+	labels, err := be.ListRepoSnapshots()
+	OK(t, err)
+	Equals(t, []string{"first", "second"}, labels)
+
+	OK(t, be.RollbackRepo("first"))
+
+	buf, err := ioutil.ReadFile(log)
+	OK(t, err)
+	Equals(t, []string{
+		"create " + be.Path + " first",
+		"create " + be.Path + " second",
+		"rollback " + be.Path + " first",
+	}, strings.Split(strings.TrimSpace(string(buf)), "\n"))
+
+	Assert(t, be.SnapshotRepo("../escape") != nil, "invalid label accepted")
+	Assert(t, be.SnapshotRepo("") != nil, "empty label accepted")
+}
+
+func TestSnapshotRepoErrors(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	err := be.SnapshotRepo("label")
+	Assert(t, errors.Cause(err) == local.ErrNoSnapshotCommand, "expected ErrNoSnapshotCommand, got %v", err)
+	_, err = be.ListRepoSnapshots()
+	Assert(t, errors.Cause(err) == local.ErrNoSnapshotCommand, "expected ErrNoSnapshotCommand, got %v", err)
+
+	be.SnapshotCommands.Create = []string{"sh", "-c", "echo failed >&2; exit 1"}
+	err = be.SnapshotRepo("label")
+	Assert(t, err != nil && strings.Contains(err.Error(), "failed"), "expected command error, got %v", err)
+}
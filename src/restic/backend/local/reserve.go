@@ -0,0 +1,50 @@
+package local
+
+import (
+	"path/filepath"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// Reserve allocates n bytes on the file system of the repository in a
+// hidden file below Paths.Temp, so that a backup of known size does not run
+// out of space near the end because other processes consumed it. Calling
+// release removes the file and frees the space again. To shrink the
+// reservation while the backup writes data, release it and reserve the
+// remaining amount. If the space is not available, ErrNoSpace is returned.
+// The space is only reserved on Linux, elsewhere Reserve does nothing.
+func (b *Local) Reserve(n int64) (release func(), err error) {
+	debug.Log("Reserve %d bytes", n)
+	if err := b.checkRecoverMode(); err != nil {
+		return nil, err
+	}
+
+	if n <= 0 || !fallocateSupported {
+		return func() {}, nil
+	}
+
+	f, err := b.FS.TempFile(filepath.Join(b.Path, backend.Paths.Temp), ".reserve-")
+	if err != nil {
+		return nil, errors.Wrap(err, "TempFile")
+	}
+	fn := f.Name()
+
+	err = fallocate(f, n)
+	if e := f.Close(); err == nil {
+		err = errors.Wrap(e, "Close")
+	}
+	if err != nil {
+		b.FS.Remove(fn)
+		return nil, noSpace(err, "Fallocate")
+	}
+
+	release = func() {
+		if err := b.FS.Remove(fn); err != nil {
+			debug.Log("unable to remove reservation %v: %v", fn, err)
+		}
+	}
+
+	return release, nil
+}
@@ -0,0 +1,15 @@
+package local
+
+import "syscall"
+
+// fallocateSupported is true if fallocate reserves space.
+const fallocateSupported = true
+
+// fallocate allocates size bytes for f.
+func fallocate(f File, size int64) error {
+	return fdOp(f, "Fallocate", func() error {
+		return retryEINTR("Fallocate", func() error {
+			return syscall.Fallocate(int(f.Fd()), 0, 0, size)
+		})
+	})
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package local
+
+// fallocateSupported is true if fallocate reserves space.
+const fallocateSupported = false
+
+// fallocate does nothing, space can only be reserved on Linux.
+func fallocate(f File, size int64) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+// +build linux
+
+package local
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"restic/backend"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestReserve(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	tempdir := filepath.Join(be.Path, backend.Paths.Temp)
+
+	release, err := be.Reserve(1 << 20)
+	if errors.Cause(err) == syscall.EOPNOTSUPP {
+		t.Skip("fallocate is not supported by the file system")
+	}
+	OK(t, err)
+
+	entries, err := ioutil.ReadDir(tempdir)
+	OK(t, err)
+	Equals(t, 1, len(entries))
+	Equals(t, int64(1<<20), entries[0].Size())
+	Assert(t, allocated(t, filepath.Join(tempdir, entries[0].Name())) >= 1<<20,
+		"space was not allocated")
+
+	release()
+	entries, err = ioutil.ReadDir(tempdir)
+	OK(t, err)
+	Equals(t, 0, len(entries))
+
+	// nothing to reserve
+	release, err = be.Reserve(0)
+	OK(t, err)
+	release()
+}
+
+func TestReserveTooLarge(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	var st syscall.Statfs_t
+	OK(t, syscall.Statfs(be.Path, &st))
+	avail := int64(st.Bavail) * int64(st.Bsize)
+
+	_, err := be.Reserve(avail + 1<<30)
+	Assert(t, err != nil, "reserving more than the available space succeeded")
+	if errors.Cause(err) != syscall.EFBIG {
+		Assert(t, errors.Cause(err) == ErrNoSpace, "expected ErrNoSpace, got %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(be.Path, backend.Paths.Temp))
+	OK(t, err)
+	Equals(t, 0, len(entries))
+}
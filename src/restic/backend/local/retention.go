@@ -0,0 +1,47 @@
+package local
+
+import (
+	"os"
+	"restic"
+	"time"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrRetention is returned by Remove when the file is younger than the
+// retention configured for its type.
+var ErrRetention = errors.New("file is younger than retention period")
+
+// checkRetention returns ErrRetention if the file fn for h was modified within
+// the retention period configured for h.Type.
+func (b *Local) checkRetention(h restic.Handle, fn string) error {
+	retention, ok := b.RetentionByType[h.Type]
+	if !ok || retention <= 0 {
+		return nil
+	}
+
+	fi, err := b.FS.Stat(fn)
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	return b.checkAge(h, fi)
+}
+
+// checkAge returns ErrRetention if fi, the file for h, was modified within
+// the retention period configured for h.Type.
+func (b *Local) checkAge(h restic.Handle, fi os.FileInfo) error {
+	retention, ok := b.RetentionByType[h.Type]
+	if !ok || retention <= 0 {
+		return nil
+	}
+
+	age := time.Since(fi.ModTime())
+	if age < retention {
+		debug.Log("refusing to remove %v, age %v < retention %v", h, age, retention)
+		return errors.Wrapf(ErrRetention, "%v modified %v ago, retention is %v", h, age, retention)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package local_test
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+	"time"
+
+	"restic/backend"
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestRetentionByType(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	dirs := map[restic.FileType]string{
+		restic.SnapshotFile: backend.Paths.Snapshots,
+		restic.IndexFile:    backend.Paths.Index,
+		restic.KeyFile:      backend.Paths.Keys,
+	}
+
+	be.RetentionByType = map[restic.FileType]time.Duration{
+		restic.SnapshotFile: 24 * time.Hour,
+		restic.IndexFile:    time.Hour,
+		restic.KeyFile:      time.Minute,
+	}
+
+	for tpe, retention := range be.RetentionByType {
+		h := restic.Handle{Type: tpe, Name: "foo"}
+		OK(t, be.Save(h, strings.NewReader("foo")))
+
+		err := be.Remove(h)
+		Assert(t, errors.Cause(err) == local.ErrRetention,
+			"%v: expected retention error, got %v", tpe, err)
+
+		ok, err := be.Test(h)
+		OK(t, err)
+		Assert(t, ok, "%v: file was removed", h)
+
+		// files older than the retention period can be removed
+		old := time.Now().Add(-retention - time.Minute)
+		OK(t, os.Chtimes(filepath.Join(be.Location(), dirs[tpe], h.Name), old, old))
+		OK(t, be.Remove(h))
+	}
+
+	// types without retention can be removed freely
+	h := restic.Handle{Type: restic.DataFile, Name: "foobar"}
+	OK(t, be.Save(h, strings.NewReader("foo")))
+	OK(t, be.Remove(h))
+}
@@ -0,0 +1,66 @@
+package local_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func upper(h restic.Handle, rd io.Reader) (io.Reader, error) {
+	buf, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(bytes.ToUpper(buf)), nil
+}
+
+func TestSaveHook(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	be.SaveHook = upper
+
+	h := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	OK(t, be.Save(h, bytes.NewReader([]byte("foobar"))))
+	Equals(t, []byte("FOOBAR"), loadAll(t, be, h))
+
+	// the hash is checked on the transformed data
+	be.VerifyHash = true
+	data := []byte("some data")
+	h = restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	err := be.Save(h, bytes.NewReader(data))
+	Assert(t, errors.Cause(err) == local.ErrHashMismatch, "expected ErrHashMismatch, got %v", err)
+
+	h = restic.Handle{Type: restic.DataFile, Name: restic.Hash(bytes.ToUpper(data)).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	Equals(t, bytes.ToUpper(data), loadAll(t, be, h))
+}
+
+func TestSaveHookError(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	hookErr := errors.New("hook failed")
+	be.SaveHook = func(restic.Handle, io.Reader) (io.Reader, error) {
+		return nil, hookErr
+	}
+
+	h := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	err := be.Save(h, bytes.NewReader([]byte("foobar")))
+	Assert(t, errors.Cause(err) == hookErr, "expected hook error, got %v", err)
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "file saved despite hook error")
+
+	entries, err := ioutil.ReadDir(filepath.Join(be.Path, backend.Paths.Temp))
+	OK(t, err)
+	Equals(t, 0, len(entries))
+}
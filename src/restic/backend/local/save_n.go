@@ -0,0 +1,21 @@
+package local
+
+import (
+	"io"
+	"restic"
+
+	"restic/debug"
+)
+
+// SaveN works like Save and returns the size of the file on disk, which
+// includes the data added by the backend, e.g. the footer if Footer is
+// enabled. This is the amount of storage used by the file.
+func (b *Local) SaveN(h restic.Handle, rd io.Reader) (int64, error) {
+	debug.Log("SaveN %v", h)
+	var stored int64
+	err := b.save(h, rd, saveOptions{stored: &stored})
+	if err != nil {
+		return 0, err
+	}
+	return stored, nil
+}
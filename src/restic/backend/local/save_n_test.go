@@ -0,0 +1,42 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestSaveN(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	for _, footer := range []bool{false, true} {
+		be.Footer = footer
+
+		data := Random(23, 1000)
+		if footer {
+			data = Random(5, 500)
+		}
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+		n, err := be.SaveN(h, bytes.NewReader(data))
+		OK(t, err)
+
+		fi, err := os.Stat(filename(be.Path, h.Type, h.Name))
+		OK(t, err)
+		Equals(t, fi.Size(), n)
+
+		want := int64(len(data))
+		if footer {
+			want += FooterSize
+		}
+		Equals(t, want, n)
+
+		info, err := be.Stat(h)
+		OK(t, err)
+		Equals(t, int64(len(data)), info.Size)
+	}
+}
@@ -0,0 +1,21 @@
+package local
+
+import (
+	"io"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrTooLarge is returned by SaveStream if the reader yields more data than
+// allowed.
+var ErrTooLarge = errors.New("data exceeds size limit")
+
+// SaveStream stores the data from rd, which may be of unknown length, at the
+// handle. If rd yields more than maxBytes bytes, nothing is stored and
+// ErrTooLarge is returned. A maxBytes of zero means no limit.
+func (b *Local) SaveStream(h restic.Handle, rd io.Reader, maxBytes int64) error {
+	debug.Log("SaveStream %v, maxBytes %v", h, maxBytes)
+	return b.save(h, rd, saveOptions{maxBytes: maxBytes})
+}
@@ -0,0 +1,55 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestSaveStream(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	var tests = []struct {
+		size, max int64
+		ok        bool
+	}{
+		{100, 0, true},
+		{100, 101, true},
+		{100, 100, true},
+		{100, 99, false},
+		{100, 1, false},
+	}
+
+	for i, test := range tests {
+		data := Random(i, int(test.size))
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		err := be.SaveStream(h, bytes.NewReader(data), test.max)
+
+		if !test.ok {
+			Assert(t, errors.Cause(err) == local.ErrTooLarge,
+				"test %d: expected ErrTooLarge, got %v", i, err)
+
+			ok, err := be.Test(h)
+			OK(t, err)
+			Assert(t, !ok, "test %d: file was saved", i)
+			continue
+		}
+
+		OK(t, err)
+		buf, err := backend.LoadAll(be, h)
+		OK(t, err)
+		Equals(t, data, buf)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(be.Location(), "tmp"))
+	OK(t, err)
+	Equals(t, 0, len(files))
+}
@@ -0,0 +1,105 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+	"sync"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// shardCounts holds the number of entries in the data subdirectories, so
+// that Save reads each directory only once.
+type shardCounts struct {
+	m      sync.Mutex
+	counts map[string]int
+}
+
+// get returns the cached number of entries in dir.
+func (c *shardCounts) get(dir string) (int, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	n, ok := c.counts[dir]
+	return n, ok
+}
+
+// set records n entries for dir.
+func (c *shardCounts) set(dir string, n int) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[dir] = n
+}
+
+// inc records a new entry in dir if its count is known.
+func (c *shardCounts) inc(dir string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if n, ok := c.counts[dir]; ok {
+		c.counts[dir] = n + 1
+	}
+}
+
+// subShardFilename returns the name of the data file name in the sub-shard
+// of its shard, e.g. data/ab/c/abcdef... for abcdef....
+func (b *Local) subShardFilename(name string) string {
+	return filepath.Join(dirname(b.Path, restic.DataFile, name), name[2:3], b.naming().Encode(name))
+}
+
+// hasSubShard returns true if data files named name may be stored in a
+// sub-shard.
+func (b *Local) hasSubShard(t restic.FileType, name string) bool {
+	return t == restic.DataFile && b.MaxShardEntries > 0 && len(name) > 2
+}
+
+// shardEntries returns the number of entries in the shard dir, which is read
+// unless the count is cached. A missing shard has no entries.
+func (b *Local) shardEntries(dir string) (int, error) {
+	if n, ok := b.shards.get(dir); ok {
+		return n, nil
+	}
+
+	names, err := readdirnames(b.FS, dir)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return 0, err
+	}
+
+	b.shards.set(dir, len(names))
+	return len(names), nil
+}
+
+// shardFiles returns the names of the data files in the shard directory dir,
+// including those in its sub-shard directories.
+func (b *Local) shardFiles(dir string) ([]string, error) {
+	names, err := listShard(b.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+	return b.decodeNames(names), nil
+}
+
+// dataTarget returns the file name a new data file is saved to: its shard,
+// or the sub-shard if the shard holds MaxShardEntries entries or more.
+func (b *Local) dataTarget(name string) string {
+	fn := b.filename(restic.DataFile, name)
+	if !b.hasSubShard(restic.DataFile, name) {
+		return fn
+	}
+
+	n, err := b.shardEntries(filepath.Dir(fn))
+	if err != nil {
+		debug.Log("unable to count entries of %v: %v", filepath.Dir(fn), err)
+		return fn
+	}
+
+	if n < b.MaxShardEntries {
+		return fn
+	}
+
+	debug.Log("shard %v has %d entries, using sub-shard for %v", filepath.Dir(fn), n, name)
+	return b.subShardFilename(name)
+}
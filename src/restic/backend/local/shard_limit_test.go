@@ -0,0 +1,171 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestMaxShardEntries(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.MaxShardEntries = 2
+
+	var handles []restic.Handle
+	for i := 0; i < 4; i++ {
+		h := restic.Handle{Type: restic.DataFile, Name: fmt.Sprintf("ab%x%061x", i+10, i)}
+		OK(t, be.Save(h, bytes.NewReader([]byte(h.Name))))
+		handles = append(handles, h)
+	}
+
+	// the first files fill the shard, the others go to the sub-shard
+	for i, h := range handles {
+		fn := filename(be.Path, h.Type, h.Name)
+		if i >= 2 {
+			fn = filepath.Join(filepath.Dir(fn), h.Name[2:3], h.Name)
+		}
+		_, err := os.Stat(fn)
+		OK(t, err)
+	}
+
+	for _, h := range handles {
+		Equals(t, []byte(h.Name), load(t, be, h, 0, 0))
+
+		fi, err := be.Stat(h)
+		OK(t, err)
+		Equals(t, int64(len(h.Name)), fi.Size)
+	}
+
+	var names []string
+	for name := range be.List(restic.DataFile, nil) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	Equals(t, 4, len(names))
+	for i, h := range handles {
+		Equals(t, h.Name, names[i])
+	}
+
+	// a new backend counts the entries of the full shard
+	be2, err := Open(be.Config)
+	OK(t, err)
+	h := restic.Handle{Type: restic.DataFile, Name: fmt.Sprintf("abf%061x", 9)}
+	OK(t, be2.Save(h, bytes.NewReader([]byte(h.Name))))
+	_, err = os.Stat(filepath.Join(be.Path, "data", "ab", "f", h.Name))
+	OK(t, err)
+
+	OK(t, be.Remove(handles[3]))
+	ok, err := be.Test(handles[3])
+	OK(t, err)
+	Assert(t, !ok, "%v still exists after Remove", handles[3])
+}
+
+func TestMaxShardEntriesListings(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.MaxShardEntries = 1
+
+	var handles []restic.Handle
+	var want []string
+	for i := 0; i < 200; i++ {
+		h, _ := saveData(t, be, i, 10)
+		handles = append(handles, h)
+		want = append(want, h.Name)
+	}
+	sort.Strings(want)
+
+	collect := func(ch <-chan string) []string {
+		var names []string
+		for name := range ch {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	Equals(t, want, collect(be.List(restic.DataFile, nil)))
+	Equals(t, want, collect(be.ListFrom(restic.DataFile, "", nil)))
+
+	l, err := be.Snapshot()
+	OK(t, err)
+	Equals(t, want, l.Names(restic.DataFile))
+
+	var paged []string
+	cursor := ""
+	for {
+		names, next, err := be.ListPage(restic.DataFile, cursor, 30)
+		OK(t, err)
+		paged = append(paged, names...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	Equals(t, want, paged)
+
+	res, err := be.TestMany(handles)
+	OK(t, err)
+	for _, h := range handles {
+		Assert(t, res[h], "%v not found by TestMany", h)
+	}
+
+	be.ListDirDelay = time.Nanosecond
+	Equals(t, want, collect(be.List(restic.DataFile, nil)))
+}
+
+func TestMaxShardEntriesMigrationMode(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.MaxShardEntries = 1
+	be.MigrationMode = true
+
+	data := Random(23, 100)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, ioutil.WriteFile(filepath.Join(be.Path, "data", h.Name), data, 0400))
+
+	Equals(t, data, load(t, be, h, 0, 0))
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, ok, "file in flat location not found")
+	OK(t, be.Verify(h))
+}
+
+func TestMaxShardEntriesVerify(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.MaxShardEntries = 1
+	be.ChecksumCache = true
+
+	var sub []restic.Handle
+	for i := 0; i < 100; i++ {
+		h, _ := saveData(t, be, i, 10)
+		fn := filename(be.Path, h.Type, h.Name)
+		if _, err := os.Stat(fn); os.IsNotExist(err) {
+			sub = append(sub, h)
+		}
+	}
+	Assert(t, len(sub) > 0, "no file was saved to a sub-shard")
+
+	// the second run is served by the checksum cache
+	for i := 0; i < 2; i++ {
+		for _, h := range sub {
+			OK(t, be.Verify(h))
+		}
+	}
+
+	h := sub[0]
+	fn := filepath.Join(filepath.Dir(filename(be.Path, h.Type, h.Name)), h.Name[2:3], h.Name)
+	corruptAt(t, fn, 3)
+	OK(t, os.Chtimes(fn, time.Now(), time.Now().Add(time.Hour)))
+	err := be.Verify(h)
+	Assert(t, errors.Cause(err) == ErrHashMismatch, "expected ErrHashMismatch, got %v", err)
+}
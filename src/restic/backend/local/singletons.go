@@ -0,0 +1,30 @@
+package local
+
+import (
+	"restic"
+
+	"restic/debug"
+)
+
+// ListSingletons returns the files stored directly in the repository
+// directory by name, with their size and modification time. Besides the
+// config, these are files like the protection marker written by Protect.
+// The directories holding the files of the other types are not included.
+func (b *Local) ListSingletons() (map[string]restic.FileInfo, error) {
+	debug.Log("ListSingletons %v", b.Path)
+	entries, err := readdir(b.FS, b.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]restic.FileInfo)
+	for _, fi := range entries {
+		if !isFile(fi) {
+			continue
+		}
+
+		files[fi.Name()] = restic.FileInfo{Size: fi.Size(), ModTime: fi.ModTime()}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,48 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestListSingletons(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	files, err := be.ListSingletons()
+	OK(t, err)
+	Equals(t, 0, len(files))
+
+	OK(t, be.Save(restic.Handle{Type: restic.ConfigFile}, bytes.NewReader([]byte("config"))))
+	OK(t, be.Protect())
+	OK(t, ioutil.WriteFile(filepath.Join(be.Path, "version"), []byte("2\n"), 0600))
+
+	// files of other types are not included
+	OK(t, be.Save(restic.Handle{Type: restic.LockFile, Name: "lock"}, bytes.NewReader([]byte("lock"))))
+
+	files, err = be.ListSingletons()
+	OK(t, err)
+	Equals(t, 3, len(files))
+
+	for name, size := range map[string]int64{"config": 6, "version": 2} {
+		fi, ok := files[name]
+		Assert(t, ok, "%v not listed", name)
+		Equals(t, size, fi.Size)
+
+		st, err := os.Stat(filepath.Join(be.Path, name))
+		OK(t, err)
+		Assert(t, fi.ModTime.Equal(st.ModTime()), "wrong mtime %v for %v", fi.ModTime, name)
+	}
+
+	_, ok := files["protected"]
+	Assert(t, ok, "protection marker not listed")
+
+	OK(t, be.Unprotect())
+}
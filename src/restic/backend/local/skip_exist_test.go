@@ -0,0 +1,97 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"testing"
+
+	. "restic/test"
+)
+
+// recordOps returns a fakeFS which records the operations on name.
+func recordOps(fsys FS, name string, ops *[]string) *fakeFS {
+	return &fakeFS{FS: fsys, fail: func(op, fn string) error {
+		if fn == name {
+			*ops = append(*ops, op)
+		}
+		return nil
+	}}
+}
+
+func TestSkipExistCheck(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		be, cleanup := TestBackend(t)
+		be.SkipExistCheck = skip
+
+		data := Random(23, 100)
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+		var ops []string
+		be.FS = recordOps(be.FS, filename(be.Path, h.Type, h.Name), &ops)
+		OK(t, be.Save(h, bytes.NewReader(data)))
+
+		// the file is only stat'ed before the rename when checking for
+		// existence
+		statBeforeRename := false
+		for _, op := range ops {
+			if op == "Rename" {
+				break
+			}
+			if op == "Stat" || op == "Lstat" {
+				statBeforeRename = true
+			}
+		}
+		Equals(t, !skip, statBeforeRename)
+
+		cleanup()
+	}
+}
+
+func TestSkipExistCheckOverwrite(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	OK(t, be.Save(h, bytes.NewReader([]byte("first"))))
+	Assert(t, be.Save(h, bytes.NewReader([]byte("second"))) != nil, "existing file was overwritten")
+
+	// without the check, the file is replaced
+	be.SkipExistCheck = true
+	OK(t, be.Save(h, bytes.NewReader([]byte("second"))))
+	Equals(t, []byte("second"), load(t, be, h, 0, 0))
+
+	data := Random(23, 100)
+	h = restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	Equals(t, data, load(t, be, h, 0, 0))
+}
+
+func BenchmarkSaveExistCheck(b *testing.B) {
+	for _, skip := range []bool{false, true} {
+		name := "check"
+		if skip {
+			name = "skip"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			be, cleanup := TestBackend(b)
+			defer cleanup()
+			be.SkipExistCheck = skip
+
+			ops := make(map[string]int)
+			be.FS = countOps(be.FS, ops)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				data := Random(i, 64)
+				h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+				if err := be.Save(h, bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.Logf("%d saves: %.2f Stat calls per save", b.N, float64(ops["Stat"]+ops["Lstat"])/float64(b.N))
+		})
+	}
+}
@@ -0,0 +1,591 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+	"sync"
+	"time"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// smallContainerSize is the size after which a new container is started for
+// packed files.
+const smallContainerSize = 16 << 20
+
+// smallIndexFile is the name of the index of the packed files below
+// packed/, all other files there are containers.
+const smallIndexFile = "index"
+
+// packedEntry is the location of a packed file within a container, as
+// recorded in the index. Removed is set for entries which record the
+// removal of a file.
+type packedEntry struct {
+	Type      restic.FileType `json:"type"`
+	Name      string          `json:"name"`
+	Container string          `json:"container,omitempty"`
+	Offset    int64           `json:"offset,omitempty"`
+	Length    int64           `json:"length,omitempty"`
+	Removed   bool            `json:"removed,omitempty"`
+}
+
+// smallPack holds the index of the packed files, it is set by
+// PackSmallBlobs, or by Open if the repository contains packed files.
+type smallPack struct {
+	m         sync.Mutex
+	threshold int
+	entries   map[restic.Handle]packedEntry
+
+	// sizes holds the number of bytes written to each container, live the
+	// number of bytes used by files which have not been removed
+	sizes   map[string]int64
+	live    map[string]int64
+	current string
+}
+
+func (b *Local) packedDir() string {
+	return filepath.Join(b.Path, localPaths.Packed)
+}
+
+// PackSmallBlobs makes Save append content-addressed files smaller than
+// threshold bytes to shared container files below packed/, instead of
+// storing each in a file of its own. This saves inodes and speeds up listing
+// for repositories with many tiny files. The location of each file is
+// recorded in an index, so that the methods of the backend handle packed
+// files like the others. Remove only marks a packed file as removed,
+// CompactSmallBlobs reclaims the space. Replace stores the new data packed
+// or on its own depending on its size, and drops the old copy. A threshold
+// of zero stops packing new files, those packed already remain accessible.
+// The index is read again from disk.
+func (b *Local) PackSmallBlobs(threshold int) error {
+	debug.Log("PackSmallBlobs %d", threshold)
+	if threshold > 0 {
+		if err := b.createDir(b.packedDir()); err != nil {
+			return err
+		}
+	}
+
+	p, err := b.loadSmallPack()
+	if err != nil {
+		return err
+	}
+
+	p.threshold = threshold
+	b.small = p
+	return nil
+}
+
+// loadSmallPack reads the index of the packed files.
+func (b *Local) loadSmallPack() (*smallPack, error) {
+	p := &smallPack{
+		entries: make(map[restic.Handle]packedEntry),
+		sizes:   make(map[string]int64),
+		live:    make(map[string]int64),
+	}
+
+	f, err := b.FS.Open(filepath.Join(b.packedDir(), smallIndexFile))
+	if os.IsNotExist(errors.Cause(err)) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Open")
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e packedEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// a partially written last line is ignored
+			debug.Log("invalid index entry %q: %v", sc.Text(), err)
+			continue
+		}
+		p.apply(e)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "Read")
+	}
+
+	return p, nil
+}
+
+// apply updates the index with the entry e.
+func (p *smallPack) apply(e packedEntry) {
+	h := restic.Handle{Type: e.Type, Name: e.Name}
+	if old, ok := p.entries[h]; ok {
+		p.live[old.Container] -= old.Length
+		delete(p.entries, h)
+	}
+
+	if e.Removed {
+		return
+	}
+
+	p.entries[h] = e
+	p.live[e.Container] += e.Length
+	if end := e.Offset + e.Length; end > p.sizes[e.Container] {
+		p.sizes[e.Container] = end
+	}
+}
+
+// packed returns the location of h if it is a packed file.
+func (b *Local) packed(h restic.Handle) (packedEntry, bool) {
+	p := b.small
+	if p == nil {
+		return packedEntry{}, false
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	e, ok := p.entries[h]
+	return e, ok
+}
+
+// readSmall reads rd up to the threshold. If rd ends before, the data is
+// returned and small is true. Otherwise, buf holds the data read so far.
+func (b *Local) readSmall(rd io.Reader) (buf []byte, small bool, err error) {
+	buf = make([]byte, b.small.threshold)
+	n, err := io.ReadFull(rd, buf)
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return buf[:n], true, nil
+	case nil:
+		return buf, false, nil
+	default:
+		return nil, false, errors.Wrap(err, "Read")
+	}
+}
+
+// savePacked appends the data for h to a container and records it in the
+// index, with the same checks as commit.
+func (b *Local) savePacked(h restic.Handle, buf []byte, hash hash.Hash, opts saveOptions) error {
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	if opts.maxBytes > 0 && int64(len(buf)) > opts.maxBytes {
+		return errors.Wrapf(ErrTooLarge, "%v is larger than %d bytes", h, opts.maxBytes)
+	}
+
+	if b.RejectEmpty && len(buf) == 0 {
+		return errors.Wrapf(ErrEmptyBlob, "%v", h)
+	}
+
+	if hash != nil {
+		if id := hex.EncodeToString(hash.Sum(nil)); id != h.Name {
+			return errors.Wrapf(ErrHashMismatch, "%v has hash %v", h, id)
+		}
+	}
+
+	if opts.stored != nil {
+		*opts.stored = int64(len(buf))
+	}
+
+	p := b.small
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	unpacked := b.existsUnpacked(h)
+	if _, ok := p.entries[h]; ok || unpacked {
+		switch {
+		case b.OnExist == OnExistSkip && !opts.overwrite:
+			debug.Log("%v already exists, skipping", h)
+			return nil
+		case b.OnExist != OnExistOverwrite && !opts.overwrite:
+			return errors.Errorf("Save(): file %v already exists", h)
+		}
+	}
+
+	if p.current == "" || p.sizes[p.current]+int64(len(buf)) > smallContainerSize {
+		p.current = restic.NewRandomID().String()
+	}
+
+	e := packedEntry{
+		Type:      h.Type,
+		Name:      h.Name,
+		Container: p.current,
+		Offset:    p.sizes[p.current],
+		Length:    int64(len(buf)),
+	}
+
+	if err := b.appendSync(filepath.Join(b.packedDir(), e.Container), buf); err != nil {
+		return err
+	}
+
+	if err := b.appendIndex(e); err != nil {
+		return err
+	}
+
+	p.apply(e)
+	debug.Log("packed %v into %v at %d", h, e.Container, e.Offset)
+
+	if unpacked {
+		return b.removeUnpacked(h)
+	}
+	return nil
+}
+
+// removeUnpacked removes the file stored on its own for h after it was
+// replaced by a packed file, together with the sidecars which only apply to
+// it. The metadata is kept.
+func (b *Local) removeUnpacked(h restic.Handle) error {
+	fn, _, err := b.locate(h)
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	if !b.NoReadOnly {
+		if err = b.FS.Chmod(fn, 0666); err != nil {
+			return errors.Wrap(err, "Chmod")
+		}
+	}
+
+	if err = b.FS.Remove(fn); err != nil {
+		return errors.Wrap(err, "Remove")
+	}
+
+	b.removeCRC(h)
+	if h.Type == restic.DataFile {
+		b.removeOffsets(h)
+		b.removeParity(h)
+	}
+	return nil
+}
+
+// existsUnpacked returns true if h is stored in a file of its own.
+func (b *Local) existsUnpacked(h restic.Handle) bool {
+	_, _, err := b.locate(h)
+	return err == nil
+}
+
+// appendSync appends buf to the file fn and syncs it.
+func (b *Local) appendSync(fn string, buf []byte) error {
+	f, err := b.FS.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, backend.Modes.File)
+	if err != nil {
+		return errors.Wrap(err, "OpenFile")
+	}
+
+	if _, err = f.Write(buf); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Write")
+	}
+
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Sync")
+	}
+
+	return errors.Wrap(f.Close(), "Close")
+}
+
+// appendIndex records e in the index. The data must have been written
+// before, so that the index never refers to missing data.
+func (b *Local) appendIndex(e packedEntry) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	return b.appendSync(filepath.Join(b.packedDir(), smallIndexFile), append(buf, '\n'))
+}
+
+// loadPacked returns a reader for the packed file e like Load.
+func (b *Local) loadPacked(e packedEntry, length int, offset int64) (io.ReadCloser, error) {
+	f, err := b.FS.Open(filepath.Join(b.packedDir(), e.Container))
+	if err != nil {
+		return nil, errors.Wrap(err, "Open")
+	}
+
+	n := e.Length - offset
+	if n < 0 {
+		n = 0
+		offset = e.Length
+	}
+	if length > 0 && int64(length) < n {
+		n = int64(length)
+	}
+
+	if _, err = f.Seek(e.Offset+offset, 0); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "Seek")
+	}
+
+	return backend.LimitReadCloser(f, n), nil
+}
+
+// removePacked marks the packed file h as removed.
+func (b *Local) removePacked(h restic.Handle) error {
+	p := b.small
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if _, ok := p.entries[h]; !ok {
+		return errors.Wrapf(&os.PathError{Op: "Remove", Path: h.String(), Err: os.ErrNotExist}, "Remove")
+	}
+
+	e := packedEntry{Type: h.Type, Name: h.Name, Removed: true}
+	if err := b.appendIndex(e); err != nil {
+		return err
+	}
+
+	p.apply(e)
+	return nil
+}
+
+// listPacked returns the names of the packed files of type t.
+func (b *Local) listPacked(t restic.FileType) []string {
+	p := b.small
+	if p == nil {
+		return nil
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	var names []string
+	for h := range p.entries {
+		if h.Type == t {
+			names = append(names, h.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// packedShards returns the names of the packed files of type t grouped by
+// the data shard they would be stored in, i.e. their first two characters.
+func (b *Local) packedShards(t restic.FileType) map[string][]string {
+	shards := make(map[string][]string)
+	for _, name := range b.listPacked(t) {
+		if len(name) >= 2 {
+			shards[name[:2]] = append(shards[name[:2]], name)
+		}
+	}
+	return shards
+}
+
+// packedFileInfo describes a packed file like the os.FileInfo of a file
+// stored on its own. The modification time is that of its container.
+type packedFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi packedFileInfo) Name() string       { return fi.name }
+func (fi packedFileInfo) Size() int64        { return fi.size }
+func (fi packedFileInfo) Mode() os.FileMode  { return backend.Modes.File }
+func (fi packedFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi packedFileInfo) IsDir() bool        { return false }
+func (fi packedFileInfo) Sys() interface{}   { return nil }
+
+// packedFileInfos returns the packed files of type t as os.FileInfo, with
+// the names encoded like those of the files stored on their own.
+func (b *Local) packedFileInfos(t restic.FileType) ([]os.FileInfo, error) {
+	p := b.small
+	if p == nil {
+		return nil, nil
+	}
+
+	var entries []packedEntry
+	p.m.Lock()
+	for _, h := range sortedPackedHandles(p.entries) {
+		if h.Type == t {
+			entries = append(entries, p.entries[h])
+		}
+	}
+	p.m.Unlock()
+
+	modTimes := make(map[string]time.Time)
+	fileInfos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		modTime, ok := modTimes[e.Container]
+		if !ok {
+			fi, err := b.FS.Stat(filepath.Join(b.packedDir(), e.Container))
+			if err != nil {
+				return nil, errors.Wrap(err, "Stat")
+			}
+			modTime = fi.ModTime()
+			modTimes[e.Container] = modTime
+		}
+
+		fileInfos = append(fileInfos, packedFileInfo{
+			name:    b.naming().Encode(e.Name),
+			size:    e.Length,
+			modTime: modTime,
+		})
+	}
+
+	return fileInfos, nil
+}
+
+// contains returns true if s is in list.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CompactSmallBlobs copies the packed files from containers which hold
+// removed files to a new container, rewrites the index and removes the old
+// containers, as well as containers not referenced by the index, e.g. left
+// behind by a crash. It returns the number of bytes reclaimed. Loads of
+// packed files must not run concurrently on systems which do not allow
+// removing open files.
+func (b *Local) CompactSmallBlobs() (reclaimed int64, err error) {
+	debug.Log("CompactSmallBlobs")
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	p := b.small
+	if p == nil {
+		return 0, nil
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	dir := b.packedDir()
+	fileInfos, err := readdir(b.FS, dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var obsolete []os.FileInfo
+	for _, fi := range fileInfos {
+		name := fi.Name()
+		if name == smallIndexFile || !isFile(fi) {
+			continue
+		}
+		if _, ok := p.sizes[name]; !ok || p.live[name] < fi.Size() {
+			obsolete = append(obsolete, fi)
+		}
+	}
+
+	if len(obsolete) == 0 {
+		return 0, nil
+	}
+
+	remove := make(map[string]bool)
+	for _, fi := range obsolete {
+		remove[fi.Name()] = true
+	}
+
+	// copy the live files of the obsolete containers to a new container
+	var moved []packedEntry
+	var data bytes.Buffer
+	target := restic.NewRandomID().String()
+	for _, h := range sortedPackedHandles(p.entries) {
+		e := p.entries[h]
+		if !remove[e.Container] {
+			continue
+		}
+
+		rd, err := b.loadPacked(e, 0, 0)
+		if err != nil {
+			return 0, err
+		}
+		offset := int64(data.Len())
+		_, err = io.Copy(&data, rd)
+		rd.Close()
+		if err != nil {
+			return 0, errors.Wrap(err, "Read")
+		}
+
+		e.Container, e.Offset = target, offset
+		moved = append(moved, e)
+	}
+
+	if len(moved) > 0 {
+		if err = b.appendSync(filepath.Join(dir, target), data.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+
+	// write the new index, with the moved files at their new location
+	np := &smallPack{
+		threshold: p.threshold,
+		entries:   make(map[restic.Handle]packedEntry),
+		sizes:     make(map[string]int64),
+		live:      make(map[string]int64),
+	}
+	var index bytes.Buffer
+	for _, h := range sortedPackedHandles(p.entries) {
+		e := p.entries[h]
+		if remove[e.Container] {
+			continue
+		}
+		np.apply(e)
+	}
+	for _, e := range moved {
+		np.apply(e)
+	}
+	for _, h := range sortedPackedHandles(np.entries) {
+		buf, err := json.Marshal(np.entries[h])
+		if err != nil {
+			return 0, errors.Wrap(err, "Marshal")
+		}
+		index.Write(append(buf, '\n'))
+	}
+
+	tmpfile, _, err := b.copyToTempfile(&index)
+	if err != nil {
+		return 0, err
+	}
+	if err = b.FS.Rename(tmpfile, filepath.Join(dir, smallIndexFile)); err != nil {
+		b.FS.Remove(tmpfile)
+		return 0, errors.Wrap(err, "Rename")
+	}
+
+	for _, fi := range obsolete {
+		if err := b.FS.Remove(filepath.Join(dir, fi.Name())); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return reclaimed, errors.Wrap(err, "Remove")
+		}
+		reclaimed += fi.Size()
+	}
+	reclaimed -= int64(data.Len())
+
+	p.entries, p.sizes, p.live = np.entries, np.sizes, np.live
+	p.current = ""
+
+	debug.Log("moved %d packed files, reclaimed %d bytes", len(moved), reclaimed)
+	return reclaimed, nil
+}
+
+// byTypeAndName sorts handles by type and name.
+type byTypeAndName []restic.Handle
+
+func (s byTypeAndName) Len() int { return len(s) }
+func (s byTypeAndName) Less(i, j int) bool {
+	if s[i].Type != s[j].Type {
+		return s[i].Type < s[j].Type
+	}
+	return s[i].Name < s[j].Name
+}
+func (s byTypeAndName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// sortedPackedHandles returns the handles of entries sorted by type and
+// name.
+func sortedPackedHandles(entries map[restic.Handle]packedEntry) []restic.Handle {
+	handles := make([]restic.Handle, 0, len(entries))
+	for h := range entries {
+		handles = append(handles, h)
+	}
+	sort.Sort(byTypeAndName(handles))
+	return handles
+}
@@ -0,0 +1,361 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"sort"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func listNamesSorted(be *Local, t restic.FileType) []string {
+	var names []string
+	for name := range be.List(t, nil) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPackSmallBlobs(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	OK(t, be.PackSmallBlobs(100))
+
+	var small []restic.Handle
+	contents := make(map[restic.Handle][]byte)
+	for i := 0; i < 10; i++ {
+		h, data := saveData(t, be, i, 10+i*5)
+		small = append(small, h)
+		contents[h] = data
+	}
+	large, data := saveData(t, be, 23, 100)
+	contents[large] = data
+
+	for _, h := range small {
+		_, err := os.Stat(filename(be.Path, h.Type, h.Name))
+		Assert(t, os.IsNotExist(err), "small file %v stored on its own", h)
+	}
+	_, err := os.Stat(filename(be.Path, large.Type, large.Name))
+	OK(t, err)
+
+	// saving a packed file again fails like for other files
+	Assert(t, be.Save(small[0], bytes.NewReader(contents[small[0]])) != nil,
+		"saving packed file twice succeeded")
+
+	check := func(be *Local) {
+		var want []string
+		for h, data := range contents {
+			want = append(want, h.Name)
+			Equals(t, data, load(t, be, h, 0, 0))
+			Equals(t, data[5:8], load(t, be, h, 3, 5))
+
+			fi, err := be.Stat(h)
+			OK(t, err)
+			Equals(t, int64(len(data)), fi.Size)
+
+			ok, err := be.Test(h)
+			OK(t, err)
+			Assert(t, ok, "%v not found", h)
+		}
+		sort.Strings(want)
+		Equals(t, want, listNamesSorted(be, restic.DataFile))
+	}
+	check(be)
+
+	// the packed files are found after opening the repository again
+	be2, err := Open(be.Config)
+	OK(t, err)
+	check(be2)
+
+	for _, h := range small[:5] {
+		OK(t, be.Remove(h))
+		delete(contents, h)
+
+		ok, err := be.Test(h)
+		OK(t, err)
+		Assert(t, !ok, "%v found after Remove", h)
+	}
+	Assert(t, be.Remove(small[0]) != nil, "removing a removed file succeeded")
+	check(be)
+
+	be3, err := Open(be.Config)
+	OK(t, err)
+	check(be3)
+}
+
+func TestCompactSmallBlobs(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	OK(t, be.PackSmallBlobs(100))
+
+	var handles []restic.Handle
+	contents := make(map[restic.Handle][]byte)
+	for i := 0; i < 10; i++ {
+		h, data := saveData(t, be, i, 50)
+		handles = append(handles, h)
+		contents[h] = data
+	}
+
+	reclaimed, err := be.CompactSmallBlobs()
+	OK(t, err)
+	Equals(t, int64(0), reclaimed)
+
+	for _, h := range handles[:4] {
+		OK(t, be.Remove(h))
+		delete(contents, h)
+	}
+
+	// a container left behind by a crash is removed as well
+	dir := filepath.Join(be.Path, "packed")
+	OK(t, ioutil.WriteFile(filepath.Join(dir, restic.NewRandomID().String()), make([]byte, 30), 0600))
+
+	reclaimed, err = be.CompactSmallBlobs()
+	OK(t, err)
+	Equals(t, int64(4*50+30), reclaimed)
+
+	names, err := readdirnames(be.FS, dir)
+	OK(t, err)
+	Equals(t, 2, len(names))
+
+	for _, be := range []*Local{be, func() *Local {
+		be2, err := Open(be.Config)
+		OK(t, err)
+		return be2
+	}()} {
+		for h, data := range contents {
+			Equals(t, data, load(t, be, h, 0, 0))
+		}
+		Equals(t, 6, len(listNamesSorted(be, restic.DataFile)))
+	}
+
+	// new files are packed after compaction
+	h, data := saveData(t, be, 42, 20)
+	Equals(t, data, load(t, be, h, 0, 0))
+}
+
+func TestPackedMethods(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	OK(t, be.PackSmallBlobs(100))
+
+	var handles []restic.Handle
+	contents := make(map[restic.Handle][]byte)
+	for i := 0; i < 5; i++ {
+		h, data := saveData(t, be, i, 20+i)
+		handles = append(handles, h)
+		contents[h] = data
+	}
+	large, _ := saveData(t, be, 23, 200)
+	var want []string
+	for _, h := range append(handles, large) {
+		want = append(want, h.Name)
+	}
+	sort.Strings(want)
+
+	for _, h := range handles {
+		data := contents[h]
+
+		rd, err := be.LoadStrict(h, 5, 3)
+		OK(t, err)
+		buf, err := ioutil.ReadAll(rd)
+		OK(t, err)
+		OK(t, rd.Close())
+		Equals(t, data[3:8], buf)
+
+		_, err = be.LoadStrict(h, len(data), 1)
+		Assert(t, errors.Cause(err) == ErrRangeNotSatisfiable,
+			"expected ErrRangeNotSatisfiable, got %v", err)
+
+		rd, _, changed, err := be.LoadIfChanged(h, time.Time{})
+		OK(t, err)
+		Assert(t, changed, "%v not reported as changed", h)
+		OK(t, rd.Close())
+
+		OK(t, be.Verify(h))
+	}
+
+	found, err := be.TestMany(handles)
+	OK(t, err)
+	for _, h := range handles {
+		Assert(t, found[h], "%v not found by TestMany", h)
+	}
+
+	var names []string
+	for name := range be.ListFrom(restic.DataFile, "", nil) {
+		names = append(names, name)
+	}
+	Equals(t, want, names)
+
+	names, _, err = be.ListPage(restic.DataFile, "", 100)
+	OK(t, err)
+	Equals(t, want, names)
+
+	listing, err := be.Snapshot()
+	OK(t, err)
+	Equals(t, want, listing.Names(restic.DataFile))
+
+	be.ListDirDelay = time.Millisecond
+	Equals(t, want, listNamesSorted(be, restic.DataFile))
+	be.ListDirDelay = 0
+
+	// a damaged packed file is detected by Verify
+	e, _ := be.packed(handles[0])
+	fn := filepath.Join(be.packedDir(), e.Container)
+	corruptAt(t, fn, int(e.Offset))
+	err = be.Verify(handles[0])
+	Assert(t, errors.Cause(err) == ErrHashMismatch, "expected ErrHashMismatch, got %v", err)
+}
+
+func TestReplacePacked(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	OK(t, be.PackSmallBlobs(100))
+
+	h, data := saveData(t, be, 5, 50)
+
+	// the file is stored on its own when packing is disabled
+	OK(t, be.PackSmallBlobs(0))
+	OK(t, be.Replace(h, bytes.NewReader(data)))
+	_, ok := be.packed(h)
+	Assert(t, !ok, "%v still packed after Replace", h)
+	_, err := os.Stat(filename(be.Path, h.Type, h.Name))
+	OK(t, err)
+	Equals(t, data, load(t, be, h, 0, 0))
+
+	// and packed again when it is replaced with packing enabled
+	OK(t, be.PackSmallBlobs(100))
+	OK(t, be.Replace(h, bytes.NewReader(data)))
+	_, ok = be.packed(h)
+	Assert(t, ok, "%v not packed after Replace", h)
+	_, err = os.Stat(filename(be.Path, h.Type, h.Name))
+	Assert(t, os.IsNotExist(err), "file stored on its own was not removed")
+	Equals(t, data, load(t, be, h, 0, 0))
+	Equals(t, []string{h.Name}, listNamesSorted(be, restic.DataFile))
+
+	be2, err := Open(be.Config)
+	OK(t, err)
+	Equals(t, data, load(t, be2, h, 0, 0))
+}
+
+func TestPackedListings(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	OK(t, be.PackSmallBlobs(50))
+
+	empty, cleanupEmpty := TestBackend(t)
+	defer cleanupEmpty()
+	emptyDigest, err := empty.StateDigest(nil)
+	OK(t, err)
+
+	h, data := saveData(t, be, 5, 4)
+	_, ok := be.packed(h)
+	Assert(t, ok, "%v was not packed", h)
+
+	onlyHere, onlyThere, both, err := be.Diff(empty, restic.DataFile, nil)
+	OK(t, err)
+	Equals(t, []string{h.Name}, onlyHere)
+	Equals(t, 0, len(onlyThere))
+	Equals(t, 0, len(both))
+
+	// the digest equals that of a repository storing the file on its own
+	digest, err := be.StateDigest(nil)
+	OK(t, err)
+	Assert(t, digest != emptyDigest, "packed file missing from the digest")
+	OK(t, empty.Save(h, bytes.NewReader(data)))
+	unpackedDigest, err := empty.StateDigest(nil)
+	OK(t, err)
+	Equals(t, unpackedDigest, digest)
+
+	var buf bytes.Buffer
+	OK(t, be.ExportListing(&buf, nil))
+	var entries []ListingEntry
+	OK(t, json.Unmarshal(buf.Bytes(), &entries))
+	found := false
+	for _, e := range entries {
+		if e.Type == restic.DataFile && e.Name == h.Name {
+			found = true
+			Equals(t, int64(len(data)), e.Size)
+		}
+	}
+	Assert(t, found, "packed file missing from the exported listing")
+
+	ch, stats := be.ListStats(restic.DataFile, nil)
+	var names []string
+	for name := range ch {
+		names = append(names, name)
+	}
+	Equals(t, []string{h.Name}, names)
+	Equals(t, 1, stats().Count)
+	Equals(t, int64(len(data)), stats().TotalSize)
+}
+
+func TestFindCorruptPacked(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	OK(t, be.PackSmallBlobs(100))
+
+	var handles []restic.Handle
+	for i := 0; i < 5; i++ {
+		h, _ := saveData(t, be, i, 20)
+		handles = append(handles, h)
+	}
+
+	e, ok := be.packed(handles[2])
+	Assert(t, ok, "%v was not packed", handles[2])
+	corruptAt(t, filepath.Join(be.packedDir(), e.Container), int(e.Offset)+3)
+
+	corrupt, errs := be.FindCorrupt(nil, nil)
+	var found []restic.Handle
+	for corrupt != nil || errs != nil {
+		select {
+		case h, ok := <-corrupt:
+			if !ok {
+				corrupt = nil
+				continue
+			}
+			found = append(found, h)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			t.Errorf("FindCorrupt returned error %v", err)
+		}
+	}
+	Equals(t, []restic.Handle{handles[2]}, found)
+}
+
+func TestDeleteTypesPacked(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	OK(t, be.PackSmallBlobs(50))
+
+	h := restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash([]byte("small")).String()}
+	OK(t, be.Save(h, bytes.NewReader([]byte("small"))))
+	_, ok := be.packed(h)
+	Assert(t, ok, "small file was not packed")
+
+	other, data := saveData(t, be, 23, 20)
+	_, ok = be.packed(other)
+	Assert(t, ok, "small file was not packed")
+
+	OK(t, be.DeleteTypes([]restic.FileType{restic.SnapshotFile}, false))
+
+	_, ok = be.packed(h)
+	Assert(t, !ok, "packed file was not removed")
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "packed file was not removed")
+	Equals(t, 0, len(listNamesSorted(be, restic.SnapshotFile)))
+
+	// packed files of other types are kept
+	Equals(t, data, load(t, be, other, 0, 0))
+}
@@ -0,0 +1,69 @@
+package local
+
+import (
+	"path/filepath"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// Snapshot lists all files in the repository except the config and returns
+// the result, which does not change when files are saved or removed later.
+// Each directory is read in a single pass, so its listing reflects one state
+// of the directory. Different directories are read one after another, a
+// file saved to a shard which has already been read is therefore missing
+// from the listing. In contrast to List, errors are returned.
+func (b *Local) Snapshot() (restic.Listing, error) {
+	debug.Log("Snapshot %v", b.Path)
+	all := make(map[restic.FileType][]string)
+
+	for _, t := range fileTypes {
+		if t == restic.ConfigFile {
+			continue
+		}
+
+		dir := dirname(b.Path, t, "")
+
+		var names []string
+		var err error
+		switch t {
+		case restic.DataFile:
+			names, err = b.snapshotData(dir)
+		case restic.SnapshotFile:
+			names, err = b.listSnapshots(b.FS, dir)
+		default:
+			names, err = listDir(b.FS, dir)
+		}
+		if err != nil {
+			return restic.Listing{}, errors.Wrapf(err, "list %v", t)
+		}
+
+		all[t] = append(b.decodeNames(names), b.listPacked(t)...)
+	}
+
+	return restic.NewListing(all), nil
+}
+
+// snapshotData returns the names of all files in the subdirectories of dir
+// and their sub-shards.
+func (b *Local) snapshotData(dir string) (names []string, err error) {
+	shards, err := readdir(b.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range shards {
+		if !fi.IsDir() {
+			continue
+		}
+
+		files, err := listShard(b.FS, filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, files...)
+	}
+
+	return names, nil
+}
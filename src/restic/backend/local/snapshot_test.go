@@ -0,0 +1,83 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"sync"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func TestSnapshot(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	lock := restic.Handle{Type: restic.LockFile, Name: "lock"}
+	OK(t, be.Save(lock, bytes.NewReader([]byte("lock"))))
+
+	var (
+		m         sync.Mutex
+		started   = make(map[string]bool)
+		completed = make(map[string]bool)
+		wg        sync.WaitGroup
+		ready     = make(chan struct{})
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			data := Random(i, 100)
+			h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+			m.Lock()
+			started[h.Name] = true
+			m.Unlock()
+
+			OK(t, be.Save(h, bytes.NewReader(data)))
+
+			m.Lock()
+			completed[h.Name] = true
+			m.Unlock()
+
+			if i == 50 {
+				close(ready)
+			}
+		}
+	}()
+
+	<-ready
+	m.Lock()
+	before := make(map[string]bool)
+	for name := range completed {
+		before[name] = true
+	}
+	m.Unlock()
+
+	l, err := be.Snapshot()
+	OK(t, err)
+	wg.Wait()
+
+	// all files saved completely before the snapshot are listed, and only
+	// files which were saved at some point
+	names := l.Names(restic.DataFile)
+	listed := make(map[string]bool)
+	for _, name := range names {
+		listed[name] = true
+		Assert(t, started[name], "unknown file %v listed", name)
+	}
+	for name := range before {
+		Assert(t, listed[name], "file %v saved before the snapshot not listed", name)
+	}
+
+	Equals(t, []string{"lock"}, l.Names(restic.LockFile))
+	Assert(t, l.Has(lock), "lock not found in listing")
+	Equals(t, 0, len(l.Names(restic.SnapshotFile)))
+
+	// the listing is not changed by later modifications
+	OK(t, be.Remove(lock))
+	Assert(t, l.Has(lock), "listing changed after Remove")
+	Equals(t, len(names), len(l.Names(restic.DataFile)))
+}
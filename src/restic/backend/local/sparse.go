@@ -0,0 +1,87 @@
+package local
+
+import (
+	"io"
+
+	"restic/errors"
+)
+
+// sparseBlockSize is the size of the runs of zeroes which are skipped by
+// sparseWriter.
+const sparseBlockSize = 4096
+
+// sparseWriter writes to a file, but seeks over blocks containing only
+// zeroes instead of writing them. finish must be called after the last
+// write.
+type sparseWriter struct {
+	f File
+
+	// hole is the number of zero bytes which have been skipped but not yet
+	// seeked over
+	hole int64
+}
+
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *sparseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := sparseBlockSize
+		if n > len(p) {
+			n = len(p)
+		}
+
+		if n == sparseBlockSize && isZero(p[:n]) {
+			w.hole += int64(n)
+			written += n
+			p = p[n:]
+			continue
+		}
+
+		if err := w.skipHole(); err != nil {
+			return written, err
+		}
+
+		m, err := w.f.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// skipHole moves the file offset past the skipped zeroes.
+func (w *sparseWriter) skipHole() error {
+	if w.hole == 0 {
+		return nil
+	}
+
+	_, err := w.f.Seek(w.hole, io.SeekCurrent)
+	w.hole = 0
+	return errors.Wrap(err, "Seek")
+}
+
+// finish extends the file if it ends in a hole, by writing the last zero.
+func (w *sparseWriter) finish() error {
+	if w.hole == 0 {
+		return nil
+	}
+
+	w.hole--
+	if err := w.skipHole(); err != nil {
+		return err
+	}
+
+	_, err := w.f.Write([]byte{0})
+	return errors.Wrap(err, "Write")
+}
@@ -0,0 +1,67 @@
+// +build !windows
+
+package local
+
+import (
+	"bytes"
+	"os"
+	"restic"
+	"syscall"
+	"testing"
+
+	. "restic/test"
+)
+
+// allocated returns the number of bytes allocated on disk for the file.
+func allocated(t testing.TB, filename string) int64 {
+	fi, err := os.Stat(filename)
+	OK(t, err)
+	return fi.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+func TestSparse(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	zeroes := make([]byte, 1<<20)
+	data := append(append(append([]byte{}, zeroes...), Random(23, 100)...), zeroes...)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	dense := restic.Handle{Type: restic.LockFile, Name: "dense"}
+	OK(t, be.Save(dense, bytes.NewReader(data)))
+	if allocated(t, filename(be.Path, dense.Type, dense.Name)) < int64(len(data)) {
+		t.Skip("file system does not allocate zeroes")
+	}
+
+	be.Sparse = true
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	fn := filename(be.Path, h.Type, h.Name)
+	fi, err := os.Stat(fn)
+	OK(t, err)
+	Equals(t, int64(len(data)), fi.Size())
+	Assert(t, allocated(t, fn) < int64(len(data))/2,
+		"file is not sparse, %d bytes allocated", allocated(t, fn))
+
+	Equals(t, data, load(t, be, h, 0, 0))
+	Equals(t, data[len(zeroes)-10:len(zeroes)+10], load(t, be, h, 20, int64(len(zeroes)-10)))
+	OK(t, be.Verify(h))
+}
+
+func TestSparseShortTail(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.Sparse = true
+
+	// zeroes shorter than a block and data ending in a hole
+	for _, data := range [][]byte{
+		{},
+		make([]byte, 10),
+		make([]byte, 3*sparseBlockSize),
+		append(Random(5, 5000), make([]byte, 2*sparseBlockSize+7)...),
+	} {
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		Equals(t, data, load(t, be, h, 0, 0))
+	}
+}
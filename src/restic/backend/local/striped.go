@@ -0,0 +1,189 @@
+package local
+
+import (
+	"encoding/hex"
+	"io"
+	"restic"
+	"strings"
+	"sync"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// StripedConfig holds the directories for a striped local backend.
+type StripedConfig struct {
+	Roots []string
+}
+
+// Striped is a backend which distributes data files across several local
+// directories, e.g. on different disks. All other files are stored in the
+// first directory.
+type Striped struct {
+	roots []*Local
+}
+
+var _ restic.Backend = &Striped{}
+
+// CreateStriped creates a new local repository in each of the directories.
+func CreateStriped(cfg StripedConfig) (*Striped, error) {
+	if len(cfg.Roots) == 0 {
+		return nil, errors.New("no roots configured")
+	}
+
+	be := &Striped{}
+	for i, dir := range cfg.Roots {
+		root, err := Create(Config{Path: dir})
+		if err != nil {
+			return nil, errors.Wrapf(err, "root %d (%v)", i, dir)
+		}
+		be.roots = append(be.roots, root)
+	}
+
+	return be, nil
+}
+
+// OpenStriped opens a striped repository, all directories must be available.
+func OpenStriped(cfg StripedConfig) (*Striped, error) {
+	if len(cfg.Roots) == 0 {
+		return nil, errors.New("no roots configured")
+	}
+
+	be := &Striped{}
+	for i, dir := range cfg.Roots {
+		root, err := Open(Config{Path: dir})
+		if err != nil {
+			return nil, errors.Wrapf(err, "root %d (%v)", i, dir)
+		}
+		be.roots = append(be.roots, root)
+	}
+
+	return be, nil
+}
+
+// rootIndex returns the index of the root the file for h is stored in.
+func (be *Striped) rootIndex(h restic.Handle) int {
+	if h.Type != restic.DataFile || len(h.Name) < 2 {
+		return 0
+	}
+
+	prefix, err := hex.DecodeString(h.Name[:2])
+	if err != nil {
+		return 0
+	}
+
+	return int(prefix[0]) % len(be.roots)
+}
+
+// root returns the backend responsible for h and a function which annotates
+// errors with the root.
+func (be *Striped) root(h restic.Handle) (*Local, func(error) error) {
+	i := be.rootIndex(h)
+	root := be.roots[i]
+	return root, func(err error) error {
+		if err == nil {
+			return nil
+		}
+		return errors.Wrapf(err, "root %d (%v)", i, root.Path)
+	}
+}
+
+// Location returns the list of directories.
+func (be *Striped) Location() string {
+	var dirs []string
+	for _, root := range be.roots {
+		dirs = append(dirs, root.Location())
+	}
+	return strings.Join(dirs, ",")
+}
+
+// Test returns true if a blob of the given type and name exists in the backend.
+func (be *Striped) Test(h restic.Handle) (bool, error) {
+	root, wrap := be.root(h)
+	ok, err := root.Test(h)
+	return ok, wrap(err)
+}
+
+// Remove removes the blob with the given name and type.
+func (be *Striped) Remove(h restic.Handle) error {
+	root, wrap := be.root(h)
+	return wrap(root.Remove(h))
+}
+
+// Save stores data in the backend at the handle.
+func (be *Striped) Save(h restic.Handle, rd io.Reader) error {
+	root, wrap := be.root(h)
+	return wrap(root.Save(h, rd))
+}
+
+// Load returns a reader that yields the contents of the file at h at the
+// given offset. If length is nonzero, only a portion of the file is
+// returned. rd must be closed after use.
+func (be *Striped) Load(h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	root, wrap := be.root(h)
+	rd, err := root.Load(h, length, offset)
+	return rd, wrap(err)
+}
+
+// Stat returns information about a blob.
+func (be *Striped) Stat(h restic.Handle) (restic.FileInfo, error) {
+	root, wrap := be.root(h)
+	fi, err := root.Stat(h)
+	return fi, wrap(err)
+}
+
+// List returns a channel that yields all names of blobs of type t. For data
+// files, the names from all roots are returned.
+func (be *Striped) List(t restic.FileType, done <-chan struct{}) <-chan string {
+	if t != restic.DataFile {
+		return be.roots[0].List(t, done)
+	}
+
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	for _, root := range be.roots {
+		wg.Add(1)
+		go func(root *Local) {
+			defer wg.Done()
+
+			rootDone := make(chan struct{})
+			defer close(rootDone)
+
+			for name := range root.List(t, rootDone) {
+				select {
+				case ch <- name:
+				case <-done:
+					return
+				}
+			}
+		}(root)
+	}
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Delete removes the repository in all directories.
+func (be *Striped) Delete() error {
+	debug.Log("Delete()")
+	for i, root := range be.roots {
+		if err := root.Delete(); err != nil {
+			return errors.Wrapf(err, "root %d (%v)", i, root.Path)
+		}
+	}
+	return nil
+}
+
+// Close closes all roots.
+func (be *Striped) Close() error {
+	for _, root := range be.roots {
+		if err := root.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package local_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"strconv"
+	"strings"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func testStriped(t testing.TB, n int) (*local.Striped, []string, func()) {
+	tempdir, err := ioutil.TempDir(TestTempDir, "restic-local-test-")
+	OK(t, err)
+
+	var roots []string
+	for i := 0; i < n; i++ {
+		roots = append(roots, filepath.Join(tempdir, "root"+strconv.Itoa(i)))
+	}
+
+	be, err := local.CreateStriped(local.StripedConfig{Roots: roots})
+	OK(t, err)
+
+	return be, roots, func() { RemoveAll(t, tempdir) }
+}
+
+func TestStripedPlacement(t *testing.T) {
+	be, roots, cleanup := testStriped(t, 3)
+	defer cleanup()
+
+	handles := saveRandom(t, be, restic.DataFile, 30)
+	for _, h := range handles {
+		prefix, err := strconv.ParseUint(h.Name[:2], 16, 8)
+		OK(t, err)
+		root := roots[int(prefix)%len(roots)]
+
+		_, err = os.Stat(filepath.Join(root, "data", h.Name[:2], h.Name))
+		OK(t, err)
+
+		for _, other := range roots {
+			if other == root {
+				continue
+			}
+			_, err = os.Stat(filepath.Join(other, "data", h.Name[:2], h.Name))
+			Assert(t, os.IsNotExist(err), "file %v also found in root %v", h, other)
+		}
+
+		buf, err := backend.LoadAll(be, h)
+		OK(t, err)
+		Equals(t, h.Name, restic.Hash(buf).String())
+	}
+
+	var listed []string
+	for name := range be.List(restic.DataFile, nil) {
+		listed = append(listed, name)
+	}
+	Equals(t, len(handles), len(listed))
+
+	// other files are stored in the first root
+	h := restic.Handle{Type: restic.SnapshotFile, Name: "ffff"}
+	OK(t, be.Save(h, strings.NewReader("foo")))
+	_, err := os.Stat(filepath.Join(roots[0], "snapshots", h.Name))
+	OK(t, err)
+}
+
+func TestStripedOfflineRoot(t *testing.T) {
+	be, roots, cleanup := testStriped(t, 2)
+	defer cleanup()
+
+	// find data which is stored in the second root
+	data := Random(23, 100)
+	for restic.Hash(data)[0]%2 != 1 {
+		data = append(data, 'x')
+	}
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	OK(t, os.RemoveAll(roots[1]))
+	err := be.Save(h, bytes.NewReader(data))
+	Assert(t, err != nil, "Save to offline root did not return an error")
+	Assert(t, strings.Contains(err.Error(), roots[1]),
+		"error %q does not name the offline root", err)
+
+	_, err = local.OpenStriped(local.StripedConfig{Roots: roots})
+	Assert(t, err != nil && strings.Contains(err.Error(), roots[1]),
+		"Open did not report the offline root: %v", err)
+}
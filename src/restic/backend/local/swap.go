@@ -0,0 +1,211 @@
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// swapIntent is written before Swap renames the first file, so that
+// RecoverSwaps can finish or undo an interrupted swap.
+type swapIntent struct {
+	X, Y restic.Handle
+}
+
+// syncDir flushes the directory entries of dir to disk, which makes renames
+// within it durable.
+func syncDir(fsys FS, dir string) error {
+	if !dirSyncSupported {
+		return nil
+	}
+
+	f, err := fsys.Open(dir)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+
+	err = f.Sync()
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return errors.Wrap(err, "Sync")
+}
+
+// renameSync renames oldpath to newpath and syncs the directories of both.
+func (b *Local) renameSync(oldpath, newpath string) error {
+	if err := b.FS.Rename(oldpath, newpath); err != nil {
+		return errors.Wrap(err, "Rename")
+	}
+
+	if err := syncDir(b.FS, filepath.Dir(newpath)); err != nil {
+		return err
+	}
+
+	if filepath.Dir(oldpath) != filepath.Dir(newpath) {
+		return syncDir(b.FS, filepath.Dir(oldpath))
+	}
+	return nil
+}
+
+// Swap exchanges the files at x and y, which must both exist. Only files
+// which are not content-addressed (config, keys and locks) can be swapped.
+// The file at x is first moved to Paths.Temp, then y is moved to x and the
+// first file to y, the directories are synced after each step. An intent
+// recorded beforehand allows RecoverSwaps to finish or undo a swap
+// interrupted by a crash, so neither file is lost.
+func (b *Local) Swap(x, y restic.Handle) error {
+	debug.Log("Swap %v, %v", x, y)
+	for _, h := range []restic.Handle{x, y} {
+		if err := h.Valid(); err != nil {
+			return err
+		}
+
+		if err := b.checkPermitted(h.Type); err != nil {
+			return err
+		}
+
+		if isContentAddressed(h.Type) {
+			return errors.Errorf("files of type %v cannot be swapped", h.Type)
+		}
+	}
+
+	if x == y {
+		return errors.Errorf("cannot swap %v with itself", x)
+	}
+
+	if err := b.checkRecoverMode(); err != nil {
+		return err
+	}
+
+	fx, fy := b.filename(x.Type, x.Name), b.filename(y.Type, y.Name)
+	for _, fn := range []string{fx, fy} {
+		fi, err := b.FS.Lstat(fn)
+		if err != nil {
+			return errors.Wrap(err, "Lstat")
+		}
+		if !isFile(fi) {
+			return errors.Errorf("%v is not a regular file", fn)
+		}
+	}
+
+	intent, err := b.writeSwapIntent(swapIntent{X: x, Y: y})
+	if err != nil {
+		return err
+	}
+	tmp := strings.TrimSuffix(intent, ".json")
+
+	if err = b.renameSync(fx, tmp); err != nil {
+		return err
+	}
+
+	if err = b.renameSync(fy, fx); err != nil {
+		return err
+	}
+
+	if err = b.renameSync(tmp, fy); err != nil {
+		return err
+	}
+
+	return errors.Wrap(b.FS.Remove(intent), "Remove")
+}
+
+// writeSwapIntent stores the intent in Paths.Temp and returns the file name.
+func (b *Local) writeSwapIntent(intent swapIntent) (string, error) {
+	buf, err := json.Marshal(intent)
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal")
+	}
+
+	tempdir := filepath.Join(b.Path, backend.Paths.Temp)
+	tmpfile, _, err := b.copyToTempfile(bytes.NewReader(buf))
+	if err != nil {
+		return "", err
+	}
+
+	fn := filepath.Join(tempdir, "swap-"+filepath.Base(tmpfile)+".json")
+	if err = b.renameSync(tmpfile, fn); err != nil {
+		b.FS.Remove(tmpfile)
+		return "", err
+	}
+
+	return fn, nil
+}
+
+// RecoverSwaps finishes or undoes swaps interrupted by a crash and returns
+// the number of swaps it found. A swap is undone if only the first file has
+// been moved to the temp dir, and finished if the second file has been
+// moved as well.
+func (b *Local) RecoverSwaps() (int, error) {
+	debug.Log("RecoverSwaps")
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	tempdir := filepath.Join(b.Path, backend.Paths.Temp)
+	names, err := listDir(b.FS, tempdir)
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, name := range names {
+		if !strings.HasPrefix(name, "swap-") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		if err = b.recoverSwap(filepath.Join(tempdir, name)); err != nil {
+			return recovered, err
+		}
+		recovered++
+	}
+
+	return recovered, nil
+}
+
+// recoverSwap completes the swap recorded in the intent file fn.
+func (b *Local) recoverSwap(fn string) error {
+	f, err := b.FS.Open(fn)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+
+	var intent swapIntent
+	err = json.NewDecoder(f).Decode(&intent)
+	f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Decode %v", fn)
+	}
+
+	tmp := strings.TrimSuffix(fn, ".json")
+	fx := b.filename(intent.X.Type, intent.X.Name)
+	fy := b.filename(intent.Y.Type, intent.Y.Name)
+
+	_, err = b.FS.Lstat(tmp)
+	switch {
+	case os.IsNotExist(errors.Cause(err)):
+		// the swap has not started or is complete
+	case err != nil:
+		return errors.Wrap(err, "Lstat")
+	default:
+		_, err = b.FS.Lstat(fx)
+		if os.IsNotExist(errors.Cause(err)) {
+			debug.Log("undoing swap of %v and %v", intent.X, intent.Y)
+			err = b.renameSync(tmp, fx)
+		} else {
+			debug.Log("finishing swap of %v and %v", intent.X, intent.Y)
+			err = b.renameSync(tmp, fy)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(b.FS.Remove(fn), "Remove")
+}
@@ -0,0 +1,100 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"strings"
+	"syscall"
+	"testing"
+
+	"restic/backend"
+	. "restic/test"
+)
+
+// crashAfterRenames returns a function for fakeFS.fail which lets n renames
+// succeed and fails all operations afterwards, as if the process crashed.
+func crashAfterRenames(n int) func(op, name string) error {
+	renames := 0
+	crashed := false
+	return func(op, name string) error {
+		if crashed {
+			return syscall.EIO
+		}
+		if op == "Rename" {
+			renames++
+			if renames > n {
+				crashed = true
+				return syscall.EIO
+			}
+		}
+		return nil
+	}
+}
+
+func TestSwap(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	x := restic.Handle{Type: restic.KeyFile, Name: "current"}
+	y := restic.Handle{Type: restic.KeyFile, Name: "previous"}
+	OK(t, be.Save(x, bytes.NewReader([]byte("new"))))
+	OK(t, be.Save(y, bytes.NewReader([]byte("old"))))
+
+	OK(t, be.Swap(x, y))
+	Equals(t, []byte("old"), load(t, be, x, 0, 0))
+	Equals(t, []byte("new"), load(t, be, y, 0, 0))
+
+	n, err := be.RecoverSwaps()
+	OK(t, err)
+	Equals(t, 0, n)
+
+	data := restic.Handle{Type: restic.DataFile, Name: restic.Hash([]byte("foo")).String()}
+	Assert(t, be.Swap(x, data) != nil, "data file was swapped")
+	Assert(t, be.Swap(x, x) != nil, "file was swapped with itself")
+	Assert(t, be.Swap(x, restic.Handle{Type: restic.LockFile, Name: "missing"}) != nil,
+		"missing file was swapped")
+}
+
+func TestSwapCrash(t *testing.T) {
+	// the renames are: intent, x to temp, y to x and temp to y
+	for renames := 0; renames < 4; renames++ {
+		be, cleanup := TestBackend(t)
+
+		x := restic.Handle{Type: restic.LockFile, Name: "x"}
+		y := restic.Handle{Type: restic.LockFile, Name: "y"}
+		OK(t, be.Save(x, bytes.NewReader([]byte("x"))))
+		OK(t, be.Save(y, bytes.NewReader([]byte("y"))))
+
+		be.FS = &fakeFS{FS: defaultFS, fail: crashAfterRenames(renames)}
+		Assert(t, be.Swap(x, y) != nil, "Swap did not fail after %d renames", renames)
+
+		be.FS = defaultFS
+		n, err := be.RecoverSwaps()
+		OK(t, err)
+		if renames == 0 {
+			Equals(t, 0, n)
+		} else {
+			Equals(t, 1, n)
+		}
+
+		// the swap was either undone or finished
+		contentX, contentY := load(t, be, x, 0, 0), load(t, be, y, 0, 0)
+		if renames < 3 {
+			Equals(t, []byte("x"), contentX)
+			Equals(t, []byte("y"), contentY)
+		} else {
+			Equals(t, []byte("y"), contentX)
+			Equals(t, []byte("x"), contentY)
+		}
+
+		entries, err := ioutil.ReadDir(filepath.Join(be.Path, backend.Paths.Temp))
+		OK(t, err)
+		for _, fi := range entries {
+			Assert(t, !strings.HasPrefix(fi.Name(), "swap-"), "%v left in the temp dir", fi.Name())
+		}
+
+		cleanup()
+	}
+}
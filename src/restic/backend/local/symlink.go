@@ -0,0 +1,65 @@
+package local
+
+import (
+	"path/filepath"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+	"restic/fs"
+)
+
+// ErrCrossDevice is returned by Open when a directory of the repository is on
+// a different file system than the directory for temporary files, so files
+// cannot be moved into place by an atomic rename.
+var ErrCrossDevice = errors.New("directory is on a different file system than the temp directory")
+
+// resolvePath returns dir with all symlinks resolved.
+func resolvePath(dir string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", errors.Wrap(err, "EvalSymlinks")
+	}
+
+	if resolved != dir {
+		debug.Log("resolved repository path %v to %v", dir, resolved)
+	}
+
+	return resolved, nil
+}
+
+// checkSameDevice returns ErrCrossDevice if one of the directories of the
+// repository at base is on a different device than the temp directory. If
+// the device cannot be determined (e.g. on Windows), nil is returned.
+func checkSameDevice(fsys FS, base string) error {
+	tempdir := filepath.Join(base, backend.Paths.Temp)
+	fi, err := fsys.Stat(tempdir)
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	tempdev, err := fs.DeviceID(fi)
+	if err != nil {
+		debug.Log("unable to determine device of %v: %v", tempdir, err)
+		return nil
+	}
+
+	for _, d := range paths(base) {
+		fi, err := fsys.Stat(d)
+		if err != nil {
+			return errors.Wrap(err, "Stat")
+		}
+
+		dev, err := fs.DeviceID(fi)
+		if err != nil {
+			debug.Log("unable to determine device of %v: %v", d, err)
+			return nil
+		}
+
+		if dev != tempdev {
+			return errors.Wrapf(ErrCrossDevice, "%v", d)
+		}
+	}
+
+	return nil
+}
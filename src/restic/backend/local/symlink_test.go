@@ -0,0 +1,78 @@
+package local_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	"restic/fs"
+	. "restic/test"
+)
+
+func TestOpenSymlinkedPath(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	link := be.Location() + "-link"
+	OK(t, os.Symlink(be.Location(), link))
+	defer os.Remove(link)
+
+	be2, err := local.Open(local.Config{Path: link})
+	OK(t, err)
+	Equals(t, be.Location(), be2.Location())
+
+	h := restic.Handle{Type: restic.SnapshotFile, Name: "foo"}
+	OK(t, be2.Save(h, strings.NewReader("foo")))
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, ok, "file saved via symlinked path not found")
+}
+
+func TestOpenCrossDeviceTempdir(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	// find a directory on a different file system
+	fi, err := os.Stat(be.Location())
+	OK(t, err)
+	dev, err := fs.DeviceID(fi)
+	if err != nil {
+		t.Skipf("device IDs not supported: %v", err)
+	}
+
+	var otherdir string
+	for _, dir := range []string{"/dev/shm", "/run", "/tmp", "/var/tmp"} {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+
+		if d, err := fs.DeviceID(fi); err == nil && d != dev {
+			otherdir = dir
+			break
+		}
+	}
+
+	if otherdir == "" {
+		t.Skip("no writable directory on a different file system found")
+	}
+
+	tempdir, err := ioutil.TempDir(otherdir, "restic-local-test-")
+	if err != nil {
+		t.Skipf("unable to create directory on other file system: %v", err)
+	}
+	defer RemoveAll(t, tempdir)
+
+	tmp := filepath.Join(be.Location(), "tmp")
+	OK(t, os.Remove(tmp))
+	OK(t, os.Symlink(tempdir, tmp))
+
+	_, err = local.Open(local.Config{Path: be.Location()})
+	Assert(t, errors.Cause(err) == local.ErrCrossDevice,
+		"expected cross-device error, got %v", err)
+}
@@ -0,0 +1,47 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// syncSession syncs the files written in the background and the directories
+// files were renamed into, it is called by Close if SyncOnClose is set.
+func (b *Local) syncSession() error {
+	for _, fn := range []string{b.accessLogFile(), b.checksumCacheFile()} {
+		if err := syncFile(b.FS, fn); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+	}
+
+	dirs := append(b.unsynced.all(),
+		filepath.Join(b.Path, localPaths.Log),
+		filepath.Join(b.Path, localPaths.Checksums))
+	for _, dir := range dirs {
+		if err := syncDir(b.FS, dir); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+	}
+
+	debug.Log("synced %d directories", len(dirs))
+	b.unsynced.reset()
+	return nil
+}
+
+// syncFile flushes the file fn to stable storage. It is opened for writing,
+// which some systems require for flushing.
+func syncFile(fsys FS, fn string) error {
+	f, err := fsys.OpenFile(fn, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return errors.Wrap(err, "OpenFile")
+	}
+
+	err = f.Sync()
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return errors.Wrap(err, "Sync")
+}
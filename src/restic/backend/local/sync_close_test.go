@@ -0,0 +1,87 @@
+package local
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+	"time"
+
+	. "restic/test"
+)
+
+func TestSyncOnClose(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.AccessLog = true
+	be.SyncOnClose = true
+
+	var synced []string
+	be.FS = &fakeFS{FS: be.FS, fail: func(op, name string) error {
+		if op == "Sync" {
+			synced = append(synced, name)
+		}
+		return nil
+	}}
+
+	h, data := saveData(t, be, 23, 100)
+	Equals(t, data, load(t, be, h, 0, 0))
+
+	synced = nil
+	OK(t, be.Close())
+
+	// the events written in the background are on disk
+	buf, err := ioutil.ReadFile(be.accessLogFile())
+	OK(t, err)
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	Equals(t, 2, len(lines))
+	var ev restic.AccessEvent
+	OK(t, json.Unmarshal([]byte(lines[0]), &ev))
+	Equals(t, "Save", ev.Op)
+
+	shard := filepath.Dir(filename(be.Path, h.Type, h.Name))
+	if dirSyncSupported {
+		Assert(t, contains(synced, shard), "%v not synced on Close, synced %v", shard, synced)
+	}
+	Assert(t, contains(synced, be.accessLogFile()), "access log not synced on Close, synced %v", synced)
+
+	// directories are only synced again after new files were saved
+	synced = nil
+	OK(t, be.Close())
+	Assert(t, !contains(synced, shard), "%v synced again", shard)
+}
+
+func TestCloseMissing(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.AccessLog = true
+
+	var handles []restic.Handle
+	for i := 0; i < 5; i++ {
+		h, _ := saveData(t, be, i, 100)
+		handles = append(handles, h)
+	}
+
+	// without Close, e.g. after a crash, the saved files are complete and
+	// no tempfiles are left, only access log events may be missing
+	be2, err := Open(Config{Path: be.Path})
+	OK(t, err)
+	for _, h := range handles {
+		OK(t, be2.Verify(h))
+	}
+
+	names, err := readdirnames(be.FS, filepath.Join(be.Path, "tmp"))
+	OK(t, err)
+	Equals(t, 0, len(names))
+
+	OK(t, be.Close())
+	events, err := be2.ReadAccessLog(time.Time{})
+	OK(t, err)
+	Equals(t, 5, len(events))
+
+	_, err = os.Stat(be.accessLogFile())
+	OK(t, err)
+}
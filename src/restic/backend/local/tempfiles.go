@@ -0,0 +1,133 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// defaultTempPrefix is the prefix of tempfiles when TempPrefix is empty.
+const defaultTempPrefix = "temp-"
+
+// tempPrefix returns the prefix for the names of new tempfiles.
+func (b *Local) tempPrefix() string {
+	if b.TempPrefix == "" {
+		return defaultTempPrefix
+	}
+	return b.TempPrefix
+}
+
+// HostTempPrefix returns a prefix for TempPrefix which contains the host name
+// and the process ID, e.g. "temp-myhost-1234-". CleanupTempFiles uses it to
+// recognize tempfiles written by other hosts or by running processes.
+func HostTempPrefix() string {
+	return fmt.Sprintf("%v%v-%d-", defaultTempPrefix, hostname(), os.Getpid())
+}
+
+// hostname returns the name of the host with the characters which are not
+// allowed in file names replaced.
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, host)
+}
+
+// tempOwner parses the name of a tempfile created with HostTempPrefix and
+// returns the host and process ID. For other names, ok is false.
+func tempOwner(name string) (host string, pid int, ok bool) {
+	if !strings.HasPrefix(name, defaultTempPrefix) {
+		return "", 0, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(name, defaultTempPrefix), "-")
+	if len(parts) < 3 {
+		return "", 0, false
+	}
+
+	if _, err := strconv.ParseUint(parts[len(parts)-1], 10, 64); err != nil {
+		return "", 0, false
+	}
+
+	pid, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil || pid <= 0 {
+		return "", 0, false
+	}
+
+	return strings.Join(parts[:len(parts)-2], "-"), pid, true
+}
+
+// CleanupTempFiles removes the tempfiles below Paths.Temp which were last
+// modified more than olderThan ago, e.g. left behind by a crashed process,
+// and returns the number of files removed. Only files named with the prefix
+// "temp-" or TempPrefix are considered. Files created with HostTempPrefix
+// by another host are left alone, since the process writing them cannot be
+// checked from here, as are files of processes still running on this host.
+func (b *Local) CleanupTempFiles(olderThan time.Duration) (int, error) {
+	debug.Log("CleanupTempFiles %v", olderThan)
+	if err := b.checkRecoverMode(); err != nil {
+		return 0, err
+	}
+
+	dir := filepath.Join(b.Path, backend.Paths.Temp)
+	fileInfos, err := readdir(b.FS, dir)
+	if err != nil {
+		return 0, err
+	}
+
+	host := hostname()
+	deadline := time.Now().Add(-olderThan)
+
+	removed := 0
+	for _, fi := range fileInfos {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+
+		if !strings.HasPrefix(name, defaultTempPrefix) && !strings.HasPrefix(name, b.tempPrefix()) {
+			continue
+		}
+
+		if owner, pid, ok := tempOwner(name); ok {
+			if owner != host {
+				debug.Log("%v belongs to host %v, skipping", name, owner)
+				continue
+			}
+
+			if processAlive(pid) {
+				debug.Log("%v belongs to running process %d, skipping", name, pid)
+				continue
+			}
+		}
+
+		if !fi.ModTime().Before(deadline) {
+			continue
+		}
+
+		if err := b.FS.Remove(filepath.Join(dir, name)); err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				continue
+			}
+			return removed, errors.Wrap(err, "Remove")
+		}
+
+		debug.Log("removed tempfile %v", name)
+		removed++
+	}
+
+	return removed, nil
+}
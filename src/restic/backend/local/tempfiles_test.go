@@ -0,0 +1,91 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"strings"
+	"testing"
+	"time"
+
+	"restic/backend"
+	. "restic/test"
+)
+
+func TestTempPrefix(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.TempPrefix = HostTempPrefix()
+
+	var written []string
+	be.FS = &fakeFS{FS: be.FS, fail: func(op, name string) error {
+		if op == "Write" {
+			written = append(written, filepath.Base(name))
+		}
+		return nil
+	}}
+
+	data := Random(23, 100)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	w, err := be.SaveWriter(restic.Handle{Type: restic.LockFile, Name: "lock"})
+	OK(t, err)
+	_, err = w.Write([]byte("foobar"))
+	OK(t, err)
+	OK(t, w.Close())
+
+	Assert(t, len(written) > 0, "no tempfile written")
+	for _, name := range written {
+		Assert(t, strings.HasPrefix(name, be.TempPrefix),
+			"tempfile %v does not have prefix %v", name, be.TempPrefix)
+
+		host, pid, ok := tempOwner(name)
+		Assert(t, ok, "owner of %v not found", name)
+		Equals(t, hostname(), host)
+		Equals(t, os.Getpid(), pid)
+	}
+}
+
+func TestCleanupTempFiles(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	dir := filepath.Join(be.Path, backend.Paths.Temp)
+	old := time.Now().Add(-2 * time.Hour)
+	create := func(name string, modTime time.Time) {
+		fn := filepath.Join(dir, name)
+		OK(t, ioutil.WriteFile(fn, []byte(name), 0600))
+		OK(t, os.Chtimes(fn, modTime, modTime))
+	}
+
+	own := fmt.Sprintf("%v1", HostTempPrefix())
+	dead := fmt.Sprintf("temp-%v-99999999-1", hostname())
+	create("temp-otherhost-1-123", old)
+	create("other-prefix-123", old)
+	create(own, old)
+	create(dead, old)
+	create("temp-555", old)
+	create("temp-666", time.Now())
+
+	removed, err := be.CleanupTempFiles(time.Hour)
+	OK(t, err)
+	Equals(t, 2, removed)
+
+	names, err := readdirnames(be.FS, dir)
+	OK(t, err)
+	remaining := make(map[string]bool)
+	for _, name := range names {
+		remaining[name] = true
+	}
+
+	for _, name := range []string{"temp-otherhost-1-123", "other-prefix-123", own, "temp-666"} {
+		Assert(t, remaining[name], "tempfile %v was removed", name)
+	}
+	for _, name := range []string{dead, "temp-555"} {
+		Assert(t, !remaining[name], "tempfile %v was not removed", name)
+	}
+}
@@ -0,0 +1,99 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// TestMany checks for each handle in handles whether the file exists in the
+// backend. Handles are grouped by the directory the files are stored in, and
+// each directory is read only once, which needs far fewer syscalls than
+// calling Test for each handle. A directory that does not exist means that
+// none of the files in it exist. If reading a directory fails for another
+// reason, an error is returned and the handles in that directory are missing
+// from the returned map, unless the file was found in another location (e.g.
+// a sub-shard).
+func (b *Local) TestMany(handles []restic.Handle) (map[restic.Handle]bool, error) {
+	debug.Log("TestMany %d handles", len(handles))
+
+	type entry struct {
+		h    restic.Handle
+		name string
+	}
+
+	// a file may be stored in several locations, e.g. in a sub-shard
+	dirs := make(map[string][]entry)
+	res := make(map[restic.Handle]bool, len(handles))
+	for _, h := range handles {
+		if err := b.checkPermitted(h.Type); err != nil {
+			return nil, err
+		}
+
+		if _, ok := b.packed(h); ok {
+			res[h] = true
+			continue
+		}
+
+		for _, fn := range b.candidates(h) {
+			dir, name := filepath.Split(fn)
+			dirs[dir] = append(dirs[dir], entry{h, name})
+		}
+	}
+
+	var firstErr error
+	failed := make(map[restic.Handle]bool)
+	for dir, list := range dirs {
+		names, err := readdirnames(b.FS, dir)
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			debug.Log("readdir %v failed: %v", dir, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			for _, e := range list {
+				failed[e.h] = true
+			}
+			continue
+		}
+
+		entries := make(map[string]struct{}, len(names))
+		for _, name := range names {
+			entries[name] = struct{}{}
+		}
+
+		for _, e := range list {
+			if _, ok := entries[e.name]; ok {
+				res[e.h] = true
+			}
+		}
+	}
+
+	for _, h := range handles {
+		if !res[h] && !failed[h] {
+			res[h] = false
+		}
+	}
+
+	return res, firstErr
+}
+
+// readdirnames returns the names of all entries in directory d. In contrast
+// to readdir, the entries are not stat'ed.
+func readdirnames(fsys FS, d string) (names []string, err error) {
+	f, e := fsys.Open(d)
+	if e != nil {
+		return nil, errors.Wrap(e, "Open")
+	}
+
+	defer func() {
+		e := f.Close()
+		if err == nil {
+			err = errors.Wrap(e, "Close")
+		}
+	}()
+
+	return f.Readdirnames(-1)
+}
@@ -0,0 +1,102 @@
+package local_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	. "restic/test"
+)
+
+func saveRandom(t testing.TB, be restic.Backend, tpe restic.FileType, n int) []restic.Handle {
+	var handles []restic.Handle
+	for i := 0; i < n; i++ {
+		data := Random(i, 100+i)
+		h := restic.Handle{Type: tpe, Name: restic.Hash(data).String()}
+		OK(t, be.Save(h, bytes.NewReader(data)))
+		handles = append(handles, h)
+	}
+	return handles
+}
+
+func TestTestMany(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	existing := saveRandom(t, be, restic.DataFile, 20)
+	existing = append(existing, saveRandom(t, be, restic.SnapshotFile, 3)...)
+
+	var missing []restic.Handle
+	for i := 0; i < 10; i++ {
+		id := restic.Hash([]byte(fmt.Sprintf("missing %d", i)))
+		missing = append(missing,
+			restic.Handle{Type: restic.DataFile, Name: id.String()},
+			restic.Handle{Type: restic.IndexFile, Name: id.String()})
+	}
+
+	res, err := be.TestMany(append(existing, missing...))
+	OK(t, err)
+	Equals(t, len(existing)+len(missing), len(res))
+
+	for _, h := range existing {
+		Assert(t, res[h], "existing file %v reported as missing", h)
+	}
+
+	for _, h := range missing {
+		Assert(t, !res[h], "missing file %v reported as existing", h)
+	}
+}
+
+func TestTestManyError(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.DataFile, Name: "aa00"}
+	OK(t, ioutil.WriteFile(filepath.Join(be.Location(), "data", "aa"), []byte("foo"), 0600))
+
+	res, err := be.TestMany([]restic.Handle{h})
+	Assert(t, err != nil, "expected error for unreadable directory not found")
+	Assert(t, !os.IsNotExist(err), "unexpected not-exist error %v", err)
+	_, ok := res[h]
+	Assert(t, !ok, "result for handle in unreadable directory returned")
+}
+
+func benchmarkTest(b *testing.B, fn func(be *local.Local, handles []restic.Handle)) {
+	be, cleanup := local.TestBackend(b)
+	defer cleanup()
+
+	// store all files in the same shard directory
+	var handles []restic.Handle
+	for i := 0; i < 500; i++ {
+		h := restic.Handle{Type: restic.DataFile, Name: fmt.Sprintf("aa%062x", i)}
+		OK(b, be.Save(h, bytes.NewReader([]byte("foo"))))
+		handles = append(handles, h)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(be, handles)
+	}
+}
+
+func BenchmarkTestLoop(b *testing.B) {
+	benchmarkTest(b, func(be *local.Local, handles []restic.Handle) {
+		for _, h := range handles {
+			ok, err := be.Test(h)
+			OK(b, err)
+			Assert(b, ok, "file %v not found", h)
+		}
+	})
+}
+
+func BenchmarkTestMany(b *testing.B) {
+	benchmarkTest(b, func(be *local.Local, handles []restic.Handle) {
+		_, err := be.TestMany(handles)
+		OK(b, err)
+	})
+}
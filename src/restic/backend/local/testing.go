@@ -0,0 +1,27 @@
+package local
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"restic/test"
+)
+
+// TestBackend returns a new local backend in a temporary directory. The
+// directory is removed when cleanup is called.
+func TestBackend(t testing.TB) (be *Local, cleanup func()) {
+	tempdir, err := ioutil.TempDir(test.TestTempDir, "restic-local-test-")
+	test.OK(t, err)
+
+	be, err = Create(Config{Path: tempdir})
+	test.OK(t, err)
+
+	return be, func() {
+		if !test.TestCleanupTempDirs {
+			t.Logf("leaving temporary directory %v used for test", tempdir)
+			return
+		}
+
+		test.RemoveAll(t, tempdir)
+	}
+}
@@ -0,0 +1,232 @@
+package local
+
+import (
+	"os"
+	"time"
+
+	"restic/errors"
+)
+
+// ErrTimeout is returned when a file system operation does not complete
+// within OpTimeout.
+var ErrTimeout = errors.New("file system operation timed out")
+
+// timeoutFS runs each operation of the underlying FS in a separate goroutine
+// and returns ErrTimeout if it does not complete in time. The goroutine of an
+// operation which timed out is abandoned: the syscall may still be blocked,
+// and a file it opens later is never closed.
+type timeoutFS struct {
+	FS
+	timeout time.Duration
+}
+
+func withTimeout(timeout time.Duration, op string, fn func() error) error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- fn()
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-t.C:
+		return errors.Wrapf(ErrTimeout, "%v after %v", op, timeout)
+	}
+}
+
+func (f timeoutFS) openFile(op string, open func() (File, error)) (File, error) {
+	var file File
+	err := withTimeout(f.timeout, op, func() (err error) {
+		file, err = open()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &timeoutFile{File: file, timeout: f.timeout}, nil
+}
+
+func (f timeoutFS) Open(name string) (File, error) {
+	return f.openFile("Open", func() (File, error) { return f.FS.Open(name) })
+}
+
+func (f timeoutFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return f.openFile("OpenFile", func() (File, error) { return f.FS.OpenFile(name, flag, perm) })
+}
+
+func (f timeoutFS) TempFile(dir, prefix string) (File, error) {
+	return f.openFile("TempFile", func() (File, error) { return f.FS.TempFile(dir, prefix) })
+}
+
+func (f timeoutFS) Stat(name string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := withTimeout(f.timeout, "Stat", func() (err error) {
+		fi, err = f.FS.Stat(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+func (f timeoutFS) Lstat(name string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := withTimeout(f.timeout, "Lstat", func() (err error) {
+		fi, err = f.FS.Lstat(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+func (f timeoutFS) Chmod(name string, mode os.FileMode) error {
+	return withTimeout(f.timeout, "Chmod", func() error { return f.FS.Chmod(name, mode) })
+}
+
+func (f timeoutFS) MkdirAll(path string, perm os.FileMode) error {
+	return withTimeout(f.timeout, "MkdirAll", func() error { return f.FS.MkdirAll(path, perm) })
+}
+
+func (f timeoutFS) Rename(oldpath, newpath string) error {
+	return withTimeout(f.timeout, "Rename", func() error { return f.FS.Rename(oldpath, newpath) })
+}
+
+func (f timeoutFS) Link(oldname, newname string) error {
+	return withTimeout(f.timeout, "Link", func() error { return f.FS.Link(oldname, newname) })
+}
+
+func (f timeoutFS) Remove(name string) error {
+	return withTimeout(f.timeout, "Remove", func() error { return f.FS.Remove(name) })
+}
+
+func (f timeoutFS) RemoveAll(path string) error {
+	return withTimeout(f.timeout, "RemoveAll", func() error { return f.FS.RemoveAll(path) })
+}
+
+// timeoutFile runs the operations on a file opened by timeoutFS with a
+// timeout. Read and Write pass a buffer of the file to the underlying File,
+// so that an operation which is abandoned after a timeout does not access
+// the buffer of the caller later. The buffer is reused until an operation
+// times out. The file is not safe for concurrent use.
+type timeoutFile struct {
+	File
+	timeout time.Duration
+	buf     []byte
+}
+
+// timedOut returns true if err was returned by withTimeout because the
+// operation did not complete. Values written by the operation must not be
+// used in this case.
+func timedOut(err error) bool {
+	return errors.Cause(err) == ErrTimeout
+}
+
+// buffer returns a buffer of n bytes owned by f.
+func (f *timeoutFile) buffer(n int) []byte {
+	if cap(f.buf) < n {
+		f.buf = make([]byte, n)
+	}
+	return f.buf[:n]
+}
+
+func (f *timeoutFile) Read(p []byte) (int, error) {
+	buf := f.buffer(len(p))
+	var n int
+	err := withTimeout(f.timeout, "Read", func() (err error) {
+		n, err = f.File.Read(buf)
+		return err
+	})
+	if timedOut(err) {
+		// the abandoned Read still owns the buffer
+		f.buf = nil
+		return 0, err
+	}
+	return copy(p, buf[:n]), err
+}
+
+func (f *timeoutFile) Write(p []byte) (int, error) {
+	buf := f.buffer(len(p))
+	copy(buf, p)
+	var n int
+	err := withTimeout(f.timeout, "Write", func() (err error) {
+		n, err = f.File.Write(buf)
+		return err
+	})
+	if timedOut(err) {
+		f.buf = nil
+		return 0, err
+	}
+	return n, err
+}
+
+func (f *timeoutFile) Seek(offset int64, whence int) (int64, error) {
+	var n int64
+	err := withTimeout(f.timeout, "Seek", func() (err error) {
+		n, err = f.File.Seek(offset, whence)
+		return err
+	})
+	if timedOut(err) {
+		return 0, err
+	}
+	return n, err
+}
+
+func (f *timeoutFile) Readdir(count int) ([]os.FileInfo, error) {
+	var fis []os.FileInfo
+	err := withTimeout(f.timeout, "Readdir", func() (err error) {
+		fis, err = f.File.Readdir(count)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return fis, err
+}
+
+func (f *timeoutFile) Readdirnames(count int) ([]string, error) {
+	var names []string
+	err := withTimeout(f.timeout, "Readdirnames", func() (err error) {
+		names, err = f.File.Readdirnames(count)
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return names, err
+}
+
+func (f *timeoutFile) Stat() (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := withTimeout(f.timeout, "Stat", func() (err error) {
+		fi, err = f.File.Stat()
+		return err
+	})
+	if timedOut(err) {
+		return nil, err
+	}
+	return fi, err
+}
+
+func (f *timeoutFile) Sync() error {
+	return withTimeout(f.timeout, "Sync", f.File.Sync)
+}
+
+func (f *timeoutFile) Close() error {
+	return withTimeout(f.timeout, "Close", f.File.Close)
+}
+
+// fdOp runs op, a system call on the descriptor of f such as fdatasync, with
+// the timeout of f if it was opened by timeoutFS. These calls use Fd and
+// bypass the methods of timeoutFile.
+func fdOp(f File, name string, op func() error) error {
+	if tf, ok := f.(*timeoutFile); ok {
+		return withTimeout(tf.timeout, name, op)
+	}
+	return op()
+}
@@ -0,0 +1,59 @@
+package local
+
+import (
+	"bytes"
+	"restic"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestOpTimeout(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	fn := filename(be.Path, h.Type, h.Name)
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	slow := &fakeFS{FS: be.FS, fail: func(op, name string) error {
+		if name == fn && (op == "Stat" || op == "Read") {
+			<-unblock
+		}
+		return nil
+	}}
+
+	cfg := be.Config
+	cfg.OpTimeout = 50 * time.Millisecond
+	be, err := open(cfg, slow)
+	OK(t, err)
+
+	start := time.Now()
+	_, err = be.Stat(h)
+	Assert(t, errors.Cause(err) == ErrTimeout, "expected ErrTimeout, got %v", err)
+	Assert(t, time.Since(start) < time.Second, "Stat blocked for %v", time.Since(start))
+
+	rd, err := be.Load(h, 0, 0)
+	OK(t, err)
+	_, err = rd.Read(make([]byte, 10))
+	Assert(t, errors.Cause(err) == ErrTimeout, "expected ErrTimeout, got %v", err)
+	OK(t, rd.Close())
+
+	// other operations are not affected
+	ok, err := be.Test(restic.Handle{Type: restic.DataFile, Name: "foobar"})
+	OK(t, err)
+	Assert(t, !ok, "non-existing file found")
+
+	// system calls on the descriptor are bounded as well
+	err = fdOp(&timeoutFile{timeout: cfg.OpTimeout}, "Fdatasync", func() error {
+		<-unblock
+		return nil
+	})
+	Assert(t, errors.Cause(err) == ErrTimeout, "expected ErrTimeout, got %v", err)
+}
@@ -0,0 +1,63 @@
+package local
+
+import (
+	"encoding/hex"
+	"io"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// Transcode replaces the content of the file at h with the data returned by
+// transform for the current content, e.g. to re-encrypt it with a new key.
+// For content-addressed files the name is recomputed: if it changes, the
+// file is stored under the new name and the old file is removed. Otherwise,
+// the file is replaced atomically. The handle of the transcoded file is
+// returned.
+func (b *Local) Transcode(h restic.Handle, transform func(io.Reader) (io.Reader, error)) (restic.Handle, error) {
+	debug.Log("Transcode %v", h)
+	if err := b.checkRecoverMode(); err != nil {
+		return restic.Handle{}, err
+	}
+
+	rd, err := b.Load(h, 0, 0)
+	if err != nil {
+		return restic.Handle{}, err
+	}
+	defer rd.Close()
+
+	trd, err := transform(rd)
+	if err != nil {
+		return restic.Handle{}, errors.Wrap(err, "transform")
+	}
+
+	hash := b.newHash()
+	if isContentAddressed(h.Type) {
+		trd = io.TeeReader(trd, hash)
+	}
+
+	opts := b.record(saveOptions{overwrite: true})
+	tmpfile, size, err := b.copyToTempfile(opts.tee(trd))
+	if err != nil {
+		return restic.Handle{}, err
+	}
+
+	newHandle := h
+	if isContentAddressed(h.Type) {
+		newHandle.Name = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	if err = b.commit(newHandle, tmpfile, size, nil, opts); err != nil {
+		return restic.Handle{}, err
+	}
+
+	if newHandle != h {
+		debug.Log("%v renamed to %v", h, newHandle)
+		if err = b.Remove(h); err != nil {
+			return newHandle, err
+		}
+	}
+
+	return newHandle, nil
+}
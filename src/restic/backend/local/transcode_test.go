@@ -0,0 +1,75 @@
+package local_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"restic"
+	"testing"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func reverse(rd io.Reader) (io.Reader, error) {
+	buf, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return bytes.NewReader(buf), nil
+}
+
+func TestTranscode(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	// the identity keeps the file
+	h2, err := be.Transcode(h, func(rd io.Reader) (io.Reader, error) { return rd, nil })
+	OK(t, err)
+	Equals(t, h, h2)
+	Equals(t, data, loadAll(t, be, h))
+
+	// reversing changes the name
+	h3, err := be.Transcode(h, reverse)
+	OK(t, err)
+	Assert(t, h3 != h, "name not changed")
+	OK(t, be.Verify(h3))
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "old file %v not removed", h)
+
+	reversed, err := reverse(bytes.NewReader(loadAll(t, be, h3)))
+	OK(t, err)
+	buf, err := ioutil.ReadAll(reversed)
+	OK(t, err)
+	Equals(t, data, buf)
+	Equals(t, []string{h3.Name}, listNames(be, restic.DataFile))
+}
+
+func TestTranscodeMutable(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.KeyFile, Name: "key"}
+	OK(t, be.Save(h, bytes.NewReader([]byte("abc"))))
+
+	h2, err := be.Transcode(h, reverse)
+	OK(t, err)
+	Equals(t, h, h2)
+	Equals(t, []byte("cba"), loadAll(t, be, h))
+
+	// a failing transform leaves the file untouched
+	_, err = be.Transcode(h, func(io.Reader) (io.Reader, error) { return nil, errors.New("failed") })
+	Assert(t, err != nil, "error of transform not returned")
+	Equals(t, []byte("cba"), loadAll(t, be, h))
+}
@@ -0,0 +1,20 @@
+package local
+
+import (
+	"restic"
+
+	"restic/errors"
+)
+
+// ErrTruncated is returned by Load when DetectTruncation is set and the file
+// is shorter than the requested range.
+var ErrTruncated = errors.New("file is truncated")
+
+// checkTruncated returns ErrTruncated if a file of size bytes ends before
+// offset+length.
+func checkTruncated(h restic.Handle, size int64, length int, offset int64) error {
+	if end := offset + int64(length); end > size {
+		return errors.Wrapf(ErrTruncated, "%v has %d bytes, want at least %d", h, size, end)
+	}
+	return nil
+}
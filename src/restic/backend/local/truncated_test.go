@@ -0,0 +1,52 @@
+package local
+
+import (
+	"os"
+	"testing"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestDetectTruncation(t *testing.T) {
+	for _, footer := range []bool{false, true} {
+		be, cleanup := TestBackend(t)
+		be.DetectTruncation = true
+		be.Footer = footer
+
+		h, data := saveData(t, be, 23, 1000)
+
+		// the complete file is fine
+		Equals(t, data[900:], load(t, be, h, 100, 900))
+		Equals(t, data, load(t, be, h, 0, 0))
+
+		fn := filename(be.Path, h.Type, h.Name)
+		OK(t, os.Chmod(fn, 0600))
+		size := int64(600)
+		if footer {
+			// keep a valid footer, as if the file was written short
+			raw := append(append([]byte{}, data[:size]...), encodeFooter(h.Type, size)...)
+			f, err := os.OpenFile(fn, os.O_WRONLY|os.O_TRUNC, 0)
+			OK(t, err)
+			_, err = f.Write(raw)
+			OK(t, err)
+			OK(t, f.Close())
+		} else {
+			OK(t, os.Truncate(fn, size))
+		}
+
+		Equals(t, data[500:600], load(t, be, h, 100, 500))
+
+		_, err := be.Load(h, 100, 550)
+		Assert(t, errors.Cause(err) == ErrTruncated, "expected ErrTruncated, got %v", err)
+		rd, err := be.Load(h, 0, 950)
+		OK(t, err)
+		OK(t, rd.Close())
+
+		// without the option, the file is read short
+		be.DetectTruncation = false
+		Equals(t, data[550:600], load(t, be, h, 100, 550))
+
+		cleanup()
+	}
+}
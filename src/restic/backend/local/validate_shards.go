@@ -0,0 +1,44 @@
+package local
+
+import (
+	"fmt"
+	"path/filepath"
+	"restic"
+	"sort"
+
+	"restic/debug"
+)
+
+// ValidateShards checks the subdirectories of the data directory and
+// returns a description of each problem found: entries with the name of a
+// shard which are not directories, shard directories which cannot be read,
+// and files stored directly in the data directory instead of a shard. List
+// does not return the files affected by these problems. Nothing is
+// modified.
+func (b *Local) ValidateShards() (problems []string, err error) {
+	dir := dirname(b.Path, restic.DataFile, "")
+	debug.Log("ValidateShards %v", dir)
+
+	entries, err := readdir(b.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byName(entries))
+
+	for _, fi := range entries {
+		name := "data/" + fi.Name()
+		switch {
+		case fi.IsDir():
+			if _, err := readdirnames(b.FS, filepath.Join(dir, fi.Name())); err != nil {
+				problems = append(problems, fmt.Sprintf("%v cannot be read: %v", name, err))
+			}
+		case len(fi.Name()) == 2:
+			problems = append(problems, fmt.Sprintf("%v is not a directory", name))
+		case isFile(fi):
+			problems = append(problems, fmt.Sprintf("%v is not in a shard directory", name))
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,47 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	. "restic/test"
+)
+
+func TestValidateShards(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	saveInShard(t, be, "ab", 1, 0)
+	problems, err := be.ValidateShards()
+	OK(t, err)
+	Equals(t, 0, len(problems))
+
+	data := filepath.Join(be.Path, "data")
+	OK(t, ioutil.WriteFile(filepath.Join(data, "cd"), []byte("x"), 0600))
+	OK(t, ioutil.WriteFile(filepath.Join(data, "cdef0123"), []byte("x"), 0600))
+	OK(t, os.Mkdir(filepath.Join(data, "ef"), 0700))
+
+	be.FS = &fakeFS{FS: be.FS, fail: func(op, name string) error {
+		if op == "Open" && name == filepath.Join(data, "ef") {
+			return syscall.EACCES
+		}
+		return nil
+	}}
+
+	problems, err = be.ValidateShards()
+	OK(t, err)
+	Equals(t, 3, len(problems))
+	Equals(t, "data/cd is not a directory", problems[0])
+	Equals(t, "data/cdef0123 is not in a shard directory", problems[1])
+	Assert(t, len(problems[2]) > 0 && problems[2][:len("data/ef cannot be read")] == "data/ef cannot be read",
+		"unexpected problem %q", problems[2])
+
+	// nothing is changed
+	for _, name := range []string{"cd", "cdef0123", "ef"} {
+		_, err := os.Stat(filepath.Join(data, name))
+		OK(t, err)
+	}
+}
@@ -0,0 +1,283 @@
+package local
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"restic"
+	"sync"
+	"time"
+
+	"restic/backend"
+	"restic/debug"
+	"restic/errors"
+)
+
+// ErrHashMismatch is returned when the content of a file does not match its
+// name.
+var ErrHashMismatch = errors.New("content does not match hash")
+
+// checksumEntry records the state of a file which was successfully verified.
+type checksumEntry struct {
+	ModTime int64 `json:"mtime"`
+	Size    int64 `json:"size"`
+}
+
+// checksumCache holds the files which were verified, it is loaded from
+// checksums/ on first use.
+type checksumCache struct {
+	m       sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]checksumEntry
+}
+
+// checksumKey returns the key of the checksum cache entry for the file fn,
+// its path relative to the repository. A file saved to a sub-shard or left
+// in the flat layout therefore has an entry of its own.
+func (b *Local) checksumKey(fn string) string {
+	rel, err := filepath.Rel(b.Path, fn)
+	if err != nil {
+		return fn
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (b *Local) checksumCacheFile() string {
+	return filepath.Join(b.Path, localPaths.Checksums, "cache.json")
+}
+
+// loadChecksums reads the checksum cache, b.checksums.m must be held. A
+// missing or unreadable cache file results in an empty cache.
+func (b *Local) loadChecksums() {
+	if b.checksums.loaded {
+		return
+	}
+
+	b.checksums.loaded = true
+	b.checksums.entries = make(map[string]checksumEntry)
+
+	f, err := b.FS.Open(b.checksumCacheFile())
+	if err != nil {
+		debug.Log("unable to open checksum cache: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&b.checksums.entries); err != nil {
+		debug.Log("unable to decode checksum cache: %v", err)
+		b.checksums.entries = make(map[string]checksumEntry)
+	}
+}
+
+// FlushChecksumCache writes the checksum cache to disk if it has been
+// modified. The file is replaced atomically.
+func (b *Local) FlushChecksumCache() error {
+	b.checksums.m.Lock()
+	defer b.checksums.m.Unlock()
+
+	if !b.checksums.dirty {
+		return nil
+	}
+
+	buf, err := json.Marshal(b.checksums.entries)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	dir := filepath.Join(b.Path, localPaths.Checksums)
+	if err = b.FS.MkdirAll(dir, backend.Modes.Dir); err != nil {
+		return errors.Wrap(err, "MkdirAll")
+	}
+
+	tmpfile, _, err := b.copyToTempfile(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	if err = b.FS.Rename(tmpfile, b.checksumCacheFile()); err != nil {
+		b.FS.Remove(tmpfile)
+		return errors.Wrap(err, "Rename")
+	}
+
+	b.checksums.dirty = false
+	return nil
+}
+
+// Verify checks that the content of the file at h matches its name. Only
+// content-addressed files can be verified. If ChecksumCache is enabled, a
+// file which has not been modified since it was last verified successfully
+// is not read again.
+func (b *Local) Verify(h restic.Handle) error {
+	debug.Log("Verify %v", h)
+	if err := h.Valid(); err != nil {
+		return err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return err
+	}
+
+	if !isContentAddressed(h.Type) {
+		return errors.Errorf("files of type %v cannot be verified", h.Type)
+	}
+
+	if err := b.checkHashName(h); err != nil {
+		return err
+	}
+
+	if e, ok := b.packed(h); ok {
+		return b.verifyPacked(h, e)
+	}
+
+	fn, fi, err := b.locate(h)
+	if err != nil {
+		return errors.Wrap(err, "Stat")
+	}
+
+	entry := checksumEntry{ModTime: fi.ModTime().UnixNano(), Size: fi.Size()}
+	key := b.checksumKey(fn)
+
+	if b.ChecksumCache {
+		b.checksums.m.Lock()
+		b.loadChecksums()
+		cached, ok := b.checksums.entries[key]
+		b.checksums.m.Unlock()
+
+		if ok && cached == entry {
+			debug.Log("%v unchanged since last verification", h)
+			return nil
+		}
+	}
+
+	id, err := b.hashFile(fn)
+	if err != nil {
+		return err
+	}
+
+	if b.ChecksumCache {
+		b.checksums.m.Lock()
+		if id == h.Name {
+			b.checksums.entries[key] = entry
+		} else {
+			delete(b.checksums.entries, key)
+		}
+		b.checksums.dirty = true
+		b.checksums.m.Unlock()
+	}
+
+	if id != h.Name {
+		err = errors.Wrapf(ErrHashMismatch, "%v has hash %v", h, id)
+		if b.RepairSource != nil {
+			return b.repairFromSource(h, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// verifyPacked implements Verify for the packed file h stored at e. The
+// checksum cache is not used, the data is read from the container.
+func (b *Local) verifyPacked(h restic.Handle, e packedEntry) error {
+	id, err := b.hashPacked(e)
+	if err != nil {
+		return err
+	}
+
+	if id != h.Name {
+		err = errors.Wrapf(ErrHashMismatch, "%v has hash %v", h, id)
+		if b.RepairSource != nil {
+			return b.repairFromSource(h, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// hashPacked returns the hash of the data of the packed file e like hashFile.
+func (b *Local) hashPacked(e packedEntry) (string, error) {
+	rd, err := b.loadPacked(e, 0, 0)
+	if err != nil {
+		return "", err
+	}
+
+	hash := b.newHash()
+	_, err = io.Copy(hash, rd)
+	if e := rd.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "Read")
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// repairFromSource replaces the file at h, which failed verification with
+// verr, with the copy from RepairSource. If the copy cannot be loaded or
+// does not match the name either, verr is returned.
+func (b *Local) repairFromSource(h restic.Handle, verr error) (err error) {
+	debug.Log("repairing %v from %v", h, b.RepairSource.Location())
+	if b.AccessLog {
+		start := time.Now()
+		defer func() {
+			b.logAccess("Repair", h, start, 0, err)
+		}()
+	}
+
+	rd, err := b.RepairSource.Load(h, 0, 0)
+	if err != nil {
+		debug.Log("unable to load %v from the repair source: %v", h, err)
+		return verr
+	}
+
+	err = b.RepairFrom(h, rd, h.Name)
+	if e := rd.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		debug.Log("repairing %v failed: %v", h, err)
+		return verr
+	}
+
+	debug.Log("%v repaired", h)
+	return nil
+}
+
+// hashFile returns the hex-encoded digest of the file fn computed by the
+// configured hash function.
+func (b *Local) hashFile(fn string) (string, error) {
+	f, err := b.FS.Open(fn)
+	if err != nil {
+		return "", errors.Wrap(err, "Open")
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return "", errors.Wrap(err, "Stat")
+	}
+
+	size, err := b.contentSize(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+
+	hash := b.newHash()
+	_, err = io.Copy(hash, io.LimitReader(f, size))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return "", errors.Wrap(err, "Read")
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,73 @@
+package local_test
+
+import (
+	"bytes"
+	"restic"
+	"testing"
+	"time"
+
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func TestVerifyRepair(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	src, cleanup2 := local.TestBackend(t)
+	defer cleanup2()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, src.Save(h, bytes.NewReader(data)))
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[500] ^= 0xff
+	OK(t, be.Save(h, bytes.NewReader(corrupted)))
+
+	// repairs are disabled by default
+	Assert(t, errors.Cause(be.Verify(h)) == local.ErrHashMismatch, "file is not corrupted")
+
+	be.RepairSource = src
+	be.AccessLog = true
+	OK(t, be.Verify(h))
+	Equals(t, data, loadAll(t, be, h))
+	OK(t, be.Verify(h))
+
+	OK(t, be.Close())
+	events, err := be.ReadAccessLog(time.Time{})
+	OK(t, err)
+	repairs := 0
+	for _, ev := range events {
+		if ev.Op == "Repair" {
+			Equals(t, h.Name, ev.Name)
+			Equals(t, "", ev.Error)
+			repairs++
+		}
+	}
+	Equals(t, 1, repairs)
+}
+
+func TestVerifyRepairBadSource(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	src, cleanup2 := local.TestBackend(t)
+	defer cleanup2()
+	be.RepairSource = src
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	corrupted := append([]byte(nil), data...)
+	corrupted[500] ^= 0xff
+	OK(t, be.Save(h, bytes.NewReader(corrupted)))
+
+	// the file is missing in the source
+	Assert(t, errors.Cause(be.Verify(h)) == local.ErrHashMismatch, "expected ErrHashMismatch")
+
+	// the copy in the source is corrupted as well
+	other := append([]byte(nil), data...)
+	other[10] ^= 0xff
+	OK(t, src.Save(h, bytes.NewReader(other)))
+	Assert(t, errors.Cause(be.Verify(h)) == local.ErrHashMismatch, "expected ErrHashMismatch")
+	Equals(t, corrupted, loadAll(t, be, h))
+}
@@ -0,0 +1,92 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"restic"
+	"testing"
+	"time"
+
+	"restic/errors"
+	. "restic/test"
+)
+
+// countOpens returns a fakeFS which counts calls to Open for fn.
+func countOpens(fsys FS, fn string, n *int) *fakeFS {
+	return &fakeFS{FS: fsys, fail: func(op, name string) error {
+		if op == "Open" && name == fn {
+			*n++
+		}
+		return nil
+	}}
+}
+
+func TestVerify(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+	OK(t, be.Verify(h))
+
+	bad := restic.Handle{Type: restic.DataFile, Name: restic.Hash([]byte("foo")).String()}
+	OK(t, be.Save(bad, bytes.NewReader(data)))
+	err := be.Verify(bad)
+	Assert(t, errors.Cause(err) == ErrHashMismatch, "expected hash mismatch, got %v", err)
+}
+
+func TestVerifyChecksumCache(t *testing.T) {
+	be, cleanup := TestBackend(t)
+	defer cleanup()
+	be.ChecksumCache = true
+
+	data := Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	fn := filename(be.Path, h.Type, h.Name)
+	opens := 0
+	be.FS = countOpens(be.FS, fn, &opens)
+
+	// miss
+	OK(t, be.Verify(h))
+	Equals(t, 1, opens)
+
+	// hit
+	OK(t, be.Verify(h))
+	Equals(t, 1, opens)
+
+	// the cache is persisted
+	OK(t, be.Close())
+	be2, err := Open(be.Config)
+	OK(t, err)
+	be2.FS = countOpens(be2.FS, fn, &opens)
+	OK(t, be2.Verify(h))
+	Equals(t, 1, opens)
+
+	// a modified file is verified again
+	fi, err := os.Stat(fn)
+	OK(t, err)
+	OK(t, os.Chmod(fn, 0600))
+	buf := append([]byte{}, data...)
+	buf[0] ^= 0xff
+	OK(t, ioutil.WriteFile(fn, buf, 0600))
+	mtime := fi.ModTime().Add(time.Second)
+	OK(t, os.Chtimes(fn, mtime, mtime))
+
+	err = be2.Verify(h)
+	Assert(t, errors.Cause(err) == ErrHashMismatch, "expected hash mismatch, got %v", err)
+	Equals(t, 2, opens)
+
+	// failed verifications are not cached
+	err = be2.Verify(h)
+	Assert(t, errors.Cause(err) == ErrHashMismatch, "expected hash mismatch, got %v", err)
+	Equals(t, 3, opens)
+
+	OK(t, be2.Close())
+	_, err = os.Stat(filepath.Join(be.Path, "checksums", "cache.json"))
+	OK(t, err)
+}
@@ -0,0 +1,96 @@
+package local
+
+import (
+	"io"
+	"restic"
+
+	"restic/debug"
+	"restic/errors"
+)
+
+// errAborted is passed to Save when a FileWriter is aborted.
+var errAborted = errors.New("FileWriter aborted")
+
+// FileWriter stores the data written to it at a handle. The data is passed
+// to Save while it is written, so the same checks and options apply, and the
+// file is moved into place by Close. FileWriter is returned by SaveWriter.
+type FileWriter struct {
+	h      restic.Handle
+	pw     *io.PipeWriter
+	result chan error
+	done   bool
+}
+
+// SaveWriter returns a writer which stores the data written to it at the
+// handle. The file is created when Close is called. If an error occurs while
+// writing, Abort must be called to remove the tempfile.
+func (b *Local) SaveWriter(h restic.Handle) (*FileWriter, error) {
+	debug.Log("SaveWriter %v", h)
+	if err := b.checkRecoverMode(); err != nil {
+		return nil, err
+	}
+
+	if err := h.Valid(); err != nil {
+		return nil, err
+	}
+
+	if err := b.checkPermitted(h.Type); err != nil {
+		return nil, err
+	}
+
+	if b.VerifyHash && isContentAddressed(h.Type) {
+		if err := b.checkHashName(h); err != nil {
+			return nil, err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	w := &FileWriter{h: h, pw: pw, result: make(chan error, 1)}
+
+	go func() {
+		err := b.Save(h, pr)
+		// unblock writes if Save returns before all data was read
+		pr.CloseWithError(err)
+		w.result <- err
+	}()
+
+	return w, nil
+}
+
+// Write passes p to Save.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, errors.New("write to closed FileWriter")
+	}
+
+	n, err := w.pw.Write(p)
+	return n, errors.Wrap(err, "Write")
+}
+
+// Close finishes the data and waits for Save to move the file into place. If
+// the checks done by Save fail, the tempfile is removed.
+func (w *FileWriter) Close() error {
+	if w.done {
+		return errors.New("FileWriter already closed")
+	}
+	w.done = true
+
+	w.pw.Close()
+	return <-w.result
+}
+
+// Abort removes the tempfile, nothing is stored. Calling Abort after Close
+// does nothing.
+func (w *FileWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	debug.Log("abort writing %v", w.h)
+	w.pw.CloseWithError(errAborted)
+	if err := <-w.result; err != nil && errors.Cause(err) != errAborted {
+		debug.Log("Save for aborted %v returned %v", w.h, err)
+	}
+	return nil
+}
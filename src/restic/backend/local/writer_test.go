@@ -0,0 +1,115 @@
+package local_test
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"restic"
+	"testing"
+
+	"restic/backend"
+	"restic/backend/local"
+	"restic/errors"
+	. "restic/test"
+)
+
+func tempfiles(t testing.TB, be *local.Local) int {
+	files, err := ioutil.ReadDir(filepath.Join(be.Location(), "tmp"))
+	OK(t, err)
+	return len(files)
+}
+
+func TestSaveWriter(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	be.VerifyHash = true
+
+	data := Random(23, 10000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	w, err := be.SaveWriter(h)
+	OK(t, err)
+	for i := 0; i < len(data); i += 1000 {
+		_, err = w.Write(data[i : i+1000])
+		OK(t, err)
+	}
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "file exists before Close")
+
+	OK(t, w.Close())
+	OK(t, w.Abort())
+
+	buf, err := backend.LoadAll(be, h)
+	OK(t, err)
+	Equals(t, data, buf)
+	Equals(t, 0, tempfiles(t, be))
+}
+
+func TestSaveWriterAbort(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 10000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+
+	w, err := be.SaveWriter(h)
+	OK(t, err)
+	_, err = w.Write(data)
+	OK(t, err)
+	Equals(t, 1, tempfiles(t, be))
+
+	OK(t, w.Abort())
+	Assert(t, w.Close() != nil, "Close after Abort did not return an error")
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "aborted file was saved")
+	Equals(t, 0, tempfiles(t, be))
+}
+
+func TestSaveWriterHashMismatch(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+	be.VerifyHash = true
+
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash([]byte("foo")).String()}
+	w, err := be.SaveWriter(h)
+	OK(t, err)
+	_, err = w.Write([]byte("bar"))
+	OK(t, err)
+
+	err = w.Close()
+	Assert(t, errors.Cause(err) == local.ErrHashMismatch, "expected ErrHashMismatch, got %v", err)
+
+	ok, err := be.Test(h)
+	OK(t, err)
+	Assert(t, !ok, "file with wrong content was saved")
+	Equals(t, 0, tempfiles(t, be))
+}
+
+func TestSaveWriterChecks(t *testing.T) {
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	var hooked []restic.Handle
+	be.SaveHook = func(h restic.Handle, rd io.Reader) (io.Reader, error) {
+		hooked = append(hooked, h)
+		return rd, nil
+	}
+
+	data := Random(23, 10000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	w, err := be.SaveWriter(h)
+	OK(t, err)
+	_, err = w.Write(data)
+	OK(t, err)
+	OK(t, w.Close())
+	Equals(t, []restic.Handle{h}, hooked)
+
+	be.PermittedTypes = []restic.FileType{restic.SnapshotFile}
+	_, err = be.SaveWriter(restic.Handle{Type: restic.KeyFile, Name: "key"})
+	Assert(t, errors.Cause(err) == local.ErrTypeNotPermitted,
+		"expected ErrTypeNotPermitted, got %v", err)
+}
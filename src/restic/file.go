@@ -19,6 +19,16 @@ const (
 	ConfigFile            = "config"
 )
 
+// MaxNameLength is the maximum length of a file name accepted by
+// Handle.Valid. The default matches NAME_MAX on most file systems.
+var MaxNameLength = 255
+
+// These errors are returned by Handle.Valid for invalid names.
+var (
+	ErrEmptyName   = errors.New("invalid Name")
+	ErrNameTooLong = errors.New("name too long")
+)
+
 // Handle is used to store and access data in a backend.
 type Handle struct {
 	Type FileType
@@ -55,7 +65,11 @@ func (h Handle) Valid() error {
 	}
 
 	if h.Name == "" {
-		return errors.New("invalid Name")
+		return ErrEmptyName
+	}
+
+	if len(h.Name) > MaxNameLength {
+		return errors.Wrapf(ErrNameTooLong, "%d > %d bytes", len(h.Name), MaxNameLength)
 	}
 
 	return nil
@@ -1,6 +1,11 @@
 package restic
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"restic/errors"
+)
 
 var handleTests = []struct {
 	h     Handle
@@ -26,3 +31,32 @@ func TestHandleValid(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleValidName(t *testing.T) {
+	types := []FileType{DataFile, KeyFile, LockFile, SnapshotFile, IndexFile}
+
+	for _, tpe := range types {
+		err := Handle{Type: tpe}.Valid()
+		if errors.Cause(err) != ErrEmptyName {
+			t.Errorf("%v: expected ErrEmptyName, got %v", tpe, err)
+		}
+
+		err = Handle{Type: tpe, Name: strings.Repeat("a", MaxNameLength)}.Valid()
+		if err != nil {
+			t.Errorf("%v: unexpected error for name of maximum length: %v", tpe, err)
+		}
+
+		err = Handle{Type: tpe, Name: strings.Repeat("a", MaxNameLength+1)}.Valid()
+		if errors.Cause(err) != ErrNameTooLong {
+			t.Errorf("%v: expected ErrNameTooLong, got %v", tpe, err)
+		}
+	}
+
+	defer func(max int) { MaxNameLength = max }(MaxNameLength)
+	MaxNameLength = 10
+
+	err := Handle{Type: DataFile, Name: strings.Repeat("a", 11)}.Valid()
+	if errors.Cause(err) != ErrNameTooLong {
+		t.Errorf("expected ErrNameTooLong for lowered maximum, got %v", err)
+	}
+}
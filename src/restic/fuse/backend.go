@@ -0,0 +1,186 @@
+// +build linux darwin
+
+package fuse
+
+import (
+	"io"
+	"os"
+
+	"restic"
+	"restic/debug"
+	"restic/errors"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"golang.org/x/net/context"
+)
+
+// Statically ensure that the backend view implements the given interfaces
+var _ = fs.HandleReadDirAller(&backendDir{})
+var _ = fs.NodeStringLookuper(&backendDir{})
+var _ = fs.HandleReader(&backendFile{})
+
+// backendDirs maps the directory names of the view to the file types.
+var backendDirs = map[string]restic.FileType{
+	"data":      restic.DataFile,
+	"index":     restic.IndexFile,
+	"keys":      restic.KeyFile,
+	"locks":     restic.LockFile,
+	"snapshots": restic.SnapshotFile,
+}
+
+// NewBackendView returns a read-only file system which exposes the raw files
+// stored in be, e.g. /data/<name> and /snapshots/<name>. All operations are
+// translated to calls to Load, Stat and List.
+func NewBackendView(be restic.Backend) fs.FS {
+	root := &fs.Tree{}
+	for name, t := range backendDirs {
+		root.Add(name, &backendDir{be: be, t: t})
+	}
+	root.Add("config", &backendFile{be: be, h: restic.Handle{Type: restic.ConfigFile}})
+	return root
+}
+
+type backendDir struct {
+	be restic.Backend
+	t  restic.FileType
+}
+
+func (d *backendDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = os.ModeDir | 0555
+	return nil
+}
+
+func (d *backendDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	debug.Log("list %v", d.t)
+	done := make(chan struct{})
+	defer close(done)
+
+	var ret []fuse.Dirent
+	for name := range d.be.List(d.t, done) {
+		ret = append(ret, fuse.Dirent{Name: name, Type: fuse.DT_File})
+	}
+	return ret, nil
+}
+
+func (d *backendDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	h := restic.Handle{Type: d.t, Name: name}
+	if err := h.Valid(); err != nil {
+		return nil, fuse.ENOENT
+	}
+
+	f := &backendFile{be: d.be, h: h}
+	if _, err := f.stat(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type backendFile struct {
+	be restic.Backend
+	h  restic.Handle
+}
+
+// stat returns the size of the file, fuse.ENOENT is returned if it does not
+// exist.
+func (f *backendFile) stat() (int64, error) {
+	ok, err := f.be.Test(f.h)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fuse.ENOENT
+	}
+
+	fi, err := f.be.Stat(f.h)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size, nil
+}
+
+func (f *backendFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	size, err := f.stat()
+	if err != nil {
+		return err
+	}
+
+	a.Mode = 0444
+	a.Size = uint64(size)
+	a.Blocks = (a.Size + blockSize - 1) / blockSize
+	return nil
+}
+
+func (f *backendFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	debug.Log("Read(%v, %v, %v)", f.h, req.Size, req.Offset)
+	if req.Size == 0 {
+		resp.Data = resp.Data[:0]
+		return nil
+	}
+
+	rd, err := f.be.Load(f.h, req.Size, req.Offset)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	buf := resp.Data[:req.Size]
+	n, err := io.ReadFull(rd, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "ReadFull")
+	}
+
+	resp.Data = buf[:n]
+	return nil
+}
+
+// BackendMount is a mounted backend view, created by MountBackend.
+type BackendMount struct {
+	mountpoint string
+	conn       *fuse.Conn
+	served     chan error
+}
+
+// MountBackend mounts a read-only view of be at mountpoint and serves it in
+// the background until Unmount is called.
+func MountBackend(be restic.Backend, mountpoint string) (*BackendMount, error) {
+	c, err := fuse.Mount(mountpoint, fuse.ReadOnly(), fuse.FSName("restic"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Mount")
+	}
+
+	m := &BackendMount{
+		mountpoint: mountpoint,
+		conn:       c,
+		served:     make(chan error, 1),
+	}
+
+	go func() {
+		m.served <- fs.Serve(c, NewBackendView(be))
+	}()
+
+	<-c.Ready
+	if err := c.MountError; err != nil {
+		_ = c.Close()
+		return nil, errors.Wrap(err, "Mount")
+	}
+
+	debug.Log("backend view mounted at %v", mountpoint)
+	return m, nil
+}
+
+// Unmount unmounts the view and waits until serving has finished.
+func (m *BackendMount) Unmount() error {
+	if err := fuse.Unmount(m.mountpoint); err != nil {
+		return errors.Wrap(err, "Unmount")
+	}
+
+	err := <-m.served
+	if cerr := m.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
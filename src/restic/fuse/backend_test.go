@@ -0,0 +1,57 @@
+// +build linux darwin
+
+package fuse_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"restic"
+	"restic/backend/local"
+	"restic/fuse"
+	. "restic/test"
+)
+
+func TestMountBackend(t *testing.T) {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		t.Skip("FUSE not available")
+	}
+
+	be, cleanup := local.TestBackend(t)
+	defer cleanup()
+
+	data := Random(23, 4096)
+	id := restic.Hash(data)
+	h := restic.Handle{Type: restic.DataFile, Name: id.String()}
+	OK(t, be.Save(h, bytes.NewReader(data)))
+
+	mnt, err := ioutil.TempDir(TestTempDir, "restic-test-mount-")
+	OK(t, err)
+	defer RemoveAll(t, mnt)
+
+	m, err := fuse.MountBackend(be, mnt)
+	if err != nil {
+		t.Skipf("unable to mount: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(mnt, "data", id.String()))
+	if err != nil {
+		_ = m.Unmount()
+		t.Fatal(err)
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(mnt, "data"))
+	if err != nil {
+		_ = m.Unmount()
+		t.Fatal(err)
+	}
+
+	OK(t, m.Unmount())
+
+	Assert(t, bytes.Equal(buf, data), "wrong data returned")
+	Equals(t, 1, len(entries))
+	Equals(t, id.String(), entries[0].Name())
+}